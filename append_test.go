@@ -0,0 +1,104 @@
+package g726
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestAppendAPI(t *testing.T) {
+	pcm := testSignal(1601)
+	for _, packing := range []PackingType{PackingNone, PackingLeft, PackingRight} {
+		for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+			ref := G726_init_state(rate, packing)
+			enc := G726_init_state(rate, packing)
+			var data []byte
+			for i := 0; i < len(pcm); i += 160 {
+				end := i + 160
+				if end > len(pcm) {
+					end = len(pcm)
+				}
+				want := ref.EncodeV2(pcm[i:end])
+
+				dst := make([]byte, enc.EncodedLen(end-i))
+				if len(dst) > 0 {
+					if _, err := enc.EncodeInto(dst[:len(dst)-1], pcm[i:end]); !errors.Is(err, io.ErrShortBuffer) {
+						t.Fatalf("got %v, want io.ErrShortBuffer", err)
+					}
+				}
+				n, err := enc.EncodeInto(dst, pcm[i:end])
+				if err != nil || string(dst[:n]) != string(want) {
+					t.Fatalf("%v packing %v: EncodeInto differs from EncodeV2", rate, packing)
+				}
+				data = append(data, want...)
+			}
+			if !ref.Equal(enc) {
+				t.Fatalf("%v packing %v: encoder states differ", rate, packing)
+			}
+
+			ref = G726_init_state(rate, packing)
+			dec := G726_init_state(rate, packing)
+			want := ref.DecodeV2(data)
+			out := make([]int16, dec.DecodedLen(len(data)))
+			n, err := dec.DecodeInto(out, data)
+			if err != nil || n != len(want) {
+				t.Fatalf("%v packing %v: DecodeInto returned %d, %v", rate, packing, n, err)
+			}
+			for i := range want {
+				if out[i] != want[i] {
+					t.Fatalf("%v packing %v: sample %d differs", rate, packing, i)
+				}
+			}
+		}
+	}
+}
+
+func TestAppendZeroAlloc(t *testing.T) {
+	pcm := testSignal(160)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		enc := G726_init_state(rate, PackingRight)
+		dec := G726_init_state(rate, PackingRight)
+		data := make([]byte, 0, 200)
+		out := make([]int16, 0, 200)
+		pcm8 := make([]byte, 0, 400)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			data = enc.AppendEncode(data[:0], pcm)
+			out = dec.AppendDecode(out[:0], data)
+			pcm8 = AppendPcm16ToPcm8(pcm8[:0], out)
+			out = AppendPcm8ToPcm16(out[:0], pcm8)
+		})
+		if allocs != 0 {
+			t.Fatalf("%v: %v allocations per frame", rate, allocs)
+		}
+	}
+}
+
+func BenchmarkAppendEncode(b *testing.B) {
+	pcm := testSignal(160)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		b.Run(rate.String(), func(b *testing.B) {
+			enc := G726_init_state(rate, PackingRight)
+			data := make([]byte, 0, 200)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data = enc.AppendEncode(data[:0], pcm)
+			}
+		})
+	}
+}
+
+func BenchmarkAppendDecode(b *testing.B) {
+	pcm := testSignal(160)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		b.Run(rate.String(), func(b *testing.B) {
+			data := G726_init_state(rate, PackingRight).EncodeV2(pcm)
+			dec := G726_init_state(rate, PackingRight)
+			out := make([]int16, 0, 200)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				out = dec.AppendDecode(out[:0], data)
+			}
+		})
+	}
+}
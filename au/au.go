@@ -0,0 +1,213 @@
+// Package au 读写 Sun/NeXT .au 音频文件, 支持 G.721/G.723 ADPCM (编码 23, 25, 26), G.711 和线性 PCM
+//
+// 文件头和线性 PCM 为大端序. ADPCM 编码与 G.726 的对应关系:
+//
+//	23  G.721 4 bit   32kbps
+//	25  G.723 3 bit   24kbps
+//	26  G.723 5 bit   40kbps
+//
+// ADPCM 码字按 PackingRight (低位在前, 与 Sun 的 g72x 参考代码一致)打包, 只支持单声道.
+// .au 没有记录采样点数, 最后一个字节中补齐的比特可能被解码为多余的1到2个采样点.
+package au
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/g711"
+)
+
+// 编码
+const (
+	EncodingULaw     = 1  // 8 bit G.711 μ律
+	EncodingLinear8  = 2  // 8 bit 线性 PCM
+	EncodingLinear16 = 3  // 16 bit 线性 PCM
+	EncodingG721     = 23 // 4 bit G.721 ADPCM, 即 32kbps G.726
+	EncodingG722     = 24 // G.722 ADPCM, 不支持
+	EncodingG723_3   = 25 // 3 bit G.723 ADPCM, 即 24kbps G.726
+	EncodingG723_5   = 26 // 5 bit G.723 ADPCM, 即 40kbps G.726
+	EncodingALaw     = 27 // 8 bit G.711 A律
+)
+
+const (
+	magic      = 0x2e736e64 // ".snd"
+	headerSize = 24
+	unknownLen = 0xFFFFFFFF
+
+	maxAnnotation = 4096 // 读取时保留的注释长度, 数据偏移来自文件, 超出的部分跳过
+)
+
+var (
+	ErrFormat      = errors.New("au: not an .au file")
+	ErrUnsupported = errors.New("au: unsupported encoding")
+)
+
+// Header .au 文件头
+type Header struct {
+	Encoding   uint32
+	SampleRate int
+	Channels   int
+	Annotation string // 去掉了结尾的 NUL, Read 最多保留 4096 字节
+}
+
+// EncodingForRate 返回 G.726 码率对应的编码, 16kbps 没有对应的编码
+func EncodingForRate(rate g726.Rate) (uint32, error) {
+	switch rate {
+	case g726.Rate24kbps:
+		return EncodingG723_3, nil
+	case g726.Rate32kbps:
+		return EncodingG721, nil
+	case g726.Rate40kbps:
+		return EncodingG723_5, nil
+	default:
+		return 0, fmt.Errorf("%w: no .au encoding for %v", ErrUnsupported, rate)
+	}
+}
+
+// G726Rate 返回 ADPCM 编码对应的 G.726 码率
+func (h Header) G726Rate() (g726.Rate, error) {
+	switch h.Encoding {
+	case EncodingG723_3:
+		return g726.Rate24kbps, nil
+	case EncodingG721:
+		return g726.Rate32kbps, nil
+	case EncodingG723_5:
+		return g726.Rate40kbps, nil
+	default:
+		return 0, fmt.Errorf("%w %d", ErrUnsupported, h.Encoding)
+	}
+}
+
+func (h Header) marshal(dataSize uint32) []byte {
+	// 注释以 NUL 结尾, 补齐到4字节, 至少4字节
+	annotation := append([]byte(h.Annotation), 0)
+	for len(annotation)%4 != 0 {
+		annotation = append(annotation, 0)
+	}
+
+	b := make([]byte, headerSize, headerSize+len(annotation))
+	binary.BigEndian.PutUint32(b[0:], magic)
+	binary.BigEndian.PutUint32(b[4:], uint32(headerSize+len(annotation)))
+	binary.BigEndian.PutUint32(b[8:], dataSize)
+	binary.BigEndian.PutUint32(b[12:], h.Encoding)
+	binary.BigEndian.PutUint32(b[16:], uint32(h.SampleRate))
+	binary.BigEndian.PutUint32(b[20:], uint32(h.Channels))
+	return append(b, annotation...)
+}
+
+// File .au 文件的内容
+type File struct {
+	Header
+	Data []byte
+}
+
+// Read 读取 .au 文件, 数据长度未知(0xFFFFFFFF)时读到文件结尾
+func Read(r io.Reader) (*File, error) {
+	var b [headerSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(b[0:]) != magic {
+		return nil, ErrFormat
+	}
+	offset := binary.BigEndian.Uint32(b[4:])
+	size := binary.BigEndian.Uint32(b[8:])
+	if offset < headerSize {
+		return nil, fmt.Errorf("au: invalid data offset %d", offset)
+	}
+
+	f := &File{Header: Header{
+		Encoding:   binary.BigEndian.Uint32(b[12:]),
+		SampleRate: int(binary.BigEndian.Uint32(b[16:])),
+		Channels:   int(binary.BigEndian.Uint32(b[20:])),
+	}}
+	if f.Channels == 0 {
+		return nil, fmt.Errorf("au: zero channels")
+	}
+
+	skip := int64(offset - headerSize)
+	n := skip
+	if n > maxAnnotation {
+		n = maxAnnotation
+	}
+	annotation := make([]byte, n)
+	if _, err := io.ReadFull(r, annotation); err != nil {
+		return nil, err
+	}
+	if skip -= int64(len(annotation)); skip > 0 {
+		if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	if i := bytes.IndexByte(annotation, 0); i >= 0 {
+		annotation = annotation[:i]
+	}
+	f.Annotation = string(annotation)
+
+	var err error
+	if size == unknownLen {
+		f.Data, err = io.ReadAll(r)
+	} else {
+		// 长度超过文件时返回已经读到的数据
+		f.Data, err = io.ReadAll(io.LimitReader(r, int64(size)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write 写入 .au 文件
+func Write(w io.Writer, f *File) error {
+	if _, err := w.Write(f.Header.marshal(uint32(len(f.Data)))); err != nil {
+		return err
+	}
+	_, err := w.Write(f.Data)
+	return err
+}
+
+// PCM 返回文件的16位 PCM (按声道交织), ADPCM 和 G.711 先解码
+func (f *File) PCM(opts ...Option) ([]int16, error) {
+	switch f.Encoding {
+	case EncodingLinear16:
+		pcm := make([]int16, len(f.Data)/2)
+		for i := range pcm {
+			pcm[i] = int16(binary.BigEndian.Uint16(f.Data[2*i:]))
+		}
+		return pcm, nil
+	case EncodingLinear8:
+		pcm := make([]int16, len(f.Data))
+		for i, v := range f.Data {
+			pcm[i] = int16(int8(v)) << 8
+		}
+		return pcm, nil
+	case EncodingULaw:
+		return g711.Decode(g711.ULaw, f.Data), nil
+	case EncodingALaw:
+		return g711.Decode(g711.ALaw, f.Data), nil
+	}
+
+	rate, err := f.G726Rate()
+	if err != nil {
+		return nil, err
+	}
+	if f.Channels != 1 {
+		return nil, fmt.Errorf("%w: ADPCM with %d channels", ErrUnsupported, f.Channels)
+	}
+	c := newConfig(opts)
+	dec, err := g726.New(rate, c.packing)
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeV2(f.Data), nil
+}
@@ -0,0 +1,177 @@
+package au
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/g711"
+)
+
+func testSignal(n int) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	return pcm
+}
+
+func TestG726(t *testing.T) {
+	pcm := testSignal(1001)
+	encodings := map[g726.Rate]uint32{g726.Rate24kbps: 25, g726.Rate32kbps: 23, g726.Rate40kbps: 26}
+
+	if _, err := EncodingForRate(g726.Rate16kbps); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("16kbps: %v", err)
+	}
+
+	for rate, encoding := range encodings {
+		var buf bytes.Buffer
+		if err := WriteG726(&buf, pcm, rate, WithAnnotation("voicemail 42")); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		bits := int(rate) + 2
+		dataLen := (1001*bits + 7) / 8
+		if string(b[:4]) != ".snd" || binary.BigEndian.Uint32(b[4:]) != 40 || binary.BigEndian.Uint32(b[8:]) != uint32(dataLen) ||
+			binary.BigEndian.Uint32(b[12:]) != encoding || binary.BigEndian.Uint32(b[16:]) != 8000 || binary.BigEndian.Uint32(b[20:]) != 1 ||
+			len(b) != 40+dataLen {
+			t.Fatalf("rate %v: bad header % x", rate, b[:24])
+		}
+
+		got, h, err := ReadPCM(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Annotation != "voicemail 42" || h.Encoding != encoding {
+			t.Fatalf("header %+v", h)
+		}
+		if r, _ := h.G726Rate(); r != rate {
+			t.Fatalf("rate %v, want %v", r, rate)
+		}
+
+		enc, _ := g726.New(rate, g726.PackingRight)
+		dec, _ := g726.New(rate, g726.PackingRight)
+		want := dec.DecodeV2(append(enc.EncodeV2(pcm), enc.Flush()...))
+		if len(got) < len(pcm) || len(got) > len(pcm)+2 || len(got) != len(want) {
+			t.Fatalf("rate %v: %d samples", rate, len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("rate %v: sample %d = %d, want %d", rate, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestLinearAndG711(t *testing.T) {
+	pcm := testSignal(500)
+
+	var buf bytes.Buffer
+	if err := WritePCM(&buf, pcm, 2, 8000); err != nil {
+		t.Fatal(err)
+	}
+	got, h, err := ReadPCM(&buf)
+	if err != nil || h.Channels != 2 || h.Encoding != EncodingLinear16 || h.Annotation != "" {
+		t.Fatal(h, err)
+	}
+	for i := range pcm {
+		if got[i] != pcm[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], pcm[i])
+		}
+	}
+
+	for _, c := range []struct {
+		encoding uint32
+		law      g711.Law
+	}{{EncodingULaw, g711.ULaw}, {EncodingALaw, g711.ALaw}} {
+		buf.Reset()
+		w, err := NewWriter(&buf, Header{Encoding: c.encoding, SampleRate: 8000, Channels: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteSamples(pcm)
+		w.Close()
+
+		f, err := Read(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.Data, g711.Encode(c.law, pcm)) {
+			t.Fatalf("encoding %d: data differs", c.encoding)
+		}
+	}
+}
+
+// writerOnly 隐藏 bytes.Buffer 以外的接口
+type writerOnly struct {
+	buf bytes.Buffer
+}
+
+func (w *writerOnly) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func TestWriterLength(t *testing.T) {
+	pcm := testSignal(800)
+
+	// 不支持 Seek 时长度未知, 读到文件结尾
+	var stream writerOnly
+	w, _ := NewWriter(&stream, Header{Encoding: EncodingG721, SampleRate: 8000, Channels: 1})
+	for i := 0; i < len(pcm); i += 100 {
+		if err := w.WriteSamples(pcm[i : i+100]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	b := stream.buf.Bytes()
+	if binary.BigEndian.Uint32(b[8:]) != unknownLen || binary.BigEndian.Uint32(b[4:]) != 28 {
+		t.Fatalf("header % x", b[:28])
+	}
+	f, err := Read(bytes.NewReader(b))
+	if err != nil || len(f.Data) != 400 {
+		t.Fatal(len(f.Data), err)
+	}
+
+	// 写入文件时补上长度
+	name := filepath.Join(t.TempDir(), "test.au")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, _ = NewWriter(file, Header{Encoding: EncodingG721, SampleRate: 8000, Channels: 1})
+	w.WriteSamples(pcm)
+	w.Close()
+	file.Close()
+	b2, _ := os.ReadFile(name)
+	binary.BigEndian.PutUint32(b[8:], 400)
+	if !bytes.Equal(b, b2) {
+		t.Fatal("file output differs")
+	}
+
+	// 数据偏移来自文件, 过大的注释只保留开头, 不按偏移分配内存
+	huge := Header{Encoding: EncodingG721, SampleRate: 8000, Channels: 1}.marshal(4)
+	binary.BigEndian.PutUint32(huge[4:], 0xFFFFFFF0)
+	if _, err := Read(bytes.NewReader(huge)); err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+	long := Header{Encoding: EncodingG721, SampleRate: 8000, Channels: 1}.marshal(4)[:headerSize]
+	long = append(long, bytes.Repeat([]byte{'x'}, 10000)...)
+	binary.BigEndian.PutUint32(long[4:], uint32(len(long)))
+	f, err = Read(bytes.NewReader(append(long, 1, 2, 3, 4)))
+	if err != nil || len(f.Annotation) != maxAnnotation || !bytes.Equal(f.Data, []byte{1, 2, 3, 4}) {
+		t.Fatal(len(f.Annotation), f.Data, err)
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("RIFF0000WAVEfmt 00000000"))); !errors.Is(err, ErrFormat) {
+		t.Fatal(err)
+	}
+	if _, err := NewWriter(&stream, Header{Encoding: EncodingG721, SampleRate: 8000, Channels: 2}); !errors.Is(err, ErrUnsupported) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,199 @@
+package au
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/g711"
+)
+
+type config struct {
+	packing    g726.PackingType
+	annotation string
+}
+
+func newConfig(opts []Option) config {
+	c := config{packing: g726.PackingRight}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Option 用于编解码和写入的可选配置
+type Option func(*config)
+
+// WithPacking 设置 ADPCM 码字的打包方式, 默认为 PackingRight
+func WithPacking(packing g726.PackingType) Option {
+	return func(c *config) {
+		c.packing = packing
+	}
+}
+
+// WithAnnotation 设置写入的注释
+func WithAnnotation(annotation string) Option {
+	return func(c *config) {
+		c.annotation = annotation
+	}
+}
+
+// ReadPCM 读取 .au 文件, 返回16位 PCM (按声道交织)和文件头
+func ReadPCM(r io.Reader, opts ...Option) ([]int16, Header, error) {
+	f, err := Read(r)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	pcm, err := f.PCM(opts...)
+	return pcm, f.Header, err
+}
+
+// WritePCM 写入16位线性 PCM 的 .au 文件
+func WritePCM(w io.Writer, pcm []int16, channels, sampleRate int, opts ...Option) error {
+	c := newConfig(opts)
+	return writeAll(w, pcm, Header{
+		Encoding:   EncodingLinear16,
+		SampleRate: sampleRate,
+		Channels:   channels,
+		Annotation: c.annotation,
+	}, opts)
+}
+
+// WriteG726 把单声道 8000Hz 的 PCM 编码为 ADPCM 并写入 .au 文件
+func WriteG726(w io.Writer, pcm []int16, rate g726.Rate, opts ...Option) error {
+	encoding, err := EncodingForRate(rate)
+	if err != nil {
+		return err
+	}
+	c := newConfig(opts)
+	return writeAll(w, pcm, Header{
+		Encoding:   encoding,
+		SampleRate: 8000,
+		Channels:   1,
+		Annotation: c.annotation,
+	}, opts)
+}
+
+// writeAll 先编码全部数据, 文件头中写入实际的数据长度
+func writeAll(w io.Writer, pcm []int16, header Header, opts []Option) error {
+	var buf bytes.Buffer
+	aw, err := NewWriter(&buf, header, opts...)
+	if err != nil {
+		return err
+	}
+	if err := aw.WriteSamples(pcm); err != nil {
+		return err
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[8:], uint32(aw.bytes))
+	_, err = w.Write(b)
+	return err
+}
+
+// Writer 流式写入 .au 文件
+// 文件头中的数据长度先写为未知(0xFFFFFFFF), 目标支持 Seek 时 Close 补上实际长度
+type Writer struct {
+	w      io.Writer
+	header Header
+	start  int64 // 文件头的位置, 目标不支持 Seek 时为-1
+	offset int   // 数据的偏移
+	bytes  int64
+	enc    *g726.G726_state
+	buf    []byte
+}
+
+// NewWriter 写入文件头并创建 Writer, header.Annotation 为空时使用 WithAnnotation 设置的注释
+func NewWriter(w io.Writer, header Header, opts ...Option) (*Writer, error) {
+	c := newConfig(opts)
+	if header.Annotation == "" {
+		header.Annotation = c.annotation
+	}
+	if header.Channels <= 0 {
+		return nil, fmt.Errorf("au: invalid channel count %d", header.Channels)
+	}
+
+	aw := &Writer{w: w, header: header, start: -1}
+	if rate, err := header.G726Rate(); err == nil {
+		if header.Channels != 1 {
+			return nil, fmt.Errorf("%w: ADPCM with %d channels", ErrUnsupported, header.Channels)
+		}
+		if aw.enc, err = g726.New(rate, c.packing); err != nil {
+			return nil, err
+		}
+	}
+
+	if s, ok := w.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			aw.start = pos
+		}
+	}
+	b := header.marshal(unknownLen)
+	aw.offset = len(b)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// Write 写入已经编码的数据
+func (w *Writer) Write(data []byte) (int, error) {
+	n, err := w.w.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// WriteSamples 按文件头中的编码把 PCM (按声道交织)编码后写入
+func (w *Writer) WriteSamples(pcm []int16) error {
+	w.buf = w.buf[:0]
+	switch w.header.Encoding {
+	case EncodingLinear16:
+		for _, v := range pcm {
+			w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(v))
+		}
+	case EncodingLinear8:
+		for _, v := range pcm {
+			w.buf = append(w.buf, byte(v>>8))
+		}
+	case EncodingULaw:
+		w.buf = g711.ULaw.AppendEncode(w.buf, pcm)
+	case EncodingALaw:
+		w.buf = g711.ALaw.AppendEncode(w.buf, pcm)
+	default:
+		if w.enc == nil {
+			return fmt.Errorf("%w %d", ErrUnsupported, w.header.Encoding)
+		}
+		w.buf = w.enc.AppendEncode(w.buf, pcm)
+	}
+	_, err := w.Write(w.buf)
+	return err
+}
+
+// Close 写出 ADPCM 剩余的比特, 目标支持 Seek 时补上数据长度
+func (w *Writer) Close() error {
+	if w.enc != nil {
+		if _, err := w.Write(w.enc.Flush()); err != nil {
+			return err
+		}
+	}
+
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok || w.start < 0 || w.bytes >= unknownLen {
+		return nil
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(w.bytes))
+	if _, err := ws.Seek(w.start+8, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := ws.Write(b[:]); err != nil {
+		return err
+	}
+	_, err := ws.Seek(w.start+int64(w.offset)+w.bytes, io.SeekStart)
+	return err
+}
@@ -0,0 +1,365 @@
+// Package avi 解析和生成 AVI 文件, 用于处理带有 G.726 音频的监控录像
+//
+// Read 读取整个文件, 返回各个流的头信息和 movi 中按顺序排列的数据块.
+// 默认所有数据块(包括视频)都保存在内存中, 内存占用与文件大小相当,
+// 只需要音频时用 WithStreamTypes(TypeAudio) 跳过其他流的数据.
+// 音频流的格式为 WAVEFORMATEX (参见 wav.Format), DecodeAudio 把音频流解码为 PCM,
+// 支持 G.726, G.711 和16位 PCM. 视频等其他流的数据原样保留.
+// Writer 把一路不透明的视频流和一路 G.726 音频流写入 AVI 文件, 带有 idx1 索引.
+// 不支持 OpenDML (超过 1GB 的 AVIX 扩展).
+package avi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/g711"
+	"github.com/general252/g726/internal/riff"
+	"github.com/general252/g726/wav"
+)
+
+var (
+	ErrFormat  = errors.New("avi: not an AVI file")
+	ErrNoAudio = errors.New("avi: no audio stream")
+)
+
+// 流类型
+const (
+	TypeVideo = "vids"
+	TypeAudio = "auds"
+)
+
+var (
+	aviID  = riff.FourCC{'A', 'V', 'I', ' '}
+	hdrlID = riff.FourCC{'h', 'd', 'r', 'l'}
+	avihID = riff.FourCC{'a', 'v', 'i', 'h'}
+	strlID = riff.FourCC{'s', 't', 'r', 'l'}
+	strhID = riff.FourCC{'s', 't', 'r', 'h'}
+	strfID = riff.FourCC{'s', 't', 'r', 'f'}
+	moviID = riff.FourCC{'m', 'o', 'v', 'i'}
+	recID  = riff.FourCC{'r', 'e', 'c', ' '}
+	idx1ID = riff.FourCC{'i', 'd', 'x', '1'}
+)
+
+const (
+	avihSize = 56
+	strhSize = 56
+
+	avifHasIndex = 0x10 // AVIF_HASINDEX
+	aviifKey     = 0x10 // AVIIF_KEYFRAME
+)
+
+// Stream 流的头信息(AVIStreamHeader 和 strf)
+type Stream struct {
+	Type       string // TypeVideo, TypeAudio 等
+	Handler    string
+	Scale      uint32 // Rate/Scale 为每秒的采样数(视频为帧率)
+	Rate       uint32
+	Length     uint32 // 以 Scale 为单位的长度
+	SampleSize uint32
+	Format     []byte      // strf 的原始内容
+	Audio      *wav.Format // 音频流的格式, 其他流为 nil
+}
+
+// Chunk movi 中的一个数据块
+type Chunk struct {
+	Stream int
+	Type   string // 块类型的后两个字符, 例如 "dc" (压缩的视频帧), "wb" (音频)
+	Data   []byte
+	Key    bool // idx1 中标记为关键帧
+}
+
+// File AVI 文件的内容
+type File struct {
+	MicroSecPerFrame uint32
+	Width, Height    int
+	Streams          []Stream
+	Chunks           []Chunk
+}
+
+// Read 读取 AVI 文件, 可以用 WithStreamTypes 只保留部分流的数据
+func Read(r io.Reader, opts ...Option) (*File, error) {
+	c := newConfig(opts)
+	form, chunks, err := riff.ReadForm(r)
+	if err == riff.ErrFormat || err == nil && form != aviID {
+		return nil, ErrFormat
+	} else if err != nil {
+		return nil, err
+	}
+
+	f := &File{}
+	var index []byte
+	for {
+		id, _, body, err := chunks.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch id {
+		case riff.LIST:
+			var listType riff.FourCC
+			if _, err := io.ReadFull(body, listType[:]); err != nil {
+				return nil, err
+			}
+			switch listType {
+			case hdrlID:
+				err = f.readHeaders(riff.NewReader(body))
+			case moviID:
+				err = f.readMovi(riff.NewReader(body), &c)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case idx1ID:
+			if index, err = io.ReadAll(body); err != nil && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+		}
+	}
+	if f.Streams == nil {
+		return nil, fmt.Errorf("avi: missing stream headers")
+	}
+
+	// idx1 中的项与 movi 中的块顺序相同
+	if len(index)/16 == len(f.Chunks) {
+		for i := range f.Chunks {
+			flags := binary.LittleEndian.Uint32(index[16*i+4:])
+			f.Chunks[i].Key = flags&aviifKey != 0
+		}
+	}
+	return f, nil
+}
+
+func (f *File) readHeaders(chunks *riff.Reader) error {
+	for {
+		id, _, body, err := chunks.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch id {
+		case avihID:
+			var b [avihSize]byte
+			if _, err := io.ReadFull(body, b[:]); err != nil {
+				return fmt.Errorf("avi: avih: %w", err)
+			}
+			f.MicroSecPerFrame = binary.LittleEndian.Uint32(b[0:])
+			f.Width = int(binary.LittleEndian.Uint32(b[32:]))
+			f.Height = int(binary.LittleEndian.Uint32(b[36:]))
+		case riff.LIST:
+			var listType riff.FourCC
+			if _, err := io.ReadFull(body, listType[:]); err != nil {
+				return err
+			}
+			if listType == strlID {
+				s, err := readStream(riff.NewReader(body))
+				if err != nil {
+					return err
+				}
+				f.Streams = append(f.Streams, s)
+			}
+		}
+	}
+}
+
+func readStream(chunks *riff.Reader) (Stream, error) {
+	var s Stream
+	for {
+		id, _, body, err := chunks.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return s, err
+		}
+
+		switch id {
+		case strhID:
+			b, err := io.ReadAll(body)
+			if err != nil {
+				return s, err
+			}
+			if len(b) < 48 {
+				return s, fmt.Errorf("avi: strh too short (%d bytes)", len(b))
+			}
+			s.Type = string(b[0:4])
+			s.Handler = string(b[4:8])
+			s.Scale = binary.LittleEndian.Uint32(b[20:])
+			s.Rate = binary.LittleEndian.Uint32(b[24:])
+			s.Length = binary.LittleEndian.Uint32(b[32:])
+			s.SampleSize = binary.LittleEndian.Uint32(b[44:])
+		case strfID:
+			if s.Format, err = io.ReadAll(body); err != nil {
+				return s, err
+			}
+		}
+	}
+
+	if s.Type == TypeAudio {
+		var a wav.Format
+		if err := a.UnmarshalBinary(s.Format); err != nil {
+			return s, err
+		}
+		s.Audio = &a
+	}
+	return s, nil
+}
+
+func (f *File) readMovi(chunks *riff.Reader, c *config) error {
+	for {
+		id, _, body, err := chunks.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if id == riff.LIST {
+			var listType riff.FourCC
+			if _, err := io.ReadFull(body, listType[:]); err != nil {
+				return err
+			}
+			if listType == recID {
+				if err := f.readMovi(riff.NewReader(body), c); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		stream, ok := parseStreamID(id)
+		if !ok {
+			// JUNK 等
+			continue
+		}
+		if !f.keep(stream, c) {
+			// 保留块的位置, 使 idx1 中的项仍然对应, 数据由 Next 跳过
+			f.Chunks = append(f.Chunks, Chunk{Stream: stream, Type: string(id[2:])})
+			continue
+		}
+		data, err := io.ReadAll(body)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		f.Chunks = append(f.Chunks, Chunk{Stream: stream, Type: string(id[2:]), Data: data})
+		if err == io.ErrUnexpectedEOF {
+			// 录像被截断
+			return nil
+		}
+	}
+}
+
+// keep 返回是否保存流 stream 的数据
+func (f *File) keep(stream int, c *config) bool {
+	if c.types == nil {
+		return true
+	}
+	if stream >= len(f.Streams) {
+		return false
+	}
+	for _, t := range c.types {
+		if f.Streams[stream].Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStreamID 解析 "01wb" 形式的块 ID
+func parseStreamID(id riff.FourCC) (int, bool) {
+	if id[0] < '0' || id[0] > '9' || id[1] < '0' || id[1] > '9' {
+		return 0, false
+	}
+	return int(id[0]-'0')*10 + int(id[1]-'0'), true
+}
+
+// AudioStream 返回第一个音频流的序号
+func (f *File) AudioStream() (int, error) {
+	for i, s := range f.Streams {
+		if s.Type == TypeAudio && s.Audio != nil {
+			return i, nil
+		}
+	}
+	return 0, ErrNoAudio
+}
+
+// StreamData 返回一个流的所有数据块按顺序连接的结果
+func (f *File) StreamData(stream int) []byte {
+	var data []byte
+	for _, c := range f.Chunks {
+		if c.Stream == stream {
+			data = append(data, c.Data...)
+		}
+	}
+	return data
+}
+
+// DecodeAudio 解码第一个音频流, 返回 PCM (按声道交织)和它的格式
+func (f *File) DecodeAudio(opts ...Option) ([]int16, wav.Format, error) {
+	stream, err := f.AudioStream()
+	if err != nil {
+		return nil, wav.Format{}, err
+	}
+	a := f.Streams[stream].Audio
+	data := f.StreamData(stream)
+
+	switch a.Tag {
+	case wav.FormatALaw, wav.FormatMuLaw:
+		law := g711.ALaw
+		if a.Tag == wav.FormatMuLaw {
+			law = g711.ULaw
+		}
+		return g711.Decode(law, data), wav.PCMFormat(a.Channels, a.SampleRate), nil
+	}
+
+	// PCM 和 G.726 与 WAV 文件的处理相同, 样本数由数据长度决定
+	wf := &wav.File{Format: *a, Samples: -1, Data: data}
+	return wf.PCM(wav.WithPacking(newConfig(opts).packing))
+}
+
+type config struct {
+	packing g726.PackingType
+	types   []string // Read 保存数据的流类型, nil 表示全部
+}
+
+func newConfig(opts []Option) config {
+	c := config{packing: g726.PackingLeft}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Option 用于读取和 G.726 编解码的可选配置
+type Option func(*config)
+
+// WithPacking 设置 G.726 码字的打包方式, 默认为 PackingLeft (与 WAV 相同)
+// 有些摄像机按 PackingRight 打包
+func WithPacking(packing g726.PackingType) Option {
+	return func(c *config) {
+		c.packing = packing
+	}
+}
+
+// WithStreamTypes 设置 Read 只保存这些类型(TypeVideo, TypeAudio 等)的流的数据,
+// 其他流的块仍然出现在 File.Chunks 中, 但 Data 为 nil, 读取时不占用内存.
+// 例如 WithStreamTypes(TypeAudio) 从很大的录像中只取出音频
+func WithStreamTypes(types ...string) Option {
+	return func(c *config) {
+		c.types = append([]string{}, types...)
+	}
+}
+
+// G726Rate 返回音频流的 G.726 码率
+func (s Stream) G726Rate() (g726.Rate, error) {
+	if s.Audio == nil {
+		return 0, ErrNoAudio
+	}
+	return s.Audio.G726Rate()
+}
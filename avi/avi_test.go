@@ -0,0 +1,238 @@
+package avi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/g711"
+	"github.com/general252/g726/wav"
+)
+
+func testSignal(n int) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	return pcm
+}
+
+// writeTestFile 写入 25fps 的视频和 G.726 音频, 每帧 320 个采样点
+func writeTestFile(t *testing.T, w *Writer, frames [][]byte, pcm []int16) {
+	for i, frame := range frames {
+		if err := w.WriteVideo(frame, i%10 == 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteSamples(pcm[i*320 : (i+1)*320]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	var frames [][]byte
+	for i := 0; i < 30; i++ {
+		frame := bytes.Repeat([]byte{byte(i)}, 100+i*7)
+		frames = append(frames, frame)
+	}
+	pcm := testSignal(30 * 320)
+	video := &Video{Handler: "H264", Width: 320, Height: 240, Rate: 25}
+
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		audio, _ := wav.G726Format(rate)
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, video, &audio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, w, frames, pcm)
+
+		f, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.MicroSecPerFrame != 40000 || f.Width != 320 || f.Height != 240 || len(f.Streams) != 2 {
+			t.Fatalf("rate %v: %+v", rate, f)
+		}
+		vs, as := f.Streams[0], f.Streams[1]
+		if vs.Type != TypeVideo || vs.Handler != "H264" || vs.Rate != 25 || vs.Scale != 1 || vs.Length != 30 || vs.Audio != nil {
+			t.Fatalf("video stream %+v", vs)
+		}
+		audioBytes := len(f.StreamData(1))
+		if as.Type != TypeAudio || as.Audio == nil || as.Audio.Tag != wav.FormatG726 || as.Rate != uint32(audio.ByteRate) ||
+			as.Length != uint32(audioBytes/audio.BlockAlign) || audioBytes%audio.BlockAlign != 0 {
+			t.Fatalf("audio stream %+v, %d bytes", as, audioBytes)
+		}
+		if r, _ := as.G726Rate(); r != rate {
+			t.Fatalf("rate %v, want %v", r, rate)
+		}
+
+		var got [][]byte
+		for _, c := range f.Chunks {
+			if c.Stream == 0 {
+				if c.Type != "dc" || c.Key != (len(got)%10 == 0) {
+					t.Fatalf("video chunk %d: type %s key %v", len(got), c.Type, c.Key)
+				}
+				got = append(got, c.Data)
+			} else if c.Type != "wb" || !c.Key {
+				t.Fatalf("audio chunk type %s", c.Type)
+			}
+		}
+		if len(got) != len(frames) {
+			t.Fatalf("%d video frames", len(got))
+		}
+		for i := range frames {
+			if !bytes.Equal(got[i], frames[i]) {
+				t.Fatalf("frame %d differs", i)
+			}
+		}
+
+		decoded, format, err := f.DecodeAudio()
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, _ := g726.New(rate, g726.PackingLeft)
+		dec, _ := g726.New(rate, g726.PackingLeft)
+		want := dec.DecodeV2(enc.EncodeV2(pcm))
+		if format.SampleRate != 8000 || format.Channels != 1 || len(decoded) < len(want) {
+			t.Fatalf("rate %v: %d samples, format %+v", rate, len(decoded), format)
+		}
+		for i := range want {
+			if decoded[i] != want[i] {
+				t.Fatalf("rate %v: sample %d = %d, want %d", rate, i, decoded[i], want[i])
+			}
+		}
+
+		// 只保留音频数据, 视频块的位置和关键帧标记不变
+		audioOnly, err := Read(bytes.NewReader(buf.Bytes()), WithStreamTypes(TypeAudio))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(audioOnly.Chunks) != len(f.Chunks) {
+			t.Fatalf("%d chunks, want %d", len(audioOnly.Chunks), len(f.Chunks))
+		}
+		for i, c := range audioOnly.Chunks {
+			if c.Stream == 0 && c.Data != nil || c.Key != f.Chunks[i].Key {
+				t.Fatalf("chunk %d: %+v", i, c)
+			}
+		}
+		decoded2, _, err := audioOnly.DecodeAudio()
+		if err != nil || len(decoded2) != len(decoded) {
+			t.Fatal(len(decoded2), err)
+		}
+		for i := range decoded {
+			if decoded2[i] != decoded[i] {
+				t.Fatalf("audio-only read: sample %d = %d, want %d", i, decoded2[i], decoded[i])
+			}
+		}
+
+		// 写入文件时在原地补上长度, 结果与写入内存相同
+		name := filepath.Join(t.TempDir(), "test.avi")
+		file, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, _ = NewWriter(file, video, &audio)
+		writeTestFile(t, w, frames, pcm)
+		file.Close()
+		b, _ := os.ReadFile(name)
+		if !bytes.Equal(b, buf.Bytes()) {
+			t.Fatal("seekable and buffered output differ")
+		}
+	}
+}
+
+// TestReadSynthetic 读取手工构造的文件: rec 列表, JUNK, 没有 idx1, μ律音频
+func TestReadSynthetic(t *testing.T) {
+	chunk := func(id string, body []byte) []byte {
+		b := append([]byte(id), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(body)))
+		b = append(b, body...)
+		if len(body)%2 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	list := func(typ string, chunks ...[]byte) []byte {
+		body := []byte(typ)
+		for _, c := range chunks {
+			body = append(body, c...)
+		}
+		return chunk("LIST", body)
+	}
+
+	pcm := testSignal(400)
+	ulaw := g711.Encode(g711.ULaw, pcm)
+	format := wav.Format{Tag: wav.FormatMuLaw, Channels: 1, SampleRate: 8000, ByteRate: 8000, BlockAlign: 1, BitsPerSample: 8}
+	strf, _ := format.MarshalBinary()
+	strh := make([]byte, strhSize)
+	copy(strh, "auds")
+
+	file := chunk("RIFF", append([]byte("AVI "),
+		append(list("hdrl", chunk("avih", make([]byte, avihSize)), list("strl", chunk("strh", strh), chunk("strf", strf))),
+			list("movi",
+				chunk("JUNK", []byte{1, 2, 3}),
+				list("rec ", chunk("00wb", ulaw[:199])),
+				chunk("00wb", ulaw[199:]))...)...))
+
+	f, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Chunks) != 2 || f.Chunks[0].Key {
+		t.Fatalf("%d chunks", len(f.Chunks))
+	}
+	got, _, err := f.DecodeAudio()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := g711.Decode(g711.ULaw, ulaw)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+
+	// 只有视频
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, &Video{Handler: "MJPG", Width: 16, Height: 16, Rate: 30000, Scale: 1001}, nil)
+	w.WriteVideo([]byte{0xFF, 0xD8}, true)
+	w.Close()
+	f, err = Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.DecodeAudio(); !errors.Is(err, ErrNoAudio) {
+		t.Fatal(err)
+	}
+	if f.MicroSecPerFrame != 33366 || f.Streams[0].Scale != 1001 {
+		t.Fatalf("%+v", f)
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00WAVE"))); !errors.Is(err, ErrFormat) {
+		t.Fatal(err)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, nil, nil); err == nil {
+		t.Fatal("no streams accepted")
+	}
+
+	// nBlockAlign 为 0 时 WriteSamples 和 Close 会除以 0
+	audio, _ := wav.G726Format(g726.Rate24kbps)
+	audio.BlockAlign = 0
+	if _, err := NewWriter(&buf, nil, &audio); err == nil {
+		t.Fatal("block align 0 accepted")
+	}
+}
@@ -0,0 +1,361 @@
+package avi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/internal/riff"
+	"github.com/general252/g726/wav"
+)
+
+// Video 视频流的参数, 视频帧的内容不做解析
+type Video struct {
+	Handler       string // 编码的 FourCC, 例如 "H264"
+	Width, Height int
+	Rate, Scale   uint32 // 帧率为 Rate/Scale, Scale 为0时按1处理
+	Format        []byte // strf 的内容, 为空时按 Handler 和宽高生成 BITMAPINFOHEADER
+}
+
+// Writer 写入 AVI 文件, 最多一路视频流(序号0)和一路音频流(有视频时序号为1)
+// 目标不支持 Seek 时数据先缓存在内存中, Close 时一次写出
+type Writer struct {
+	dst io.Writer
+	buf *riff.Buffer
+	rw  *riff.Writer
+
+	video       *Video
+	audio       *wav.Format
+	videoStream int // 没有时为-1
+	audioStream int
+
+	avihPos   int64   // avih 的位置
+	strhPos   []int64 // 各个流 strh 的位置
+	moviPos   int64   // movi 列表类型的位置, idx1 中的偏移相对于它
+	index     []byte
+	lengths   []uint32 // 各个流以 Scale 为单位的长度
+	maxChunk  []uint32
+	moviBytes int64
+
+	enc     *g726.G726_state
+	pending []byte // 不足 nBlockAlign 的音频数据
+}
+
+// NewWriter 创建 Writer 并写入文件头, video 和 audio 可以有一个为 nil
+// audio 为 G.726 格式时可以用 WriteSamples 写入 PCM
+func NewWriter(w io.Writer, video *Video, audio *wav.Format, opts ...Option) (*Writer, error) {
+	if video == nil && audio == nil {
+		return nil, errors.New("avi: no streams")
+	}
+
+	aw := &Writer{dst: w, video: video, audio: audio, videoStream: -1, audioStream: -1}
+	var streams int
+	if video != nil {
+		aw.videoStream = streams
+		streams++
+	}
+	if audio != nil {
+		aw.audioStream = streams
+		streams++
+		if audio.BlockAlign < 1 {
+			return nil, fmt.Errorf("avi: invalid audio block align %d", audio.BlockAlign)
+		}
+		if audio.Tag == wav.FormatG726 {
+			rate, err := audio.G726Rate()
+			if err != nil {
+				return nil, err
+			}
+			if audio.Channels != 1 {
+				return nil, fmt.Errorf("%w: G.726 with %d channels", wav.ErrUnsupported, audio.Channels)
+			}
+			if aw.enc, err = g726.New(rate, newConfig(opts).packing); err != nil {
+				return nil, err
+			}
+		}
+	}
+	aw.lengths = make([]uint32, streams)
+	aw.maxChunk = make([]uint32, streams)
+
+	ws, ok := w.(io.WriteSeeker)
+	if !ok {
+		aw.buf = &riff.Buffer{}
+		ws = aw.buf
+	}
+	rw, err := riff.NewWriter(ws, aviID)
+	if err != nil {
+		return nil, err
+	}
+	aw.rw = rw
+
+	if err := aw.writeHeaders(streams); err != nil {
+		return nil, err
+	}
+
+	if err := rw.StartList(riff.LIST, moviID); err != nil {
+		return nil, err
+	}
+	aw.moviPos = rw.Offset() - 4
+	return aw, nil
+}
+
+func (w *Writer) writeHeaders(streams int) error {
+	rw := w.rw
+	if err := rw.StartList(riff.LIST, hdrlID); err != nil {
+		return err
+	}
+
+	// avih, 帧数等在 Close 时补上
+	avih := make([]byte, avihSize)
+	if v := w.video; v != nil {
+		binary.LittleEndian.PutUint32(avih[0:], uint32(uint64(1000000)*uint64(scale(v))/uint64(max1(v.Rate))))
+		binary.LittleEndian.PutUint32(avih[32:], uint32(v.Width))
+		binary.LittleEndian.PutUint32(avih[36:], uint32(v.Height))
+	}
+	binary.LittleEndian.PutUint32(avih[12:], avifHasIndex)
+	binary.LittleEndian.PutUint32(avih[24:], uint32(streams))
+	if err := rw.StartChunk(avihID); err != nil {
+		return err
+	}
+	w.avihPos = rw.Offset()
+	if _, err := rw.Write(avih); err != nil {
+		return err
+	}
+	if err := rw.EndChunk(); err != nil {
+		return err
+	}
+
+	if v := w.video; v != nil {
+		strh := make([]byte, strhSize)
+		copy(strh[0:], TypeVideo)
+		copy(strh[4:], fourcc(v.Handler))
+		binary.LittleEndian.PutUint32(strh[20:], scale(v))
+		binary.LittleEndian.PutUint32(strh[24:], v.Rate)
+		binary.LittleEndian.PutUint32(strh[40:], 0xFFFFFFFF)
+		binary.LittleEndian.PutUint16(strh[52:], uint16(v.Width))
+		binary.LittleEndian.PutUint16(strh[54:], uint16(v.Height))
+
+		format := v.Format
+		if len(format) == 0 {
+			format = bitmapInfoHeader(v)
+		}
+		if err := w.writeStream(strh, format); err != nil {
+			return err
+		}
+	}
+
+	if a := w.audio; a != nil {
+		strh := make([]byte, strhSize)
+		copy(strh[0:], TypeAudio)
+		binary.LittleEndian.PutUint32(strh[20:], uint32(max1(uint32(a.BlockAlign))))
+		binary.LittleEndian.PutUint32(strh[24:], uint32(a.ByteRate))
+		binary.LittleEndian.PutUint32(strh[40:], 0xFFFFFFFF)
+		binary.LittleEndian.PutUint32(strh[44:], uint32(a.BlockAlign))
+
+		format, err := a.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if err := w.writeStream(strh, format); err != nil {
+			return err
+		}
+	}
+
+	return rw.EndChunk()
+}
+
+func (w *Writer) writeStream(strh, strf []byte) error {
+	rw := w.rw
+	if err := rw.StartList(riff.LIST, strlID); err != nil {
+		return err
+	}
+	if err := rw.StartChunk(strhID); err != nil {
+		return err
+	}
+	w.strhPos = append(w.strhPos, rw.Offset())
+	if _, err := rw.Write(strh); err != nil {
+		return err
+	}
+	if err := rw.EndChunk(); err != nil {
+		return err
+	}
+	if err := rw.StartChunk(strfID); err != nil {
+		return err
+	}
+	if _, err := rw.Write(strf); err != nil {
+		return err
+	}
+	if err := rw.EndChunk(); err != nil {
+		return err
+	}
+	return rw.EndChunk()
+}
+
+func scale(v *Video) uint32 {
+	return max1(v.Scale)
+}
+
+func max1(v uint32) uint32 {
+	if v == 0 {
+		return 1
+	}
+	return v
+}
+
+func fourcc(s string) []byte {
+	b := []byte("    ")
+	copy(b, s)
+	return b
+}
+
+// bitmapInfoHeader 生成 BITMAPINFOHEADER
+func bitmapInfoHeader(v *Video) []byte {
+	b := make([]byte, 40)
+	binary.LittleEndian.PutUint32(b[0:], 40)
+	binary.LittleEndian.PutUint32(b[4:], uint32(v.Width))
+	binary.LittleEndian.PutUint32(b[8:], uint32(v.Height))
+	binary.LittleEndian.PutUint16(b[12:], 1)
+	binary.LittleEndian.PutUint16(b[14:], 24)
+	copy(b[16:], fourcc(v.Handler))
+	binary.LittleEndian.PutUint32(b[20:], uint32(v.Width*v.Height*3))
+	return b
+}
+
+// WriteVideo 写入一个视频帧, key 表示关键帧
+func (w *Writer) WriteVideo(frame []byte, key bool) error {
+	if w.videoStream < 0 {
+		return errors.New("avi: no video stream")
+	}
+	if err := w.writeChunk(w.videoStream, "dc", frame, key); err != nil {
+		return err
+	}
+	w.lengths[w.videoStream]++
+	return nil
+}
+
+// WriteAudio 写入已经编码的音频数据, 长度应为 nBlockAlign 的整数倍
+func (w *Writer) WriteAudio(data []byte) error {
+	if w.audioStream < 0 {
+		return ErrNoAudio
+	}
+	if err := w.writeChunk(w.audioStream, "wb", data, true); err != nil {
+		return err
+	}
+	w.lengths[w.audioStream] += uint32(len(data) / int(max1(uint32(w.audio.BlockAlign))))
+	return nil
+}
+
+// WriteSamples 把单声道 PCM 编码为 G.726 后写入一个音频块
+// 不足 nBlockAlign 的部分留到下一次写入
+func (w *Writer) WriteSamples(pcm []int16) error {
+	if w.enc == nil {
+		return fmt.Errorf("%w: audio stream is not G.726", wav.ErrUnsupported)
+	}
+	w.pending = w.enc.AppendEncode(w.pending, pcm)
+	n := len(w.pending) / w.audio.BlockAlign * w.audio.BlockAlign
+	if n == 0 {
+		return nil
+	}
+	if err := w.WriteAudio(w.pending[:n]); err != nil {
+		return err
+	}
+	w.pending = append(w.pending[:0], w.pending[n:]...)
+	return nil
+}
+
+func (w *Writer) writeChunk(stream int, typ string, data []byte, key bool) error {
+	id := riff.FourCC{'0' + byte(stream/10), '0' + byte(stream%10), typ[0], typ[1]}
+	offset := w.rw.Offset() - w.moviPos
+	if err := w.rw.StartChunk(id); err != nil {
+		return err
+	}
+	if _, err := w.rw.Write(data); err != nil {
+		return err
+	}
+	if err := w.rw.EndChunk(); err != nil {
+		return err
+	}
+
+	var flags uint32
+	if key {
+		flags = aviifKey
+	}
+	w.index = append(w.index, id[:]...)
+	w.index = binary.LittleEndian.AppendUint32(w.index, flags)
+	w.index = binary.LittleEndian.AppendUint32(w.index, uint32(offset))
+	w.index = binary.LittleEndian.AppendUint32(w.index, uint32(len(data)))
+
+	if uint32(len(data)) > w.maxChunk[stream] {
+		w.maxChunk[stream] = uint32(len(data))
+	}
+	w.moviBytes += int64(len(data))
+	return nil
+}
+
+// Close 写出剩余的音频, idx1 索引, 并补上帧数和各个流的长度
+func (w *Writer) Close() error {
+	if w.enc != nil {
+		w.pending = w.enc.AppendFlush(w.pending)
+		for len(w.pending)%w.audio.BlockAlign != 0 {
+			w.pending = append(w.pending, 0)
+		}
+		if len(w.pending) > 0 {
+			if err := w.WriteAudio(w.pending); err != nil {
+				return err
+			}
+			w.pending = w.pending[:0]
+		}
+	}
+
+	rw := w.rw
+	if err := rw.EndChunk(); err != nil { // movi
+		return err
+	}
+	if err := rw.StartChunk(idx1ID); err != nil {
+		return err
+	}
+	if _, err := rw.Write(w.index); err != nil {
+		return err
+	}
+	if err := rw.EndChunk(); err != nil {
+		return err
+	}
+
+	var maxChunk uint32
+	for i, pos := range w.strhPos {
+		if err := rw.Patch(pos+32, w.lengths[i]); err != nil {
+			return err
+		}
+		if err := rw.Patch(pos+36, w.maxChunk[i]); err != nil {
+			return err
+		}
+		if w.maxChunk[i] > maxChunk {
+			maxChunk = w.maxChunk[i]
+		}
+	}
+	if w.videoStream >= 0 {
+		if err := rw.Patch(w.avihPos+16, w.lengths[w.videoStream]); err != nil {
+			return err
+		}
+		if v := w.video; v.Rate > 0 && w.lengths[w.videoStream] > 0 {
+			// 平均码率
+			perSec := uint64(w.moviBytes) * uint64(v.Rate) / (uint64(w.lengths[w.videoStream]) * uint64(scale(v)))
+			if err := rw.Patch(w.avihPos+4, uint32(perSec)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rw.Patch(w.avihPos+28, maxChunk); err != nil {
+		return err
+	}
+	if err := rw.Close(); err != nil {
+		return err
+	}
+
+	if w.buf != nil {
+		_, err := w.dst.Write(w.buf.Bytes())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package g726
+
+import (
+	"testing"
+)
+
+var benchPackings = []PackingType{PackingNone, PackingLeft, PackingRight}
+
+func packingName(p PackingType) string {
+	switch p {
+	case PackingNone:
+		return "none"
+	case PackingLeft:
+		return "left"
+	case PackingRight:
+		return "right"
+	default:
+		return ""
+	}
+}
+
+// 每次处理一秒的音频(8000个采样点), 输出 samples/s
+func BenchmarkEncode(b *testing.B) {
+	pcm := testSignal(8000)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		for _, packing := range benchPackings {
+			b.Run(rate.String()+"/"+packingName(packing), func(b *testing.B) {
+				enc := G726_init_state(rate, packing)
+				data := make([]byte, 0, len(pcm)+8)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					data = enc.AppendEncode(data[:0], pcm)
+				}
+				b.ReportMetric(float64(b.N*len(pcm))/b.Elapsed().Seconds(), "samples/s")
+			})
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	pcm := testSignal(8000)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		for _, packing := range benchPackings {
+			b.Run(rate.String()+"/"+packingName(packing), func(b *testing.B) {
+				data := G726_init_state(rate, packing).EncodeV2(pcm)
+				dec := G726_init_state(rate, packing)
+				out := make([]int16, 0, len(pcm)+8)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					out = dec.AppendDecode(out[:0], data)
+				}
+				b.ReportMetric(float64(b.N*len(out))/b.Elapsed().Seconds(), "samples/s")
+			})
+		}
+	}
+}
+
+// 20ms 帧的编码和解码, 对应媒体服务器的典型用法, 单独编码或解码见 append_test.go
+func BenchmarkFrame(b *testing.B) {
+	pcm := testSignal(160)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		b.Run(rate.String(), func(b *testing.B) {
+			enc := G726_init_state(rate, PackingRight)
+			dec := G726_init_state(rate, PackingRight)
+			data := make([]byte, 0, 200)
+			out := make([]int16, 0, 200)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data = enc.AppendEncode(data[:0], pcm)
+				out = dec.AppendDecode(out[:0], data)
+			}
+		})
+	}
+}
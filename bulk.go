@@ -0,0 +1,191 @@
+package g726
+
+// 批量编解码时每次处理的采样点数
+const blockSamples = 256
+
+// encode_block 按速率编码一组采样点, 每个码字占一个字节
+func (state_ptr *G726_state) encode_block(codes []byte, pcm []int16) {
+	s := state_ptr
+	codes = codes[:len(pcm)]
+
+	switch s.rate {
+	case Rate16kbps:
+		for i, v := range pcm {
+			codes[i] = byte(s.g726_16_encoder(int(v)))
+		}
+	case Rate24kbps:
+		for i, v := range pcm {
+			codes[i] = byte(s.g726_24_encoder(int(v)))
+		}
+	case Rate32kbps:
+		for i, v := range pcm {
+			codes[i] = byte(s.g726_32_encoder(int(v)))
+		}
+	case Rate40kbps:
+		for i, v := range pcm {
+			codes[i] = byte(s.g726_40_encoder(int(v)))
+		}
+	}
+}
+
+// decode_block 按速率解码一组码字, 结果写入 pcm
+func (state_ptr *G726_state) decode_block(pcm []int16, codes []byte) {
+	s := state_ptr
+	pcm = pcm[:len(codes)]
+
+	switch s.rate {
+	case Rate16kbps:
+		for i, c := range codes {
+			pcm[i] = int16(s.g726_16_decoder(int(c)))
+		}
+	case Rate24kbps:
+		for i, c := range codes {
+			pcm[i] = int16(s.g726_24_decoder(int(c)))
+		}
+	case Rate32kbps:
+		for i, c := range codes {
+			pcm[i] = int16(s.g726_32_decoder(int(c)))
+		}
+	case Rate40kbps:
+		for i, c := range codes {
+			pcm[i] = int16(s.g726_40_decoder(int(c)))
+		}
+	}
+}
+
+// pack 把码字按 packing 打包追加到 dst
+// 8个码字正好占 bits_per_sample 个字节, 所以每次处理8个码字, 剩余的逐个处理
+func (state_ptr *G726_state) pack(dst []byte, codes []byte) []byte {
+	s := state_ptr
+	if s.packing == PackingNone {
+		return append(dst, codes...)
+	}
+
+	bits := uint(s.bits_per_sample)
+	bitstream := s.bs.bitstream
+	residue := uint(s.bs.residue)
+
+	switch s.packing {
+	case PackingRight:
+		for ; len(codes) >= 8; codes = codes[8:] {
+			var group uint64
+			for k := 7; k >= 0; k-- {
+				group = group<<bits | uint64(codes[k])
+			}
+			acc := uint64(bitstream) | group<<residue
+			for j := uint(0); j < bits; j++ {
+				dst = append(dst, byte(acc>>(8*j)))
+			}
+			bitstream = uint32(acc >> (8 * bits))
+		}
+		for _, code := range codes {
+			bitstream |= uint32(code) << residue
+			residue += bits
+			if residue >= 8 {
+				dst = append(dst, byte(bitstream))
+				bitstream >>= 8
+				residue -= 8
+			}
+		}
+	case PackingLeft:
+		for ; len(codes) >= 8; codes = codes[8:] {
+			var group uint64
+			for k := 0; k < 8; k++ {
+				group = group<<bits | uint64(codes[k])
+			}
+			acc := uint64(bitstream)<<(8*bits) | group
+			for j := bits; j > 0; j-- {
+				dst = append(dst, byte(acc>>(residue+8*(j-1))))
+			}
+			bitstream = uint32(acc)
+		}
+		for _, code := range codes {
+			bitstream = bitstream<<bits | uint32(code)
+			residue += bits
+			if residue >= 8 {
+				dst = append(dst, byte(bitstream>>(residue-8)))
+				residue -= 8
+			}
+		}
+	}
+
+	s.bs.bitstream = bitstream
+	s.bs.residue = int32(residue)
+	return dst
+}
+
+// unpack 从 data 中解出最多 len(codes) 个码字, 返回码字个数和使用的字节数
+// 只在需要时读取新的字节, 与逐个解码的结果完全一致
+func (state_ptr *G726_state) unpack(codes []byte, data []byte) (n int, used int) {
+	s := state_ptr
+	if s.packing == PackingNone {
+		n = copy(codes, data)
+		return n, n
+	}
+
+	bits := uint(s.bits_per_sample)
+	mask := uint64(1)<<bits - 1
+	bitstream := s.bs.bitstream
+	residue := uint(s.bs.residue)
+
+	switch s.packing {
+	case PackingRight:
+		for n+8 <= len(codes) && used+int(bits) <= len(data) {
+			var group uint64
+			for j := uint(0); j < bits; j++ {
+				group |= uint64(data[used+int(j)]) << (8 * j)
+			}
+			acc := uint64(bitstream) | group<<residue
+			for k := uint(0); k < 8; k++ {
+				codes[n+int(k)] = byte(acc >> (k * bits) & mask)
+			}
+			bitstream = uint32(acc >> (8 * bits))
+			n += 8
+			used += int(bits)
+		}
+		for n < len(codes) {
+			if residue < bits {
+				if used >= len(data) {
+					break
+				}
+				bitstream |= uint32(data[used]) << residue
+				used++
+				residue += 8
+			}
+			codes[n] = byte(uint64(bitstream) & mask)
+			bitstream >>= bits
+			residue -= bits
+			n++
+		}
+	case PackingLeft:
+		for n+8 <= len(codes) && used+int(bits) <= len(data) {
+			acc := uint64(bitstream)
+			for j := uint(0); j < bits; j++ {
+				acc = acc<<8 | uint64(data[used+int(j)])
+			}
+			for k := uint(0); k < 8; k++ {
+				codes[n+int(k)] = byte(acc >> (residue + (7-k)*bits) & mask)
+			}
+			bitstream = uint32(acc)
+			n += 8
+			used += int(bits)
+		}
+		for n < len(codes) {
+			if residue < bits {
+				if used >= len(data) {
+					break
+				}
+				bitstream = bitstream<<8 | uint32(data[used])
+				used++
+				residue += 8
+			}
+			codes[n] = byte(uint64(bitstream>>(residue-bits)) & mask)
+			residue -= bits
+			n++
+		}
+	}
+
+	s.bs.bitstream = bitstream
+	s.bs.residue = int32(residue)
+	return n, used
+}
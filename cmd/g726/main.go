@@ -0,0 +1,297 @@
+// Command g726 编码, 解码和转码 G.726 音频
+//
+// 用法:
+//
+//	g726 encode [flags] [input [output]]     PCM -> G.726
+//	g726 decode [flags] [input [output]]     G.726 -> PCM
+//	g726 transcode [flags] [input [output]]  G.726 -> G.726, 改变速率, 打包方式或文件格式
+//
+// input 和 output 省略或为 "-" 时使用标准输入和标准输出, raw 数据可以在管道中流式处理.
+// 文件格式(raw, wav, au)由 -in-format 和 -out-format 指定, 默认按扩展名判断(.wav, .au, .snd), 其他为 raw.
+// raw PCM 的采样格式由 -pcm 指定(s16le, s16be, u8, s24le, s32le, f32le, f64le).
+// WAV 和 AU 文件中 G.726 的速率由文件头决定.
+//
+// 编码时多声道的输入先混合为单声道, 采样率不是 8000Hz 时先重采样.
+//
+// 退出码: 0 成功, 1 处理出错, 2 参数错误.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/pcm"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// 文件格式
+const (
+	formatRaw = "raw"
+	formatWAV = "wav"
+	formatAU  = "au"
+)
+
+const usage = `usage: g726 <command> [flags] [input [output]]
+
+commands:
+  encode     encode PCM to G.726
+  decode     decode G.726 to PCM
+  transcode  convert G.726 to another rate, packing or file format
+
+Run "g726 <command> -h" for the flags of a command.
+Input and output default to stdin and stdout ("-").
+`
+
+// errUsage 表示参数错误, 退出码为 exitUsage
+var errUsage = errors.New("invalid argument")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// options 命令行参数
+type options struct {
+	rate      int    // 输出(encode, transcode)或输入(decode)的速率, kbit/s
+	packing   string // 同上的打包方式
+	inRate    int    // transcode 的 raw 输入速率
+	inPacking string // transcode 的 raw 输入打包方式
+
+	inFormat   string
+	outFormat  string
+	pcmFormat  string
+	sampleRate int
+	channels   int
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	var o options
+	cmd := args[0]
+	fs := flag.NewFlagSet("g726 "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&o.inFormat, "in-format", "", "input file format: raw, wav or au (default from the file extension)")
+	fs.StringVar(&o.outFormat, "out-format", "", "output file format: raw, wav or au (default from the file extension)")
+
+	switch cmd {
+	case "encode":
+		fs.IntVar(&o.rate, "rate", 32, "G.726 bit rate in kbit/s: 16, 24, 32 or 40")
+		fs.StringVar(&o.packing, "packing", "", "G.726 packing: left, right or none (default right for raw and au, left for wav)")
+		fs.StringVar(&o.pcmFormat, "pcm", "s16le", "sample format of raw PCM input")
+		fs.IntVar(&o.sampleRate, "sample-rate", 8000, "sample rate of raw PCM input")
+		fs.IntVar(&o.channels, "channels", 1, "channel count of raw PCM input")
+	case "decode":
+		fs.IntVar(&o.rate, "rate", 32, "bit rate of raw G.726 input in kbit/s: 16, 24, 32 or 40")
+		fs.StringVar(&o.packing, "packing", "", "packing of the G.726 input: left, right or none (default right for raw and au, left for wav)")
+		fs.StringVar(&o.pcmFormat, "pcm", "s16le", "sample format of raw PCM output")
+		fs.IntVar(&o.sampleRate, "sample-rate", 8000, "sample rate of the PCM output")
+		fs.IntVar(&o.channels, "channels", 1, "channel count of the PCM output")
+	case "transcode":
+		fs.IntVar(&o.inRate, "in-rate", 32, "bit rate of raw G.726 input in kbit/s")
+		fs.StringVar(&o.inPacking, "in-packing", "", "packing of the G.726 input (default right for raw and au, left for wav)")
+		fs.IntVar(&o.rate, "rate", 32, "output bit rate in kbit/s: 16, 24, 32 or 40")
+		fs.StringVar(&o.packing, "packing", "", "output packing (default right for raw and au, left for wav)")
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "g726: unknown command %q\n\n%s", cmd, usage)
+		return exitUsage
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 2 {
+		fmt.Fprintf(stderr, "g726 %s: too many arguments\n", cmd)
+		return exitUsage
+	}
+	input, output := fs.Arg(0), fs.Arg(1)
+
+	if err := execute(cmd, &o, input, output, stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "g726 %s: %v\n", cmd, err)
+		if errors.Is(err, errUsage) {
+			return exitUsage
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+func execute(cmd string, o *options, input, output string, stdin io.Reader, stdout io.Writer) (err error) {
+	if o.inFormat, err = fileFormat(o.inFormat, input); err != nil {
+		return err
+	}
+	if o.outFormat, err = fileFormat(o.outFormat, output); err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(input, stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	var src source
+	switch cmd {
+	case "encode":
+		src, err = openPCMSource(r, o)
+	case "decode":
+		src, err = openG726Source(r, o.inFormat, o.rate, o.packing)
+	case "transcode":
+		src, err = openG726Source(r, o.inFormat, o.inRate, o.inPacking)
+	}
+	if err != nil {
+		return err
+	}
+
+	w, closeOutput, err := openOutput(output, stdout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = closeOutput(err)
+	}()
+
+	var dst sink
+	if cmd == "decode" {
+		dst, err = openPCMSink(w, o)
+	} else {
+		dst, err = openG726Sink(w, o.outFormat, o.rate, o.packing)
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		samples, err := src.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := dst.WriteSamples(samples); err != nil {
+			return err
+		}
+	}
+	return dst.Close()
+}
+
+// fileFormat 返回文件格式, 没有指定时按扩展名判断
+func fileFormat(flagValue, name string) (string, error) {
+	switch strings.ToLower(flagValue) {
+	case formatRaw, formatWAV, formatAU:
+		return strings.ToLower(flagValue), nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: unknown file format %q", errUsage, flagValue)
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".wav", ".wave":
+		return formatWAV, nil
+	case ".au", ".snd":
+		return formatAU, nil
+	default:
+		return formatRaw, nil
+	}
+}
+
+// parseRate 把 kbit/s 转换为 g726.Rate
+func parseRate(kbps int) (g726.Rate, error) {
+	switch kbps {
+	case 16:
+		return g726.Rate16kbps, nil
+	case 24:
+		return g726.Rate24kbps, nil
+	case 32:
+		return g726.Rate32kbps, nil
+	case 40:
+		return g726.Rate40kbps, nil
+	default:
+		return 0, fmt.Errorf("%w: rate %d, want 16, 24, 32 or 40", errUsage, kbps)
+	}
+}
+
+// parsePacking 解析打包方式, 没有指定时使用文件格式的默认值
+func parsePacking(name, format string) (g726.PackingType, error) {
+	switch strings.ToLower(name) {
+	case "":
+		if format == formatWAV {
+			return g726.PackingLeft, nil
+		}
+		return g726.PackingRight, nil
+	case "left":
+		return g726.PackingLeft, nil
+	case "right":
+		return g726.PackingRight, nil
+	case "none":
+		return g726.PackingNone, nil
+	default:
+		return 0, fmt.Errorf("%w: packing %q, want left, right or none", errUsage, name)
+	}
+}
+
+func parsePCMFormat(name string) (pcm.Format, error) {
+	f, err := pcm.ParseFormat(name)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return f, nil
+}
+
+func openInput(name string, stdin io.Reader) (io.Reader, func(), error) {
+	if name == "" || name == "-" {
+		return stdin, func() {}, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// openOutput 打开输出, 返回的 close 函数在处理出错时删除不完整的输出文件
+func openOutput(name string, stdout io.Writer) (io.Writer, func(error) error, error) {
+	if name == "" || name == "-" {
+		if f, ok := stdout.(*os.File); ok {
+			// 管道不支持 Seek, 只有普通文件才能让 wav 和 au 回填文件头
+			if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+				return struct{ io.Writer }{f}, func(err error) error { return err }, nil
+			}
+		}
+		return stdout, func(err error) error { return err }, nil
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func(err error) error {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(name)
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/au"
+	"github.com/general252/g726/pcm"
+	"github.com/general252/g726/wav"
+)
+
+func tone(n, sampleRate int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+// runCmd 运行命令, 返回退出码, 标准输出和标准错误
+func runCmd(t *testing.T, stdin []byte, args ...string) (int, []byte, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, bytes.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.Bytes(), stderr.String()
+}
+
+func TestEncodeDecodeRaw(t *testing.T) {
+	samples := tone(1003, 8000)
+	input, _ := pcm.Encode(pcm.S16LE, samples)
+
+	code, out, stderr := runCmd(t, input, "encode", "-rate", "24")
+	if code != exitOK {
+		t.Fatal(code, stderr)
+	}
+	enc := g726.G726_init_state(g726.Rate24kbps, g726.PackingRight)
+	want := enc.AppendFlush(enc.EncodeV2(samples))
+	if !bytes.Equal(out, want) {
+		t.Fatalf("encoded %d bytes, want %d", len(out), len(want))
+	}
+
+	code, decoded, stderr := runCmd(t, out, "decode", "-rate", "24", "-pcm", "f32le")
+	if code != exitOK {
+		t.Fatal(code, stderr)
+	}
+	wantPCM, _ := pcm.Encode(pcm.F32LE, g726.G726_init_state(g726.Rate24kbps, g726.PackingRight).DecodeV2(want))
+	if !bytes.Equal(decoded, wantPCM) {
+		t.Fatalf("decoded %d bytes, want %d", len(decoded), len(wantPCM))
+	}
+}
+
+func TestContainers(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.wav")
+	encoded := filepath.Join(dir, "out.au")
+	decoded := filepath.Join(dir, "decoded.wav")
+
+	// 16kHz 立体声输入, 编码前混合为单声道并重采样
+	f, _ := os.Create(in)
+	if err := wav.WritePCM(f, g726.Upmix(tone(16000, 16000), 2), 2, 16000); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if code, _, stderr := runCmd(t, nil, "encode", "-rate", "40", in, encoded); code != exitOK {
+		t.Fatal(code, stderr)
+	}
+	af, err := os.Open(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := au.Read(af)
+	af.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Encoding != au.EncodingG723_5 || file.SampleRate != 8000 || len(file.Data) != 8000*5/8 {
+		t.Fatalf("encoding %d, rate %d, %d bytes", file.Encoding, file.SampleRate, len(file.Data))
+	}
+
+	if code, _, stderr := runCmd(t, nil, "decode", "-sample-rate", "16000", encoded, decoded); code != exitOK {
+		t.Fatal(code, stderr)
+	}
+	wf, _ := os.Open(decoded)
+	samples, format, err := wav.ReadPCM(wf)
+	wf.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format.SampleRate != 16000 || format.Channels != 1 || len(samples) != 16000 {
+		t.Fatalf("%d Hz, %d channels, %d samples", format.SampleRate, format.Channels, len(samples))
+	}
+
+	// 重采样和编解码之后仍然接近原信号
+	ref := tone(16000, 16000)
+	var signal, noise float64
+	for i := 1000; i < 15000; i++ {
+		d := float64(samples[i]) - float64(ref[i])
+		signal += float64(ref[i]) * float64(ref[i])
+		noise += d * d
+	}
+	if snr := 10 * math.Log10(signal/noise); snr < 20 {
+		t.Fatalf("SNR %.1f dB", snr)
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	var input bytes.Buffer
+	if err := wav.WriteG726(&input, tone(800, 8000), g726.Rate32kbps); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, stderr := runCmd(t, input.Bytes(), "transcode", "-in-format", "wav", "-rate", "16", "-packing", "left")
+	if code != exitOK {
+		t.Fatal(code, stderr)
+	}
+
+	file, _ := wav.Read(bytes.NewReader(input.Bytes()))
+	decoded, _, _ := file.PCM()
+	enc := g726.G726_init_state(g726.Rate16kbps, g726.PackingLeft)
+	if want := enc.EncodeV2(decoded); !bytes.Equal(out, want) {
+		t.Fatalf("transcoded %d bytes, want %d", len(out), len(want))
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.raw")
+	output := filepath.Join(dir, "out.raw")
+
+	tests := []struct {
+		args  []string
+		stdin []byte
+		code  int
+		msg   string
+	}{
+		{nil, nil, exitUsage, "usage"},
+		{[]string{"play"}, nil, exitUsage, "unknown command"},
+		{[]string{"encode", "-rate", "20"}, nil, exitUsage, "rate 20"},
+		{[]string{"encode", "-pcm", "s8"}, nil, exitUsage, "unknown sample format"},
+		{[]string{"decode", "-packing", "middle"}, nil, exitUsage, "packing"},
+		{[]string{"encode", "-bogus"}, nil, exitUsage, "not defined"},
+		{[]string{"encode", "a", "b", "c"}, nil, exitUsage, "too many"},
+		{[]string{"decode", missing}, nil, exitError, "no such file"},
+		{[]string{"encode", "-", output}, []byte{1, 2, 3}, exitError, "incomplete sample"},
+		{[]string{"decode", "-in-format", "wav"}, []byte("RIFF"), exitError, ""},
+		{[]string{"encode", "-h"}, nil, exitOK, "-sample-rate"},
+	}
+	for _, tt := range tests {
+		code, _, stderr := runCmd(t, tt.stdin, tt.args...)
+		if code != tt.code || !strings.Contains(stderr, tt.msg) {
+			t.Errorf("%q: exit %d, want %d: %s", tt.args, code, tt.code, stderr)
+		}
+	}
+
+	// 出错时不留下不完整的输出文件
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
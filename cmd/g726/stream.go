@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/au"
+	"github.com/general252/g726/pcm"
+	"github.com/general252/g726/resample"
+	"github.com/general252/g726/wav"
+)
+
+// 每次读取的采样点数(每声道)
+const chunkSamples = 4096
+
+// source 产生 PCM 采样点, 结束时返回 io.EOF
+type source interface {
+	Read() ([]int16, error)
+}
+
+// sink 接收 8000Hz 单声道的 PCM 采样点
+type sink interface {
+	WriteSamples(pcm []int16) error
+	Close() error
+}
+
+// memorySource 一次返回全部采样点, 用于 wav 和 au 输入
+type memorySource struct {
+	pcm  []int16
+	done bool
+}
+
+func (s *memorySource) Read() ([]int16, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	s.done = true
+	return s.pcm, nil
+}
+
+// rawPCMSource 流式读取 raw PCM, 不完整的采样时刻保留到下次读取
+type rawPCMSource struct {
+	r      io.Reader
+	format pcm.Format
+	frame  int // 每个采样时刻的字节数
+	buf    []byte
+	rest   int // buf 开头保留的字节数
+	pcm    []int16
+}
+
+func (s *rawPCMSource) Read() ([]int16, error) {
+	for {
+		n, err := s.r.Read(s.buf[s.rest:])
+		n += s.rest
+		whole := n - n%s.frame
+		if whole > 0 {
+			s.pcm, _ = pcm.AppendDecode(s.pcm[:0], s.format, s.buf[:whole])
+			s.rest = copy(s.buf, s.buf[whole:n])
+			return s.pcm, nil
+		}
+		s.rest = n
+
+		if err == io.EOF && s.rest > 0 {
+			return nil, fmt.Errorf("input ends with an incomplete sample")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rawG726Source 流式读取并解码 raw G.726
+type rawG726Source struct {
+	r     io.Reader
+	state *g726.G726_state
+	buf   []byte
+	pcm   []int16
+}
+
+func (s *rawG726Source) Read() ([]int16, error) {
+	n, err := s.r.Read(s.buf)
+	if n > 0 {
+		s.pcm = s.state.AppendDecode(s.pcm[:0], s.buf[:n])
+		return s.pcm, nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return nil, err
+}
+
+// narrowbandSource 把任意声道数和采样率的 PCM 转换为 8000Hz 单声道
+type narrowbandSource struct {
+	src      source
+	channels int
+	rs       *resample.Resampler
+	flushed  bool
+}
+
+func (s *narrowbandSource) Read() ([]int16, error) {
+	samples, err := s.src.Read()
+	if err == io.EOF && s.rs != nil && !s.flushed {
+		s.flushed = true
+		return s.rs.Flush(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.channels > 1 {
+		samples = g726.Downmix(samples, s.channels)
+	}
+	if s.rs != nil {
+		samples = s.rs.Process(samples)
+	}
+	return samples, nil
+}
+
+// openPCMSource 打开 encode 的 PCM 输入, 输出为 8000Hz 单声道
+func openPCMSource(r io.Reader, o *options) (source, error) {
+	var src source
+	channels, sampleRate := o.channels, o.sampleRate
+
+	switch o.inFormat {
+	case formatRaw:
+		f, err := parsePCMFormat(o.pcmFormat)
+		if err != nil {
+			return nil, err
+		}
+		if channels < 1 || sampleRate <= 0 {
+			return nil, fmt.Errorf("%w: channel count %d, sample rate %d", errUsage, channels, sampleRate)
+		}
+		frame := f.BytesPerSample() * channels
+		src = &rawPCMSource{r: r, format: f, frame: frame, buf: make([]byte, chunkSamples*frame)}
+	case formatWAV:
+		samples, format, err := wav.ReadPCM(r)
+		if err != nil {
+			return nil, err
+		}
+		src = &memorySource{pcm: samples}
+		channels, sampleRate = format.Channels, format.SampleRate
+	case formatAU:
+		file, err := au.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		samples, err := file.PCM()
+		if err != nil {
+			return nil, err
+		}
+		src = &memorySource{pcm: samples}
+		channels, sampleRate = file.Channels, file.SampleRate
+	}
+
+	s := &narrowbandSource{src: src, channels: channels}
+	if sampleRate != 8000 {
+		rs, err := resample.New(sampleRate, 8000, 1)
+		if err != nil {
+			return nil, err
+		}
+		s.rs = rs
+	}
+	return s, nil
+}
+
+// openG726Source 打开 G.726 输入并解码, raw 输入使用 kbps 和 packing, wav 和 au 的速率由文件头决定
+func openG726Source(r io.Reader, format string, kbps int, packing string) (source, error) {
+	p, err := parsePacking(packing, format)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case formatWAV:
+		file, err := wav.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		if file.Tag != wav.FormatG726 {
+			return nil, fmt.Errorf("input is not G.726 (format tag %#04x)", file.Tag)
+		}
+		samples, _, err := file.PCM(wav.WithPacking(p))
+		if err != nil {
+			return nil, err
+		}
+		return &memorySource{pcm: samples}, nil
+	case formatAU:
+		file, err := au.Read(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.G726Rate(); err != nil {
+			return nil, fmt.Errorf("input is not G.726: %w", err)
+		}
+		samples, err := file.PCM(au.WithPacking(p))
+		if err != nil {
+			return nil, err
+		}
+		return &memorySource{pcm: samples}, nil
+	}
+
+	rate, err := parseRate(kbps)
+	if err != nil {
+		return nil, err
+	}
+	state, err := g726.New(rate, p)
+	if err != nil {
+		return nil, err
+	}
+	return &rawG726Source{r: r, state: state, buf: make([]byte, chunkSamples)}, nil
+}
+
+// rawSink 把采样点转换后写入 bufio.Writer
+type rawSink struct {
+	w      *bufio.Writer
+	encode func(dst []byte, pcm []int16) []byte
+	flush  func(dst []byte) []byte
+	buf    []byte
+}
+
+func (s *rawSink) WriteSamples(pcm []int16) error {
+	s.buf = s.encode(s.buf[:0], pcm)
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *rawSink) Close() error {
+	if s.flush != nil {
+		if _, err := s.w.Write(s.flush(s.buf[:0])); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+// convertSink 把 8000Hz 单声道的采样点重采样和复制到多个声道后写入下一级
+type convertSink struct {
+	dst      sink
+	channels int
+	rs       *resample.Resampler
+}
+
+func (s *convertSink) WriteSamples(samples []int16) error {
+	if s.rs != nil {
+		samples = s.rs.Process(samples)
+	}
+	if s.channels > 1 {
+		samples = g726.Upmix(samples, s.channels)
+	}
+	return s.dst.WriteSamples(samples)
+}
+
+func (s *convertSink) Close() error {
+	if s.rs != nil {
+		samples := s.rs.Flush()
+		if s.channels > 1 {
+			samples = g726.Upmix(samples, s.channels)
+		}
+		if err := s.dst.WriteSamples(samples); err != nil {
+			return err
+		}
+	}
+	return s.dst.Close()
+}
+
+// openPCMSink 打开 decode 的 PCM 输出
+func openPCMSink(w io.Writer, o *options) (sink, error) {
+	if o.channels < 1 || o.sampleRate <= 0 {
+		return nil, fmt.Errorf("%w: channel count %d, sample rate %d", errUsage, o.channels, o.sampleRate)
+	}
+
+	var dst sink
+	switch o.outFormat {
+	case formatRaw:
+		f, err := parsePCMFormat(o.pcmFormat)
+		if err != nil {
+			return nil, err
+		}
+		dst = &rawSink{
+			w: bufio.NewWriter(w),
+			encode: func(dst []byte, samples []int16) []byte {
+				dst, _ = pcm.AppendEncode(dst, f, samples)
+				return dst
+			},
+		}
+	case formatWAV:
+		ww, err := wav.NewWriter(w, wav.PCMFormat(o.channels, o.sampleRate))
+		if err != nil {
+			return nil, err
+		}
+		dst = ww
+	case formatAU:
+		aw, err := au.NewWriter(w, au.Header{Encoding: au.EncodingLinear16, SampleRate: o.sampleRate, Channels: o.channels})
+		if err != nil {
+			return nil, err
+		}
+		dst = aw
+	}
+
+	if o.sampleRate == 8000 && o.channels == 1 {
+		return dst, nil
+	}
+	s := &convertSink{dst: dst, channels: o.channels}
+	if o.sampleRate != 8000 {
+		rs, err := resample.New(8000, o.sampleRate, 1)
+		if err != nil {
+			return nil, err
+		}
+		s.rs = rs
+	}
+	return s, nil
+}
+
+// openG726Sink 打开 G.726 输出, 编码 8000Hz 单声道的采样点
+func openG726Sink(w io.Writer, format string, kbps int, packing string) (sink, error) {
+	rate, err := parseRate(kbps)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePacking(packing, format)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case formatWAV:
+		f, err := wav.G726Format(rate)
+		if err != nil {
+			return nil, err
+		}
+		return wav.NewWriter(w, f, wav.WithPacking(p))
+	case formatAU:
+		encoding, err := au.EncodingForRate(rate)
+		if err != nil {
+			return nil, err
+		}
+		return au.NewWriter(w, au.Header{Encoding: encoding, SampleRate: 8000, Channels: 1}, au.WithPacking(p))
+	}
+
+	state, err := g726.New(rate, p)
+	if err != nil {
+		return nil, err
+	}
+	return &rawSink{w: bufio.NewWriter(w), encode: state.AppendEncode, flush: state.AppendFlush}, nil
+}
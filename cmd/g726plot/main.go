@@ -0,0 +1,111 @@
+// Command g726plot 把 PCM 的波形, 以及 G.726 编解码之后的波形和两者之差绘制为 PNG 图片
+//
+// 用法:
+//
+//	g726plot [flags] input output.png
+//
+// input 是 8000Hz 单声道的 raw PCM, 采样格式由 -pcm 指定. 不指定 -rate 时只绘制原信号.
+// -from 和 -to 以秒为单位选择放大的时间段.
+//
+// README 中的图片由下面的命令生成:
+//
+//	g726plot example/audio-samples.pcm img/audio-samples.png
+//	g726plot -rate 32 example/audio-samples.pcm img/audio-samples-32kbps.png
+//	g726plot -rate 16 example/audio-samples.pcm img/audio-samples-16kbps.png
+//	g726plot -rate 16 -from 10.6 -to 10.63 example/audio-samples.pcm img/audio-samples-16kbps-zoom.png
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/pcm"
+	"github.com/general252/g726/waveform"
+)
+
+const sampleRate = 8000
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("g726plot", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	rate := fs.Int("rate", 0, "G.726 bit rate in kbit/s: 16, 24, 32 or 40 (default: plot the input only)")
+	format := fs.String("pcm", "s16le", "sample format of the raw PCM input")
+	from := fs.Float64("from", 0, "start of the plotted range in seconds")
+	to := fs.Float64("to", 0, "end of the plotted range in seconds (default: end of the input)")
+	width := fs.Int("width", 1500, "image width in pixels")
+	height := fs.Int("height", 300, "height of each waveform in pixels")
+	amplitude := fs.Int("amplitude", 32768, "vertical range of each waveform, ±amplitude")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: g726plot [flags] input output.png")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if *rate != 0 && (*rate%8 != 0 || *rate < 16 || *rate > 40) {
+		fmt.Fprintf(stderr, "g726plot: rate %d, want 16, 24, 32 or 40\n", *rate)
+		return 2
+	}
+
+	opts := []waveform.Option{
+		waveform.WithSize(*width, *height),
+		waveform.WithRange(int(*from*sampleRate), int(*to*sampleRate)),
+		waveform.WithAmplitude(*amplitude),
+	}
+	if err := plot(fs.Arg(0), fs.Arg(1), *format, *rate, opts); err != nil {
+		fmt.Fprintf(stderr, "g726plot: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func plot(input, output, format string, kbps int, opts []waveform.Option) error {
+	f, err := pcm.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	samples, err := pcm.Decode(f, data)
+	if err != nil {
+		return err
+	}
+
+	var img image.Image
+	if kbps == 0 {
+		img = waveform.Render([]waveform.Panel{{PCM: samples}}, opts...)
+	} else if img, err = waveform.RenderComparison(samples, g726.Rate(kbps/8-2), opts...); err != nil {
+		return err
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		os.Remove(output)
+		return err
+	}
+	return out.Close()
+}
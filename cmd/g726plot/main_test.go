@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/general252/g726/pcm"
+)
+
+func TestPlot(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.raw")
+	samples := make([]int16, 4000)
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	data, _ := pcm.Encode(pcm.S16BE, samples)
+	if err := os.WriteFile(input, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		args   []string
+		height int
+	}{
+		{[]string{"-pcm", "s16be", "-width", "200", "-height", "50"}, 50},
+		{[]string{"-pcm", "s16be", "-width", "200", "-height", "50", "-rate", "24", "-from", "0.1", "-to", "0.12", "-amplitude", "10000"}, 3*50 + 2*2},
+	} {
+		output := filepath.Join(dir, "out.png")
+		var stderr bytes.Buffer
+		if code := run(append(tt.args, input, output), &stderr); code != 0 {
+			t.Fatal(code, stderr.String())
+		}
+		f, _ := os.Open(output)
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 200 || b.Dy() != tt.height {
+			t.Fatalf("%q: %v", tt.args, b)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.raw")
+	output := filepath.Join(dir, "out.png")
+	os.WriteFile(input, []byte{1, 2, 3}, 0644)
+
+	for _, tt := range []struct {
+		args []string
+		code int
+		msg  string
+	}{
+		{nil, 2, "usage"},
+		{[]string{"-bogus", input, output}, 2, "not defined"},
+		{[]string{"-rate", "20", input, output}, 2, "rate 20"},
+		{[]string{input, output}, 1, "multiple of the sample size"},
+		{[]string{filepath.Join(dir, "missing"), output}, 1, "no such file"},
+	} {
+		var stderr bytes.Buffer
+		if code := run(tt.args, &stderr); code != tt.code || !strings.Contains(stderr.String(), tt.msg) {
+			t.Errorf("%q: exit %d, want %d: %s", tt.args, code, tt.code, stderr.String())
+		}
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
@@ -39,10 +39,10 @@ func main() {
 
 func encodeAndDecode(rate g726.Rate, packing g726.PackingType, pcm []byte) (pcmOut, g726Data []byte, err error) {
 	encoder := g726.G726_init_state(rate, packing)
-	g726Data = encoder.EncodeV2(encoder.Pcm8ToPcm16(pcm))
+	g726Data = encoder.EncodeV2(g726.AppendPcm8ToPcm16(nil, pcm))
 
 	decoder := g726.G726_init_state(rate, packing)
 	out := decoder.DecodeV2(g726Data)
 
-	return decoder.Pcm16ToPcm8(out), g726Data, nil
+	return g726.AppendPcm16ToPcm8(nil, out), g726Data, nil
 }
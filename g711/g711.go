@@ -0,0 +1,176 @@
+// Package g711 实现 ITU-T G.711 A律(PCMA)和μ律(PCMU)编解码
+//
+// 线性采样点为16位有符号 PCM, 每个 G.711 采样点为一个字节
+// Append 开头的批量接口在 dst 容量足够时不分配内存
+package g711
+
+import "math/bits"
+
+// Law G.711 压扩律
+type Law int
+
+const (
+	ALaw Law = 0 // A律, RTP 负载类型 8 (PCMA)
+	ULaw Law = 1 // μ律, RTP 负载类型 0 (PCMU)
+)
+
+func (l Law) String() string {
+	switch l {
+	case ALaw:
+		return "alaw"
+	case ULaw:
+		return "ulaw"
+	default:
+		return ""
+	}
+}
+
+const (
+	ALawAMIMask = 0x55 // A律偶数位取反掩码
+
+	ALawIdle byte = 0x80 ^ ALawAMIMask // A律空闲码, 即静音
+	ULawIdle byte = 0xFF               // μ律空闲码, 即静音
+
+	ulawBias = 0x84 // μ律编码偏置
+)
+
+var (
+	alawToLinear [256]int16
+	ulawToLinear [256]int16
+)
+
+func init() {
+	for i := range alawToLinear {
+		alawToLinear[i] = decodeALaw(byte(i))
+		ulawToLinear[i] = decodeULaw(byte(i))
+	}
+}
+
+// EncodeALaw 16位线性 PCM 转 A律
+func EncodeALaw(linear int16) byte {
+	v := int(linear)
+	mask := 0x80 | ALawAMIMask
+	if v < 0 {
+		mask = ALawAMIMask
+		v = -v - 1
+	}
+
+	seg := bits.Len(uint(v|0xFF)) - 8
+	shift := 4
+	if seg != 0 {
+		shift = seg + 3
+	}
+	return byte((seg<<4 | (v>>shift)&0x0F) ^ mask)
+}
+
+// DecodeALaw A律转16位线性 PCM
+func DecodeALaw(alaw byte) int16 {
+	return alawToLinear[alaw]
+}
+
+// EncodeULaw 16位线性 PCM 转μ律
+func EncodeULaw(linear int16) byte {
+	v := int(linear)
+	mask := 0xFF
+	if v >= 0 {
+		v = ulawBias + v
+	} else {
+		v = ulawBias - v
+		mask = 0x7F
+	}
+
+	seg := bits.Len(uint(v|0xFF)) - 8
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+	return byte((seg<<4 | (v>>(seg+3))&0x0F) ^ mask)
+}
+
+// DecodeULaw μ律转16位线性 PCM
+func DecodeULaw(ulaw byte) int16 {
+	return ulawToLinear[ulaw]
+}
+
+func decodeALaw(alaw byte) int16 {
+	alaw ^= ALawAMIMask
+	i := int(alaw&0x0F) << 4
+	seg := int(alaw&0x70) >> 4
+	if seg != 0 {
+		i = (i + 0x108) << (seg - 1)
+	} else {
+		i += 8
+	}
+	if alaw&0x80 != 0 {
+		return int16(i)
+	}
+	return int16(-i)
+}
+
+func decodeULaw(ulaw byte) int16 {
+	ulaw = ^ulaw
+	t := (int(ulaw&0x0F)<<3 + ulawBias) << (int(ulaw&0x70) >> 4)
+	if ulaw&0x80 != 0 {
+		return int16(ulawBias - t)
+	}
+	return int16(t - ulawBias)
+}
+
+// Encode 按压扩律编码一个采样点
+func (l Law) Encode(linear int16) byte {
+	if l == ULaw {
+		return EncodeULaw(linear)
+	}
+	return EncodeALaw(linear)
+}
+
+// Decode 按压扩律解码一个采样点
+func (l Law) Decode(v byte) int16 {
+	if l == ULaw {
+		return ulawToLinear[v]
+	}
+	return alawToLinear[v]
+}
+
+// Idle 返回空闲码(静音)
+func (l Law) Idle() byte {
+	if l == ULaw {
+		return ULawIdle
+	}
+	return ALawIdle
+}
+
+// AppendEncode 编码 pcm 并追加到 dst
+func (l Law) AppendEncode(dst []byte, pcm []int16) []byte {
+	if l == ULaw {
+		for _, v := range pcm {
+			dst = append(dst, EncodeULaw(v))
+		}
+		return dst
+	}
+	for _, v := range pcm {
+		dst = append(dst, EncodeALaw(v))
+	}
+	return dst
+}
+
+// AppendDecode 解码 data 并把采样点追加到 dst
+func (l Law) AppendDecode(dst []int16, data []byte) []int16 {
+	table := &alawToLinear
+	if l == ULaw {
+		table = &ulawToLinear
+	}
+	for _, v := range data {
+		dst = append(dst, table[v])
+	}
+	return dst
+}
+
+// Encode 编码 pcm, 返回新分配的切片
+func Encode(l Law, pcm []int16) []byte {
+	return l.AppendEncode(make([]byte, 0, len(pcm)), pcm)
+}
+
+// Decode 解码 data, 返回新分配的切片
+func Decode(l Law, data []byte) []int16 {
+	return l.AppendDecode(make([]int16, 0, len(data)), data)
+}
@@ -0,0 +1,107 @@
+package g711
+
+import "testing"
+
+func TestRoundTrip(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		if got := EncodeALaw(DecodeALaw(byte(i))); got != byte(i) {
+			t.Fatalf("A-law %#x: round trip gives %#x", i, got)
+		}
+		// 0x7F 是μ律的负零, 编码时总是得到 0xFF
+		if i == 0x7F {
+			continue
+		}
+		if got := EncodeULaw(DecodeULaw(byte(i))); got != byte(i) {
+			t.Fatalf("u-law %#x: round trip gives %#x", i, got)
+		}
+	}
+
+	if DecodeALaw(ALawIdle) != 8 || DecodeULaw(ULawIdle) != 0 {
+		t.Fatal("idle octets do not decode to silence")
+	}
+	if EncodeALaw(0) != ALawIdle || EncodeULaw(0) != ULawIdle {
+		t.Fatal("silence does not encode to the idle octets")
+	}
+}
+
+func TestMonotonic(t *testing.T) {
+	for _, l := range []Law{ALaw, ULaw} {
+		prev := l.Decode(l.Encode(-32768))
+		for v := -32768; v <= 32767; v++ {
+			got := l.Decode(l.Encode(int16(v)))
+			if got < prev {
+				t.Fatalf("%v: %d decodes to %d, below %d", l, v, got, prev)
+			}
+			prev = got
+		}
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		// 转码前后的线性值相差不超过一个量化级
+		for _, l := range []Law{ALaw, ULaw} {
+			to := ULaw - l
+			in := byte(i)
+			out := AppendTranscode(nil, l, []byte{in})[0]
+			a, b := int(l.Decode(in)), int(to.Decode(out))
+			step := a / 8
+			if step < 0 {
+				step = -step
+			}
+			if d := a - b; d > step+16 || d < -step-16 {
+				t.Fatalf("%v %#x -> %v %#x: %d vs %d", l, in, to, out, a, b)
+			}
+		}
+	}
+
+	data := []byte{0x00, 0x55, 0xD5, 0x2A, 0xAA}
+	want := AppendTranscode(nil, ALaw, data)
+	Transcode(ALaw, data)
+	if string(data) != string(want) {
+		t.Fatal("in-place transcoding differs")
+	}
+	for i, v := range data {
+		if v != ALawToULaw([]byte{0x00, 0x55, 0xD5, 0x2A, 0xAA}[i]) {
+			t.Fatalf("byte %d transcoded to %#x", i, v)
+		}
+	}
+}
+
+func TestIdle(t *testing.T) {
+	for _, l := range []Law{ALaw, ULaw} {
+		data := AppendIdle(nil, l, 160)
+		if len(data) != 160 || !IsIdle(l, data) {
+			t.Fatalf("%v: AppendIdle", l)
+		}
+		data[3] ^= 1
+		if IsIdle(l, data) {
+			t.Fatalf("%v: IsIdle accepted a non-idle octet", l)
+		}
+		FillIdle(l, data)
+		if !IsIdle(l, data) {
+			t.Fatalf("%v: FillIdle", l)
+		}
+	}
+}
+
+func TestAppendZeroAlloc(t *testing.T) {
+	pcm := make([]int16, 160)
+	for i := range pcm {
+		pcm[i] = int16(i * 200)
+	}
+	enc := make([]byte, 0, len(pcm))
+	dec := make([]int16, 0, len(pcm))
+	conv := make([]byte, 0, len(pcm))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, l := range []Law{ALaw, ULaw} {
+			data := l.AppendEncode(enc[:0], pcm)
+			l.AppendDecode(dec[:0], data)
+			AppendTranscode(conv[:0], l, data)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("got %v allocations, want 0", allocs)
+	}
+}
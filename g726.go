@@ -2,7 +2,17 @@ package g726
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+)
+
+var (
+	ErrInvalidRate    = errors.New("invalid rate")
+	ErrInvalidPacking = errors.New("invalid packing")
+	ErrInputLength    = errors.New("invalid input length")
+	ErrInvalidCoding  = errors.New("invalid ext coding")
+	ErrClosed         = errors.New("write to closed writer")
 )
 
 type Rate int
@@ -14,6 +24,33 @@ const (
 	Rate40kbps Rate = 3
 )
 
+// ExtCoding 编码器输入和解码器输出的采样点格式
+// A律和μ律时每个采样点是一个 G.711 字节, 以 int16 传递时只使用低8位
+type ExtCoding int
+
+const (
+	ExtCodingLinear ExtCoding = 0 // 16位线性 PCM
+	ExtCodingULaw   ExtCoding = 1 // G.711 μ律(PCMU)
+	ExtCodingALaw   ExtCoding = 2 // G.711 A律(PCMA)
+)
+
+func (c ExtCoding) String() string {
+	switch c {
+	case ExtCodingLinear:
+		return "linear"
+	case ExtCodingULaw:
+		return "ulaw"
+	case ExtCodingALaw:
+		return "alaw"
+	default:
+		return ""
+	}
+}
+
+func (c ExtCoding) valid() bool {
+	return c == ExtCodingLinear || c == ExtCodingULaw || c == ExtCodingALaw
+}
+
 type PackingType int
 
 const (
@@ -38,11 +75,20 @@ func (r Rate) String() string {
 }
 
 func (state_ptr *G726_state) Encode(pcm []int16) ([]byte, error) {
+	out, err := state_ptr.encode(pcm)
+	if err == nil {
+		state_ptr.samples += int64(len(pcm))
+		state_ptr.direction = dir_encode
+	}
+	return out, err
+}
+
+func (state_ptr *G726_state) encode(pcm []int16) ([]byte, error) {
 	switch state_ptr.rate {
 	case Rate16kbps:
 		input_len := len(pcm)
 		if input_len%4 != 0 {
-			return nil, fmt.Errorf("input length must be a multiple of 4 for 16kbps encoding")
+			return nil, fmt.Errorf("%w: must be a multiple of 4 for 16kbps encoding", ErrInputLength)
 		}
 
 		var out = make([]byte, 0, input_len/4)
@@ -60,7 +106,7 @@ func (state_ptr *G726_state) Encode(pcm []int16) ([]byte, error) {
 	case Rate24kbps:
 		input_len := len(pcm)
 		if input_len%8 != 0 {
-			return nil, fmt.Errorf("input length must be multiple of 8 for 24kbps encoding")
+			return nil, fmt.Errorf("%w: must be a multiple of 8 for 24kbps encoding", ErrInputLength)
 		}
 
 		out := make([]byte, 0, input_len/8*3)
@@ -87,7 +133,7 @@ func (state_ptr *G726_state) Encode(pcm []int16) ([]byte, error) {
 	case Rate32kbps:
 		input_len := len(pcm)
 		if input_len%2 != 0 {
-			return nil, fmt.Errorf("input length must be a multiple of 2 for 32kbps encoding")
+			return nil, fmt.Errorf("%w: must be a multiple of 2 for 32kbps encoding", ErrInputLength)
 		}
 
 		var out = make([]byte, 0, input_len/2)
@@ -102,7 +148,7 @@ func (state_ptr *G726_state) Encode(pcm []int16) ([]byte, error) {
 	case Rate40kbps:
 		input_len := len(pcm)
 		if input_len%8 != 0 {
-			return nil, fmt.Errorf("input length must be multiple of 8 for 40kbps encoding")
+			return nil, fmt.Errorf("%w: must be a multiple of 8 for 40kbps encoding", ErrInputLength)
 		}
 
 		out_len := input_len * 5 / 8
@@ -129,12 +175,21 @@ func (state_ptr *G726_state) Encode(pcm []int16) ([]byte, error) {
 		}
 		return out, nil
 	default:
-		return nil, fmt.Errorf("invalid rate")
+		return nil, ErrInvalidRate
 	}
 
 }
 
 func (state_ptr *G726_state) Decode(bitstream []byte) ([]int16, error) {
+	out, err := state_ptr.decode(bitstream)
+	if err == nil {
+		state_ptr.samples += int64(len(out))
+		state_ptr.direction = dir_decode
+	}
+	return out, err
+}
+
+func (state_ptr *G726_state) decode(bitstream []byte) ([]int16, error) {
 	switch state_ptr.rate {
 	case Rate16kbps:
 		input_len := len(bitstream)
@@ -155,7 +210,7 @@ func (state_ptr *G726_state) Decode(bitstream []byte) ([]int16, error) {
 	case Rate24kbps:
 		input_len := len(bitstream)
 		if input_len%3 != 0 {
-			return nil, fmt.Errorf("input length must be multiple of 3 for 24kbps decoding")
+			return nil, fmt.Errorf("%w: must be a multiple of 3 for 24kbps decoding", ErrInputLength)
 		}
 
 		out_len := input_len * 8 / 3
@@ -200,7 +255,7 @@ func (state_ptr *G726_state) Decode(bitstream []byte) ([]int16, error) {
 	case Rate40kbps:
 		input_len := len(bitstream)
 		if input_len%5 != 0 {
-			return nil, fmt.Errorf("input length must be multiple of 5 for 40kbps decoding")
+			return nil, fmt.Errorf("%w: must be a multiple of 5 for 40kbps decoding", ErrInputLength)
 		}
 
 		out_len := input_len * 8 / 5
@@ -234,102 +289,244 @@ func (state_ptr *G726_state) Decode(bitstream []byte) ([]int16, error) {
 		}
 		return out, nil
 	default:
-		return nil, fmt.Errorf("invalid rate")
+		return nil, ErrInvalidRate
 	}
 }
 
 func (state_ptr *G726_state) EncodeV2(pcm []int16) []byte {
+	g726Data := make([]byte, 0, state_ptr.EncodedLen(len(pcm)))
+	return state_ptr.AppendEncode(g726Data, pcm)
+}
+
+// AppendEncode 编码 pcm 并把码流追加到 dst, 返回追加后的切片
+// dst 的剩余容量不小于 EncodedLen(len(pcm)) 时不会分配内存
+func (state_ptr *G726_state) AppendEncode(dst []byte, pcm []int16) []byte {
 	s := state_ptr
-	// 一个采样点(sample)占用4个比特(bit)
-	sampleCount := len(pcm)
-	g726Bytes := int(s.bits_per_sample)*sampleCount/8 + 1
-	g726Data := make([]byte, 0, g726Bytes)
-
-	for i := 0; i < len(pcm); i++ {
-		code := s.fun_encoder(int(pcm[i]))
-
-		if s.packing == PackingRight {
-			s.bs.bitstream |= uint32(code) << uint32(s.bs.residue)
-			s.bs.residue += s.bits_per_sample
-			if s.bs.residue >= 8 {
-				g726Data = append(g726Data, byte(s.bs.bitstream&0xFF))
-				s.bs.bitstream >>= 8
-				s.bs.residue -= 8
-			}
-		} else if s.packing == PackingLeft {
-			s.bs.bitstream = (s.bs.bitstream << uint32(s.bits_per_sample)) | uint32(code)
-			s.bs.residue += s.bits_per_sample
-			if s.bs.residue >= 8 {
-				g726Data = append(g726Data, byte((s.bs.bitstream>>(s.bs.residue-8))&0xFF))
-				s.bs.residue -= 8
-			}
-		} else if s.packing == PackingNone {
-			g726Data = append(g726Data, byte(code))
+	var codes [blockSamples]byte
+
+	s.samples += int64(len(pcm))
+	s.direction = dir_encode
+	for len(pcm) > 0 {
+		n := len(pcm)
+		if n > blockSamples {
+			n = blockSamples
 		}
+
+		s.encode_block(codes[:n], pcm[:n])
+		dst = s.pack(dst, codes[:n])
+		pcm = pcm[n:]
 	}
 
-	return g726Data
+	return dst
+}
+
+// EncodeInto 编码 src 并把码流写入 dst, 返回写入的字节数
+// dst 的长度小于 EncodedLen(len(src)) 时返回 io.ErrShortBuffer, 状态保持不变
+func (state_ptr *G726_state) EncodeInto(dst []byte, src []int16) (n int, err error) {
+	n = state_ptr.EncodedLen(len(src))
+	if len(dst) < n {
+		return 0, io.ErrShortBuffer
+	}
+
+	return len(state_ptr.AppendEncode(dst[:0], src)), nil
+}
+
+// EncodedLen 返回编码 samples 个采样点时输出的字节数(包括上次编码剩余的比特)
+func (state_ptr *G726_state) EncodedLen(samples int) int {
+	s := state_ptr
+	if s.packing == PackingNone {
+		return samples
+	}
+	return (int(s.bs.residue) + samples*int(s.bits_per_sample)) / 8
+}
+
+// Flush 输出编码器中剩余不足一个字节的比特, 空余的比特补零
+// 采样点数乘以每个采样点的比特数不是8的倍数时(常见于24kbps和40kbps), 需要在码流结束时调用
+// 解码端可以通过 SetSampleLimit 丢弃补零产生的多余采样点
+func (state_ptr *G726_state) Flush() []byte {
+	if state_ptr.bs.residue <= 0 {
+		return nil
+	}
+	return state_ptr.AppendFlush(nil)
+}
+
+// AppendFlush 与 Flush 相同, 输出追加到 dst
+func (state_ptr *G726_state) AppendFlush(dst []byte) []byte {
+	s := state_ptr
+	if s.bs.residue <= 0 {
+		return dst
+	}
+
+	var v byte
+	switch s.packing {
+	case PackingRight:
+		v = byte(s.bs.bitstream & 0xFF)
+	case PackingLeft:
+		v = byte(s.bs.bitstream << uint32(8-s.bs.residue))
+	default:
+		return dst
+	}
+
+	s.bs.bitstream = 0
+	s.bs.residue = 0
+	return append(dst, v)
 }
 
 func (state_ptr *G726_state) DecodeV2(g726_data []byte) []int16 {
+	pcm := make([]int16, 0, state_ptr.DecodedLen(len(g726_data)))
+	return state_ptr.AppendDecode(pcm, g726_data)
+}
+
+// AppendDecode 解码 g726_data 并把采样点追加到 dst, 返回追加后的切片
+// dst 的剩余容量不小于 DecodedLen(len(g726_data)) 时不会分配内存
+func (state_ptr *G726_state) AppendDecode(dst []int16, g726_data []byte) []int16 {
 	s := state_ptr
+	var codes [blockSamples]byte
 
-	var i int
-	var code byte
-	var g726_bytes = len(g726_data)
-	var sampleCount = g726_bytes * 8 / int(s.bits_per_sample)
-	var pcm = make([]int16, 0, sampleCount)
+	s.direction = dir_decode
 
 	for {
-		if s.packing != PackingNone {
-			if s.packing == PackingRight {
-				if s.bs.residue < s.bits_per_sample {
-					if i >= g726_bytes {
-						break
-					}
-
-					s.bs.bitstream |= uint32(g726_data[i]) << uint32(s.bs.residue)
-					i += 1
-
-					s.bs.residue += 8
-				}
-				code = (byte)(s.bs.bitstream & ((1 << s.bits_per_sample) - 1))
-				s.bs.bitstream >>= s.bits_per_sample
-			} else if s.packing == PackingLeft {
-				if s.bs.residue < s.bits_per_sample {
-					if i >= g726_bytes {
-						break
-					}
-					s.bs.bitstream = (s.bs.bitstream << 8) | uint32(g726_data[i])
-					i += 1
-					s.bs.residue += 8
-				}
-
-				code = byte((s.bs.bitstream >> (s.bs.residue - s.bits_per_sample)) & ((1 << s.bits_per_sample) - 1))
+		max := blockSamples
+		if s.sample_limit >= 0 {
+			if left := s.sample_limit - s.samples; left < int64(max) {
+				max = int(left)
 			}
+		}
+		if max <= 0 {
+			break
+		}
+
+		n, used := s.unpack(codes[:max], g726_data)
+		if n == 0 {
+			break
+		}
+		g726_data = g726_data[used:]
+
+		start := len(dst)
+		dst = append(dst, make([]int16, n)...)
+		s.decode_block(dst[start:], codes[:n])
+		s.samples += int64(n)
+	}
+
+	return dst
+}
+
+// DecodeInto 解码 src 并把采样点写入 dst, 返回写入的采样点数
+// dst 的长度小于 DecodedLen(len(src)) 时返回 io.ErrShortBuffer, 状态保持不变
+func (state_ptr *G726_state) DecodeInto(dst []int16, src []byte) (n int, err error) {
+	n = state_ptr.DecodedLen(len(src))
+	if len(dst) < n {
+		return 0, io.ErrShortBuffer
+	}
+
+	return len(state_ptr.AppendDecode(dst[:0], src)), nil
+}
+
+// DecodedLen 返回解码 n 个字节时输出的采样点数(包括上次解码剩余的比特, 受 SetSampleLimit 限制)
+func (state_ptr *G726_state) DecodedLen(n int) int {
+	s := state_ptr
+	samples := n
+	if s.packing != PackingNone {
+		samples = (int(s.bs.residue) + n*8) / int(s.bits_per_sample)
+	}
 
-			s.bs.residue -= s.bits_per_sample
-		} else if s.packing == PackingNone {
-			if i >= g726_bytes {
-				break
+	if s.sample_limit >= 0 {
+		if left := s.sample_limit - s.samples; left < int64(samples) {
+			if left < 0 {
+				left = 0
 			}
-			code = g726_data[i]
-			i += 1
+			samples = int(left)
+		}
+	}
+	return samples
+}
+
+// Prime 用 pcm 更新量化器和预测器的状态, 效果与解码 pcm 编码后的码字相同, 但不产生输出
+// 丢包时先用隐藏算法生成替代的 pcm, 再用 Prime 让解码器的状态跟上编码端, 可以减小丢包后的失真
+// 码流中剩余的比特和采样点计数不变, pcm 的格式由 ext coding 决定
+func (state_ptr *G726_state) Prime(pcm []int16) {
+	var codes [blockSamples]byte
+	for len(pcm) > 0 {
+		n := len(pcm)
+		if n > blockSamples {
+			n = blockSamples
+		}
+		state_ptr.encode_block(codes[:n], pcm[:n])
+		pcm = pcm[n:]
+	}
+}
+
+// Samples 返回已经编码或解码的采样点数
+func (state_ptr *G726_state) Samples() int64 {
+	return state_ptr.samples
+}
+
+// SetSampleLimit 设置解码的采样点总数, 达到后 DecodeV2 不再输出采样点, 小于0表示不限制
+// 与编码端的 Samples 配合使用, 可以丢弃 Flush 补零产生的多余采样点, 保持原始长度
+func (state_ptr *G726_state) SetSampleLimit(n int64) {
+	state_ptr.sample_limit = n
+}
+
+// EncodeG711 编码 G.711 字节流, 状态需要用 WithExtCoding 设置为A律或μ律
+func (state_ptr *G726_state) EncodeG711(g711 []byte) []byte {
+	return state_ptr.AppendEncodeG711(make([]byte, 0, state_ptr.EncodedLen(len(g711))), g711)
+}
+
+// AppendEncodeG711 与 EncodeG711 相同, 码流追加到 dst
+func (state_ptr *G726_state) AppendEncodeG711(dst []byte, g711 []byte) []byte {
+	var pcm [blockSamples]int16
+
+	for len(g711) > 0 {
+		n := len(g711)
+		if n > blockSamples {
+			n = blockSamples
 		}
+		for i, v := range g711[:n] {
+			pcm[i] = int16(v)
+		}
+		dst = state_ptr.AppendEncode(dst, pcm[:n])
+		g711 = g711[n:]
+	}
+
+	return dst
+}
+
+// DecodeG711 解码为 G.711 字节流, 状态需要用 WithExtCoding 设置为A律或μ律
+func (state_ptr *G726_state) DecodeG711(g726_data []byte) []byte {
+	return state_ptr.AppendDecodeG711(make([]byte, 0, state_ptr.DecodedLen(len(g726_data))), g726_data)
+}
+
+// AppendDecodeG711 与 DecodeG711 相同, G.711 字节追加到 dst
+func (state_ptr *G726_state) AppendDecodeG711(dst []byte, g726_data []byte) []byte {
+	s := state_ptr
+	var pcm [blockSamples + 8]int16
+
+	// 每次解码的字节数, 保证输出不超过 pcm 的长度
+	chunk := blockSamples
+	if s.packing != PackingNone {
+		chunk = blockSamples * int(s.bits_per_sample) / 8
+	}
 
-		sl := s.fun_decoder(int(code))
-		pcm = append(pcm, int16(sl))
+	for len(g726_data) > 0 {
+		n := chunk
+		if n > len(g726_data) {
+			n = len(g726_data)
+		}
+		for _, v := range s.AppendDecode(pcm[:0], g726_data[:n]) {
+			dst = append(dst, byte(v))
+		}
+		g726_data = g726_data[n:]
 	}
 
-	return pcm
+	return dst
 }
 
 func (state_ptr *G726_state) EncodeSimple(pcm []byte) ([]byte, error) {
 	if len(pcm)%2 != 0 {
-		return nil, fmt.Errorf("pcm length must be even")
+		return nil, fmt.Errorf("%w: pcm length must be even", ErrInputLength)
 	}
 
-	pcm_in := state_ptr.Pcm8ToPcm16(pcm)
+	pcm_in := AppendPcm8ToPcm16(make([]int16, 0, len(pcm)/2), pcm)
 	return state_ptr.Encode(pcm_in)
 }
 
@@ -339,24 +536,42 @@ func (state_ptr *G726_state) DecodeSimple(bitstream []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return state_ptr.Pcm16ToPcm8(pcm_out), nil
+	return AppendPcm16ToPcm8(make([]byte, 0, len(pcm_out)*2), pcm_out), nil
 }
 
+// Pcm8ToPcm16 把16位小端PCM数据转换为采样点
+//
+// Deprecated: 与编解码状态无关且只支持16位小端, 使用 AppendPcm8ToPcm16 或 pcm 包的 Decode
 func (state_ptr *G726_state) Pcm8ToPcm16(pcm8 []byte) []int16 {
-	pcm16 := make([]int16, len(pcm8)/2)
-	for i := 0; i < len(pcm16); i++ {
+	return AppendPcm8ToPcm16(make([]int16, 0, len(pcm8)/2), pcm8)
+}
+
+// Pcm16ToPcm8 把采样点转换为16位小端PCM数据
+//
+// Deprecated: 与编解码状态无关且只支持16位小端, 使用 AppendPcm16ToPcm8 或 pcm 包的 Encode
+func (state_ptr *G726_state) Pcm16ToPcm8(pcm16 []int16) []byte {
+	return AppendPcm16ToPcm8(make([]byte, 0, len(pcm16)*2), pcm16)
+}
+
+// AppendPcm8ToPcm16 把16位小端PCM数据转换为采样点追加到 dst, 末尾不完整的字节被忽略
+func AppendPcm8ToPcm16(dst []int16, pcm8 []byte) []int16 {
+	for i := 0; i+1 < len(pcm8); i += 2 {
 		// 每2字节组合为一个int16
-		pcm16[i] = int16(binary.LittleEndian.Uint16(pcm8[2*i : 2*i+2]))
+		dst = append(dst, int16(binary.LittleEndian.Uint16(pcm8[i:i+2])))
 	}
 
-	return pcm16
+	return dst
 }
 
-func (state_ptr *G726_state) Pcm16ToPcm8(pcm16 []int16) []byte {
-	pcm8 := make([]byte, len(pcm16)*2)
+// AppendPcm16ToPcm8 把采样点转换为16位小端PCM数据追加到 dst
+func AppendPcm16ToPcm8(dst []byte, pcm16 []int16) []byte {
 	for i := 0; i < len(pcm16); i++ {
-		binary.LittleEndian.PutUint16(pcm8[2*i:2*i+2], uint16(pcm16[i]))
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(pcm16[i]))
 	}
 
-	return pcm8
+	return dst
+}
+
+func (p PackingType) valid() bool {
+	return p == PackingNone || p == PackingLeft || p == PackingRight
 }
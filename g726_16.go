@@ -35,6 +35,21 @@ func init() {
 	p16._fitab = [4]int{0, 0xE00, 0xE00, 0}
 }
 
+// quantize_16 量化预测差值 d, 返回2位码字
+func quantize_16(d, y int) int {
+	i := quantize(d, y, p16.qtab_723_16[:])
+
+	/* Since quantize() only produces a three level output
+	 * (1, 2, or 3), we must create the fourth one on our own
+	 */
+	if i == 3 { /* i code for the zero region */
+		if (d & 0x8000) == 0 { /* If d > 0, i=3 isn't right... */
+			i = 0
+		}
+	}
+	return i
+}
+
 func (state_ptr *G726_state) g726_16_encoder(sl int) int {
 	var (
 		sezi  int
@@ -49,7 +64,7 @@ func (state_ptr *G726_state) g726_16_encoder(sl int) int {
 		dqsez int
 	)
 
-	sl >>= 2 /* sl of 14-bit dynamic range */
+	sl = state_ptr.linear_input(sl) /* sl of 14-bit dynamic range */
 
 	sezi = state_ptr.predictor_zero()
 	sez = sezi >> 1
@@ -59,21 +74,16 @@ func (state_ptr *G726_state) g726_16_encoder(sl int) int {
 	d = sl - se /* d = estimation diff. */
 
 	/* quantize prediction difference d */
-	y = state_ptr.step_size()              /* quantizer step size */
-	i = quantize(d, y, p16.qtab_723_16[:]) /* i = ADPCM code */
-
-	/* Since quantize() only produces a three level output
-	 * (1, 2, or 3), we must create the fourth one on our own
-	 */
-	if i == 3 { /* i code for the zero region */
-		if (d & 0x8000) == 0 { /* If d > 0, i=3 isn't right... */
-			i = 0
-		}
-	}
+	y = state_ptr.step_size() /* quantizer step size */
+	i = quantize_16(d, y)     /* i = ADPCM code */
 
 	dq = reconstruct(i&2, int(p16._dqlntab[i]), y) /* quantized diff. */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x3FFF), se+dq) /* reconstructed signal */
+	if dq < 0 { /* reconstructed signal */
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr + sez - se /* pole prediction diff. */
 
@@ -103,11 +113,22 @@ func (state_ptr *G726_state) g726_16_decoder(i int) int {
 	y = state_ptr.step_size()                         /* adaptive quantizer step size */
 	dq = reconstruct(i&0x02, int(p16._dqlntab[i]), y) /* unquantize pred diff */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x3FFF), se+dq) /* reconst. signal */
+	if dq < 0 { /* reconst. signal */
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr - se + sez /* pole prediction diff. */
 
 	state_ptr.update(2, y, int(p16._witab[i]), int(p16._fitab[i]), dq, sr, dqsez)
 
+	switch state_ptr.ext_coding {
+	case ExtCodingALaw:
+		return tandem_adjust_alaw(sr, se, y, i, 2, quantize_16)
+	case ExtCodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, i, 2, quantize_16)
+	}
+
 	return sr << 2 /* sr was of 14-bit dynamic range */
 }
@@ -29,6 +29,11 @@ func init() {
 	p24._fitab = [8]int{0, 0x200, 0x400, 0xE00, 0xE00, 0x400, 0x200, 0}
 }
 
+// quantize_24 量化预测差值 d, 返回3位码字
+func quantize_24(d, y int) int {
+	return quantize(d, y, p24.qtab_723_24[:])
+}
+
 func (state_ptr *G726_state) g726_24_encoder(sl int) int {
 	var (
 		sezi  int
@@ -43,7 +48,7 @@ func (state_ptr *G726_state) g726_24_encoder(sl int) int {
 		dqsez int
 	)
 
-	sl >>= 2 /* sl of 14-bit dynamic range */
+	sl = state_ptr.linear_input(sl) /* sl of 14-bit dynamic range */
 
 	sezi = state_ptr.predictor_zero()
 	sez = sezi >> 1
@@ -57,7 +62,11 @@ func (state_ptr *G726_state) g726_24_encoder(sl int) int {
 	i = quantize(d, y, p24.qtab_723_24[:])         /* i = ADPCM code */
 	dq = reconstruct(i&4, int(p24._dqlntab[i]), y) /* quantized diff. */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x3FFF), se+dq) /* reconstructed signal */
+	if dq < 0 { /* reconstructed signal */
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr + sez - se /* pole prediction diff. */
 
@@ -87,11 +96,22 @@ func (state_ptr *G726_state) g726_24_decoder(i int) int {
 	y = state_ptr.step_size()                         /* adaptive quantizer step size */
 	dq = reconstruct(i&0x04, int(p24._dqlntab[i]), y) /* unquantize pred diff */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x3FFF), se+dq) /* reconst. signal */
+	if dq < 0 { /* reconst. signal */
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr - se + sez /* pole prediction diff. */
 
 	state_ptr.update(3, y, int(p24._witab[i]), int(p24._fitab[i]), dq, sr, dqsez)
 
+	switch state_ptr.ext_coding {
+	case ExtCodingALaw:
+		return tandem_adjust_alaw(sr, se, y, i, 4, quantize_24)
+	case ExtCodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, i, 4, quantize_24)
+	}
+
 	return sr << 2 /* sr was of 14-bit dynamic range */
 }
@@ -30,6 +30,11 @@ func init() {
 		0xE00, 0x600, 0x200, 0x200, 0x200, 0, 0, 0}
 }
 
+// quantize_32 量化预测差值 d, 返回4位码字
+func quantize_32(d, y int) int {
+	return quantize(d, y, p32.qtab_721[:])
+}
+
 func (state_ptr *G726_state) g726_32_encoder(sl int) int {
 	var (
 		sezi  int
@@ -43,7 +48,7 @@ func (state_ptr *G726_state) g726_32_encoder(sl int) int {
 		dqsez int
 	)
 
-	sl >>= 2 /* 14-bit dynamic range */
+	sl = state_ptr.linear_input(sl) /* sl of 14-bit dynamic range */
 
 	sezi = state_ptr.predictor_zero()
 	sez = sezi >> 1
@@ -57,14 +62,14 @@ func (state_ptr *G726_state) g726_32_encoder(sl int) int {
 
 	dq = reconstruct(i&8, p32._dqlntab[i], y) /* quantized est diff */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x3FFF), se+dq) /* reconst. signal */
+	if dq < 0 { /* reconst. signal */
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr + sez - se /* pole prediction diff. */
 
-	t0 := int(p32._witab[i])
-	t1 := t0 << 5
-	t2 := int(t1)
-	_ = t2
 	state_ptr.update(4, y, p32._witab[i]<<5, p32._fitab[i], dq, sr, dqsez)
 
 	return i
@@ -93,15 +98,29 @@ func (state_ptr *G726_state) g726_32_decoder(i int) int {
 
 	dq = reconstruct(i&0x08, p32._dqlntab[i], y) /* quantized diff. */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x3FFF), se+dq) /* reconst. signal */
+	if dq < 0 { /* reconst. signal */
+		sr = se - (dq & 0x3FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr - se + sez /* pole prediction diff. */
 
 	state_ptr.update(4, y, p32._witab[i]<<5, p32._fitab[i], dq, sr, dqsez)
 
+	switch state_ptr.ext_coding {
+	case ExtCodingALaw:
+		return tandem_adjust_alaw(sr, se, y, i, 8, quantize_32)
+	case ExtCodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, i, 8, quantize_32)
+	}
+
 	lino = sr << 2 /* this seems to overflow a short*/
-	lino = IfElse[int](lino > 32767, 32767, lino)
-	lino = IfElse[int](lino < -32768, -32768, lino)
+	if lino > 32767 {
+		lino = 32767
+	} else if lino < -32768 {
+		lino = -32768
+	}
 
 	return lino //(sr << 2);	/* sr was 14-bit dynamic range */
 }
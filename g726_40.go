@@ -39,6 +39,11 @@ func init() {
 
 }
 
+// quantize_40 量化预测差值 d, 返回5位码字
+func quantize_40(d, y int) int {
+	return quantize(d, y, p40.qtab_723_40[:])
+}
+
 func (state_ptr *G726_state) g726_40_encoder(sl int) int {
 
 	var (
@@ -54,7 +59,7 @@ func (state_ptr *G726_state) g726_40_encoder(sl int) int {
 		dqsez int
 	)
 
-	sl >>= 2 /* sl of 14-bit dynamic range */
+	sl = state_ptr.linear_input(sl) /* sl of 14-bit dynamic range */
 
 	sezi = state_ptr.predictor_zero()
 	sez = sezi >> 1
@@ -69,7 +74,11 @@ func (state_ptr *G726_state) g726_40_encoder(sl int) int {
 
 	dq = reconstruct(i&0x10, int(p40._dqlntab[i]), y) /* quantized diff */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x7FFF), se+dq) /* reconstructed signal */
+	if dq < 0 { /* reconstructed signal */
+		sr = se - (dq & 0x7FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr + sez - se /* dqsez = pole prediction diff. */
 
@@ -99,11 +108,22 @@ func (state_ptr *G726_state) g726_40_decoder(i int) int {
 	y = state_ptr.step_size()                         /* adaptive quantizer step size */
 	dq = reconstruct(i&0x10, int(p40._dqlntab[i]), y) /* estimation diff. */
 
-	sr = IfElse[int](dq < 0, se-(dq&0x7FFF), se+dq) /* reconst. signal */
+	if dq < 0 { /* reconst. signal */
+		sr = se - (dq & 0x7FFF)
+	} else {
+		sr = se + dq
+	}
 
 	dqsez = sr - se + sez /* pole prediction diff. */
 
 	state_ptr.update(5, y, int(p40._witab[i]), int(p40._fitab[i]), dq, sr, dqsez)
 
+	switch state_ptr.ext_coding {
+	case ExtCodingALaw:
+		return tandem_adjust_alaw(sr, se, y, i, 0x10, quantize_40)
+	case ExtCodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, i, 0x10, quantize_40)
+	}
+
 	return sr << 2 /* sr was of 14-bit dynamic range */
 }
@@ -0,0 +1,228 @@
+package g726
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFlushSampleLimit(t *testing.T) {
+	for _, packing := range []PackingType{PackingLeft, PackingRight} {
+		for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+			for _, n := range []int{1, 3, 7, 160, 161} {
+				pcm := testSignal(n)
+
+				enc := G726_init_state(rate, packing)
+				data := enc.EncodeV2(pcm)
+				data = append(data, enc.Flush()...)
+				if want := (n*int(enc.bits_per_sample) + 7) / 8; len(data) != want {
+					t.Fatalf("%v packing %v n %d: got %d bytes, want %d", rate, packing, n, len(data), want)
+				}
+				if enc.Flush() != nil {
+					t.Fatalf("%v packing %v: second Flush returned data", rate, packing)
+				}
+
+				ref := G726_init_state(rate, packing)
+				refData := ref.EncodeV2(append(pcm, make([]int16, 8)...))
+
+				dec := G726_init_state(rate, packing)
+				dec.SetSampleLimit(enc.Samples())
+				out := dec.DecodeV2(data)
+				if len(out) != n {
+					t.Fatalf("%v packing %v: decoded %d samples, want %d", rate, packing, len(out), n)
+				}
+
+				// 补零只影响最后一个字节中未使用的比特
+				full := n * int(enc.bits_per_sample) / 8
+				for i := 0; i < full; i++ {
+					if data[i] != refData[i] {
+						t.Fatalf("%v packing %v: byte %d differs", rate, packing, i)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	pcm := testSignal(1003)
+	for _, packing := range []PackingType{PackingNone, PackingLeft, PackingRight} {
+		for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+			enc := G726_init_state(rate, packing)
+			enc.EncodeV2(pcm[:501])
+
+			data, err := enc.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var restored G726_state
+			if err = restored.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if !restored.Equal(enc) {
+				t.Fatalf("%v packing %v: restored state differs", rate, packing)
+			}
+
+			clone := enc.Clone()
+			want := string(enc.EncodeV2(pcm[501:]))
+			if clone.Equal(enc) {
+				t.Fatalf("%v packing %v: clone shares state with original", rate, packing)
+			}
+			if got := string(restored.EncodeV2(pcm[501:])); got != want {
+				t.Fatalf("%v packing %v: restored state encodes differently", rate, packing)
+			}
+			if got := string(clone.EncodeV2(pcm[501:])); got != want {
+				t.Fatalf("%v packing %v: cloned state encodes differently", rate, packing)
+			}
+			if !clone.Equal(enc) || !restored.Equal(enc) {
+				t.Fatalf("%v packing %v: states diverged", rate, packing)
+			}
+		}
+	}
+
+	var s G726_state
+	if err := s.UnmarshalBinary([]byte{99}); err == nil {
+		t.Fatal("expected error for unknown version")
+	}
+
+	// 码流中剩余的比特数超出范围
+	data, _ := G726_init_state(Rate32kbps, PackingLeft).MarshalBinary()
+	for _, residue := range []int32{-1, 9, 33} {
+		binary.BigEndian.PutUint32(data[len(data)-20:], uint32(residue))
+		if err := s.UnmarshalBinary(data); err == nil || !strings.Contains(err.Error(), "residue") {
+			t.Fatalf("residue %d: got %v", residue, err)
+		}
+	}
+	binary.BigEndian.PutUint32(data[len(data)-20:], 8)
+	if err := s.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// 音调检测的标志只能是 0 或 1
+	data[len(data)-25] = 2
+	if err := s.UnmarshalBinary(data); err == nil || !strings.Contains(err.Error(), "tone detect") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestNewReset(t *testing.T) {
+	if _, err := New(Rate(7), PackingLeft); !errors.Is(err, ErrInvalidRate) {
+		t.Fatalf("got %v, want ErrInvalidRate", err)
+	}
+	if _, err := New(Rate32kbps, PackingType(9)); !errors.Is(err, ErrInvalidPacking) {
+		t.Fatalf("got %v, want ErrInvalidPacking", err)
+	}
+
+	s, err := New(Rate24kbps, PackingRight, WithSampleLimit(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Encode(make([]int16, 5)); !errors.Is(err, ErrInputLength) {
+		t.Fatalf("got %v, want ErrInputLength", err)
+	}
+	if _, err = s.Decode(make([]byte, 4)); !errors.Is(err, ErrInputLength) {
+		t.Fatalf("got %v, want ErrInputLength", err)
+	}
+
+	pcm := testSignal(333)
+	want := s.EncodeV2(pcm)
+	s.Reset()
+
+	fresh, _ := New(Rate24kbps, PackingRight, WithSampleLimit(100))
+	if !s.Equal(fresh) {
+		t.Fatal("reset state differs from a new state")
+	}
+	if got := s.EncodeV2(pcm); string(got) != string(want) {
+		t.Fatal("reset state encodes differently")
+	}
+}
+
+// samePredictor 比较量化器和预测器的状态
+func samePredictor(s, o *G726_state) bool {
+	return s.yl == o.yl && s.yu == o.yu && s.dms == o.dms && s.dml == o.dml && s.ap == o.ap &&
+		s.a == o.a && s.b == o.b && s.pk == o.pk && s.dq == o.dq && s.sr == o.sr && s.td == o.td &&
+		s.rate == o.rate
+}
+
+func TestSetRate(t *testing.T) {
+	rates := []Rate{Rate32kbps, Rate16kbps, Rate40kbps, Rate24kbps, Rate24kbps, Rate40kbps, Rate16kbps}
+	pcm := testSignal(8000)
+
+	for _, packing := range []PackingType{PackingNone, PackingLeft, PackingRight} {
+		for _, frame := range []int{41, 160} {
+			enc, _ := New(Rate32kbps, packing)
+			dec, _ := New(Rate32kbps, packing)
+
+			// 编码端每帧切换一次速率, 记录每帧结束时的状态和码流长度
+			var stream []byte
+			var states []*G726_state
+			var sizes []int
+			for k := 0; (k+1)*frame <= len(pcm); k++ {
+				if err := enc.SetRate(rates[k%len(rates)]); err != nil {
+					t.Fatal(err)
+				}
+				stream = enc.AppendEncode(stream, pcm[k*frame:(k+1)*frame])
+				states = append(states, enc.Clone())
+
+				size := frame
+				if packing != PackingNone {
+					size = (frame*int(enc.bits_per_sample) + 7) / 8
+				}
+				sizes = append(sizes, size)
+			}
+			stream = enc.AppendFlush(stream)
+
+			total := 0
+			for _, size := range sizes {
+				total += size
+			}
+			if len(stream) != total {
+				t.Fatalf("packing %v frame %d: stream is %d bytes, want %d", packing, frame, len(stream), total)
+			}
+
+			// 解码端在同样的采样点切换速率, 每帧结束时状态必须与编码端相同
+			for k, size := range sizes {
+				if err := dec.SetRate(rates[k%len(rates)]); err != nil {
+					t.Fatal(err)
+				}
+				dec.SetSampleLimit(int64((k + 1) * frame))
+				if n := len(dec.DecodeV2(stream[:size])); n != frame {
+					t.Fatalf("packing %v frame %d: frame %d decoded to %d samples", packing, frame, k, n)
+				}
+				stream = stream[size:]
+
+				if !samePredictor(dec, states[k]) {
+					t.Fatalf("packing %v frame %d: decoder diverged in frame %d", packing, frame, k)
+				}
+			}
+		}
+	}
+
+	s, _ := New(Rate24kbps, PackingLeft)
+	if err := s.SetRate(Rate(4)); !errors.Is(err, ErrInvalidRate) {
+		t.Fatalf("got %v, want ErrInvalidRate", err)
+	}
+	if s.rate != Rate24kbps || s.bits_per_sample != 3 {
+		t.Fatal("failed SetRate changed the rate")
+	}
+}
+
+func TestPrime(t *testing.T) {
+	pcm := testSignal(800)
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		enc, _ := New(rate, PackingRight)
+		dec, _ := New(rate, PackingRight)
+		dec.DecodeV2(enc.EncodeV2(pcm[:400]))
+
+		// 解码端没有收到中间的数据, 用同样的 pcm 补上后与编码端保持一致
+		enc.EncodeV2(pcm[400:600])
+		dec.Prime(pcm[400:600])
+		if !samePredictor(enc, dec) {
+			t.Fatalf("%v: primed decoder differs from the encoder", rate)
+		}
+		if dec.Samples() != 400 {
+			t.Fatalf("%v: Prime changed the sample count", rate)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package g726
 
+import (
+	"math/bits"
+
+	"github.com/general252/g726/g711"
+)
+
 type G726_state struct {
 	yl  int /* Locked or steady state step size multiplier. */
 	yu  int /* Unlocked or non-steady state step size multiplier. */
@@ -23,71 +29,167 @@ type G726_state struct {
 
 	rate            Rate
 	packing         PackingType
+	ext_coding      ExtCoding
 	bs              bitstream_state_t
 	bits_per_sample int32
 
-	fun_encoder func(int) int
-	fun_decoder func(int) int
+	direction    int8  /* 最近一次是编码还是解码, SetRate 据此处理码流中剩余的比特 */
+	samples      int64 /* 已编码或解码的采样点数 */
+	sample_limit int64 /* 解码采样点数上限, 小于0表示不限制 */
+
 }
 
-func G726_init_state(rate Rate, packing PackingType) *G726_state {
+// direction 的取值
+const (
+	dir_none   = 0
+	dir_encode = 1
+	dir_decode = 2
+)
+
+// Option 用于 New 的可选配置
+type Option func(*G726_state)
+
+// WithSampleLimit 设置解码的采样点总数, 参见 SetSampleLimit
+func WithSampleLimit(n int64) Option {
+	return func(state_ptr *G726_state) {
+		state_ptr.sample_limit = n
+	}
+}
+
+// WithExtCoding 设置编码器输入和解码器输出的采样点格式, 默认为 ExtCodingLinear
+// 设置为A律或μ律时, 解码器按 G.726 4.2.7 做同步编码调整, 多次 G.711/G.726 转码不会累积失真
+func WithExtCoding(c ExtCoding) Option {
+	return func(state_ptr *G726_state) {
+		state_ptr.ext_coding = c
+	}
+}
+
+// New 创建编解码器状态, rate, packing 或 ext coding 无效时返回 ErrInvalidRate, ErrInvalidPacking 或 ErrInvalidCoding
+func New(rate Rate, packing PackingType, opts ...Option) (*G726_state, error) {
+	if !packing.valid() {
+		return nil, ErrInvalidPacking
+	}
+
 	var state_ptr = &G726_state{
-		yl:  34816,
-		yu:  544,
-		dms: 0,
-		dml: 0,
-		ap:  0,
-		a:   [2]int{},
-		b:   [6]int{},
-		pk:  [2]int{},
-		dq:  [6]int16{32, 32, 32, 32, 32, 32},
-		sr:  [2]int{32, 32},
-		td:  0,
-
-		rate:    rate,
-		packing: packing,
-		bs: bitstream_state_t{
-			bitstream: 0,
-			residue:   0,
-		},
-	}
-
-	switch rate {
+		rate:         rate,
+		packing:      packing,
+		sample_limit: -1,
+	}
+	if !state_ptr.bind() {
+		return nil, ErrInvalidRate
+	}
+	state_ptr.reset()
+
+	for _, opt := range opts {
+		opt(state_ptr)
+	}
+	if !state_ptr.ext_coding.valid() {
+		return nil, ErrInvalidCoding
+	}
+
+	return state_ptr, nil
+}
+
+// G726_init_state 创建编解码器状态, rate 或 packing 无效时 panic
+func G726_init_state(rate Rate, packing PackingType) *G726_state {
+	state_ptr, err := New(rate, packing)
+	if err != nil {
+		panic(err)
+	}
+
+	return state_ptr
+}
+
+// Reset 把状态恢复为ITU规定的初始值, 并清空码流中剩余的比特和采样点计数
+// rate, packing 以及 New 的配置保持不变, 可用于复用同一个状态对象
+func (state_ptr *G726_state) Reset() {
+	state_ptr.reset()
+	state_ptr.samples = 0
+}
+
+func (state_ptr *G726_state) reset() {
+	state_ptr.yl = 34816
+	state_ptr.yu = 544
+	state_ptr.dms = 0
+	state_ptr.dml = 0
+	state_ptr.ap = 0
+	state_ptr.a = [2]int{}
+	state_ptr.b = [6]int{}
+	state_ptr.pk = [2]int{}
+	state_ptr.dq = [6]int16{32, 32, 32, 32, 32, 32}
+	state_ptr.sr = [2]int{32, 32}
+	state_ptr.td = 0
+
+	state_ptr.bs = bitstream_state_t{
+		bitstream: 0,
+		residue:   0,
+	}
+	state_ptr.direction = dir_none
+}
+
+// SetRate 在当前采样点处切换速率, 自适应量化器和预测器的状态(yl, yu, a, b 等)保持不变
+// 编码端: 码流中剩余的比特补零到字节边界, 在下一次编码或 Flush 时输出, 新速率的码字从新的字节开始
+// 解码端: 丢弃当前字节中未读的比特(编码端补的零), 因此解码端需要正好停在切换的采样点上,
+// 例如每次解码一个完整的帧, 或者用 SetSampleLimit 限制解码的采样点数
+func (state_ptr *G726_state) SetRate(rate Rate) error {
+	s := state_ptr
+	old := s.rate
+	s.rate = rate
+	if !s.bind() {
+		s.rate = old
+		s.bind()
+		return ErrInvalidRate
+	}
+
+	switch s.direction {
+	case dir_encode:
+		if s.bs.residue > 0 && s.bs.residue < 8 {
+			if s.packing == PackingLeft {
+				s.bs.bitstream <<= uint32(8 - s.bs.residue)
+			}
+			s.bs.residue = 8
+		}
+	case dir_decode:
+		s.bs.bitstream = 0
+		s.bs.residue = 0
+	}
+	return nil
+}
+
+// bind 根据 rate 设置每个采样点的比特数
+func (state_ptr *G726_state) bind() bool {
+	switch state_ptr.rate {
 	case Rate16kbps:
 		state_ptr.bits_per_sample = 2
-		state_ptr.fun_encoder = state_ptr.g726_16_encoder
-		state_ptr.fun_decoder = state_ptr.g726_16_decoder
 	case Rate24kbps:
 		state_ptr.bits_per_sample = 3
-		state_ptr.fun_encoder = state_ptr.g726_24_encoder
-		state_ptr.fun_decoder = state_ptr.g726_24_decoder
 	case Rate32kbps:
 		state_ptr.bits_per_sample = 4
-		state_ptr.fun_encoder = state_ptr.g726_32_encoder
-		state_ptr.fun_decoder = state_ptr.g726_32_decoder
 	case Rate40kbps:
 		state_ptr.bits_per_sample = 5
-		state_ptr.fun_encoder = state_ptr.g726_40_encoder
-		state_ptr.fun_decoder = state_ptr.g726_40_decoder
 	default:
-		panic("invalid rate")
+		return false
 	}
+	return true
+}
 
-	return state_ptr
+// linear_input 把输入采样点转换为14位线性值
+func (state_ptr *G726_state) linear_input(sl int) int {
+	switch state_ptr.ext_coding {
+	case ExtCodingALaw:
+		return int(g711.DecodeALaw(uint8(sl))) >> 2
+	case ExtCodingULaw:
+		return int(g711.DecodeULaw(uint8(sl))) >> 2
+	}
+	return sl >> 2
 }
 
 func (state_ptr *G726_state) predictor_zero() int {
-	var (
-		i    int
-		sezi int
-	)
+	b, dq := &state_ptr.b, &state_ptr.dq
 
-	sezi = fmult(state_ptr.b[0]>>2, int(state_ptr.dq[0]))
-	for i = 1; i < 6; i++ { /* ACCUM */
-		sezi += fmult(state_ptr.b[i]>>2, int(state_ptr.dq[i]))
-	}
-
-	return sezi
+	/* ACCUM */
+	return fmult(b[0]>>2, int(dq[0])) + fmult(b[1]>>2, int(dq[1])) + fmult(b[2]>>2, int(dq[2])) +
+		fmult(b[3]>>2, int(dq[3])) + fmult(b[4]>>2, int(dq[4])) + fmult(b[5]>>2, int(dq[5]))
 }
 
 func (state_ptr *G726_state) predictor_pole() int {
@@ -126,8 +228,11 @@ func quantize(d, y int, table []int) int {
 		i    int
 	)
 
-	dqm = ABS(d)
-	exp = quan(dqm>>1, power2)
+	dqm = d
+	if d < 0 {
+		dqm = -d
+	}
+	exp = quan_pow2(dqm >> 1)
 
 	mant = ((dqm << 7) >> exp) & 0x7F /* Fractional portion. */
 	dl = (exp << 7) + mant
@@ -157,13 +262,16 @@ func reconstruct(sign, dqln, y int) int {
 	dql = dqln + (y >> 2) /* ADDA */
 
 	if dql < 0 {
-		return IfElse[int](sign != 0, -0x8000, 0)
+		dq = 0
 	} else { /* ANTILOG */
 		dex = (dql >> 7) & 15
 		dqt = 128 + (dql & 127)
 		dq = (dqt << 7) >> (14 - dex)
-		return IfElse(sign != 0, dq-0x8000, dq)
 	}
+	if sign != 0 {
+		dq -= 0x8000
+	}
+	return dq
 }
 
 func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
@@ -180,15 +288,20 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 		pk0                int
 	)
 
-	pk0 = IfElse[int](dqsez < 0, 1, 0) /* needed in updating predictor poles */
+	if dqsez < 0 { /* needed in updating predictor poles */
+		pk0 = 1
+	}
 
 	mag = dq & 0x7FFF /* prediction difference magnitude */
 	/* TRANS */
-	ylint = state_ptr.yl >> 15                  /* exponent part of yl */
-	ylfrac = (state_ptr.yl >> 10) & 0x1F        /* fractional part of yl */
-	thr1 = (32 + ylfrac) << ylint               /* threshold */
-	thr2 = IfElse[int](ylint > 9, 31<<10, thr1) /* limit thr2 to 31 << 10 */
-	dqthr = (thr2 + (thr2 >> 1)) >> 1           /* dqthr = 0.75 * thr2 */
+	ylint = state_ptr.yl >> 15           /* exponent part of yl */
+	ylfrac = (state_ptr.yl >> 10) & 0x1F /* fractional part of yl */
+	thr1 = (32 + ylfrac) << ylint        /* threshold */
+	thr2 = thr1
+	if ylint > 9 { /* limit thr2 to 31 << 10 */
+		thr2 = 31 << 10
+	}
+	dqthr = (thr2 + (thr2 >> 1)) >> 1 /* dqthr = 0.75 * thr2 */
 
 	if state_ptr.td == 0 { /* signal supposed voice */
 		tr = 0
@@ -215,9 +328,6 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 
 	/* FILTE & DELAY */
 	/* update steady state step size multiplier */
-	t1 := state_ptr.yu + ((-state_ptr.yl) >> 6)
-	t2 := state_ptr.yl + t1
-	_ = t2
 	state_ptr.yl += state_ptr.yu + ((-state_ptr.yl) >> 6)
 
 	/*
@@ -239,7 +349,10 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 		/* update predictor pole a[1] */
 		a2p = state_ptr.a[1] - (state_ptr.a[1] >> 7)
 		if dqsez != 0 {
-			fa1 = IfElse[int](pks1 != 0, state_ptr.a[0], -state_ptr.a[0])
+			fa1 = -state_ptr.a[0]
+			if pks1 != 0 {
+				fa1 = state_ptr.a[0]
+			}
 			if fa1 < -8191 { /* a2p = function of fa1 */
 				a2p -= 0x100
 			} else if fa1 > 8191 {
@@ -288,18 +401,19 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 		}
 
 		/* UPB : update predictor zeros b[6] */
-		for cnt = 0; cnt < 6; cnt++ {
-			if code_size == 5 { /* for 40Kbps G.723 */
-				state_ptr.b[cnt] -= state_ptr.b[cnt] >> 9
-			} else { /* for G.721 and 24Kbps G.723 */
-				state_ptr.b[cnt] -= state_ptr.b[cnt] >> 8
-				if (dq & 0x7FFF) != 0 { /* XOR */
-					if (dq ^ int(state_ptr.dq[cnt])) >= 0 {
-						state_ptr.b[cnt] += 128
-					} else {
-						state_ptr.b[cnt] -= 128
-					}
-				}
+		leak := uint(8)
+		if code_size == 5 { /* for 40Kbps G.723 */
+			leak = 9
+		}
+		if mag != 0 {
+			for cnt = 0; cnt < 6; cnt++ {
+				state_ptr.b[cnt] -= state_ptr.b[cnt] >> leak
+				/* XOR: +128 if signs agree, -128 otherwise */
+				state_ptr.b[cnt] += 128 - (((dq ^ int(state_ptr.dq[cnt])) >> 63) & 256)
+			}
+		} else {
+			for cnt = 0; cnt < 6; cnt++ {
+				state_ptr.b[cnt] -= state_ptr.b[cnt] >> leak
 			}
 		}
 	}
@@ -310,11 +424,18 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 
 	/* FLOAT A : convert dq[0] to 4-bit exp, 6-bit mantissa f.p. */
 	if mag == 0 {
-		u := uint16(0xFC20)
-		state_ptr.dq[0] = IfElse[int16](dq >= 0, 0x20, int16(u))
+		if dq >= 0 {
+			state_ptr.dq[0] = 0x20
+		} else {
+			state_ptr.dq[0] = -0x3E0 /* 0xFC20 */
+		}
 	} else {
-		exp = quan(mag, power2)
-		state_ptr.dq[0] = IfElse[int16](dq >= 0, int16((exp<<6)+((mag<<6)>>exp)), int16((exp<<6)+((mag<<6)>>exp)-0x400))
+		exp = quan_pow2(mag)
+		if dq >= 0 {
+			state_ptr.dq[0] = int16((exp << 6) + ((mag << 6) >> exp))
+		} else {
+			state_ptr.dq[0] = int16((exp << 6) + ((mag << 6) >> exp) - 0x400)
+		}
 	}
 
 	state_ptr.sr[1] = state_ptr.sr[0]
@@ -322,11 +443,11 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 	if sr == 0 {
 		state_ptr.sr[0] = 0x20
 	} else if sr > 0 {
-		exp = quan(sr, power2)
+		exp = quan_pow2(sr)
 		state_ptr.sr[0] = (exp << 6) + ((sr << 6) >> exp)
 	} else if sr > -32768 {
 		mag = -sr
-		exp = quan(mag, power2)
+		exp = quan_pow2(mag)
 		state_ptr.sr[0] = (exp << 6) + ((mag << 6) >> exp) - 0x400
 	} else {
 		state_ptr.sr[0] = 0xFC20
@@ -366,6 +487,7 @@ func (state_ptr *G726_state) update(code_size, y, wi, fi, dq, sr, dqsez int) {
 
 var power2 = []int{1, 2, 4, 8, 0x10, 0x20, 0x40, 0x80, 0x100, 0x200, 0x400, 0x800, 0x1000, 0x2000, 0x4000}
 
+// quan 返回 table 中不大于 val 的元素个数, table 为升序
 func quan(val int, table []int) int {
 	for i := 0; i < len(table); i++ {
 		if val < table[i] {
@@ -375,36 +497,47 @@ func quan(val int, table []int) int {
 	return len(table)
 }
 
+// quan_pow2 等价于 quan(val, power2), 即 val 的二进制位数(最大为15)
+func quan_pow2(val int) int {
+	if val <= 0 {
+		return 0
+	}
+	n := bits.Len(uint(val))
+	if n > len(power2) {
+		n = len(power2)
+	}
+	return n
+}
+
+/*
+ * returns the integer product of the 14-bit integer "an" and
+ * "floating point" representation (4-bit exponent, 6-bit mantessa) "srn".
+ */
 func fmult(an, srn int) int {
-	var (
-		anmag   int
-		anexp   int
-		anmant  int
-		wanexp  int
-		wanmant int
-		retval  int
-	)
+	anmag := an
+	if an <= 0 {
+		anmag = -an & 0x1FFF
+	}
 
-	anmag = IfElse[int](an > 0, an, (-an)&0x1FFF)
-	anexp = quan(anmag, power2) - 6
+	/*
+	 * anmant 为 anmag 规格化后的6位尾数, anmag 为0时取32
+	 * an 不超过14位, 所以 anlen 与 quan(anmag, power2) 相同
+	 */
+	anlen := bits.Len(uint(anmag))
+	anmant := (anmag<<6)>>anlen | 32>>(anlen<<3)
 
-	if anmag == 0 {
-		anmant = 32
-	} else if anexp >= 0 {
-		anmant = anmag >> anexp
-	} else {
-		anmant = anmag << -anexp
-	}
-	wanexp = anexp + ((srn >> 6) & 0xF) - 13
+	/*
+	 * wanexp = anlen + srnexp - 19
+	 * wanexp >= 0 时 retval = (wanmant << wanexp) & 0x7FFF, 否则 retval = wanmant >> -wanexp,
+	 * 两种情况都等于 ((wanmant << (anlen + srnexp)) >> 19) & 0x7FFF
+	 */
+	wanmant := int64(anmant*(srn&077)+0x30) >> 4
+	retval := int(wanmant<<(anlen+(srn>>6)&0xF)>>19) & 0x7FFF
 
-	wanmant = (anmant*(srn&077) + 0x30) >> 4
-	if wanexp >= 0 {
-		retval = (wanmant << wanexp) & 0x7FFF
-	} else {
-		retval = wanmant >> -wanexp
+	if (an ^ srn) < 0 {
+		return -retval
 	}
-
-	return IfElse((an^srn) < 0, -retval, retval)
+	return retval
 }
 
 type bitstream_state_t struct {
@@ -422,8 +555,8 @@ func IfElse[T any](b bool, t, f T) T {
 
 type Compare interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 |
-	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
-	~float32 | ~float64
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
 }
 
 func ABS[T Compare](a T) T {
@@ -0,0 +1,152 @@
+package g726
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// goldenInput 返回用于比对输出的固定输入: 示例音频 + 正弦波 + 噪声 + 削波信号
+func goldenInput(t testing.TB) []int16 {
+	data, err := os.ReadFile("example/audio-samples.pcm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm := AppendPcm8ToPcm16(nil, data)
+	if len(pcm) > 80000 {
+		pcm = pcm[:80000]
+	}
+	pcm = append(pcm, testSignal(8000)...)
+
+	rnd := rand.New(rand.NewSource(726))
+	for i := 0; i < 8000; i++ {
+		pcm = append(pcm, int16(rnd.Intn(65536)-32768))
+	}
+	for i := 0; i < 8000; i++ {
+		if (i/40)%2 == 0 {
+			pcm = append(pcm, 32767)
+		} else {
+			pcm = append(pcm, -32768)
+		}
+	}
+	return pcm
+}
+
+// goldenBitstream 返回随机码流, 用于覆盖解码器的全部码字
+func goldenBitstream() []byte {
+	rnd := rand.New(rand.NewSource(623))
+	data := make([]byte, 40000)
+	rnd.Read(data)
+	return data
+}
+
+func digest(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:8])
+}
+
+func digest16(pcm []int16) string {
+	return digest(AppendPcm16ToPcm8(nil, pcm))
+}
+
+// 以下摘要由优化前的实现生成, 用于保证输出逐比特一致
+// 40kbps 的摘要在修正预测器零点 b[] 的更新之后重新生成, 与 spandsp 的输出一致
+var goldenDigests = map[string]string{
+	"16kbps/group/encode": "2f89269dcff80eb4",
+	"16kbps/group/random": "202811a905b1da82",
+	"16kbps/left/decode":  "916763715ece99af",
+	"16kbps/left/encode":  "2f89269dcff80eb4",
+	"16kbps/left/random":  "dd1835262aa925e7",
+	"16kbps/none/decode":  "916763715ece99af",
+	"16kbps/none/encode":  "95210e22563d2ec2",
+	"16kbps/none/random":  "b2633b3322fa5e79",
+	"16kbps/right/decode": "916763715ece99af",
+	"16kbps/right/encode": "1098eb3fae443624",
+	"16kbps/right/random": "424bf65a53265e96",
+	"24kbps/group/encode": "821174d166610eaa",
+	"24kbps/group/random": "7506ec26aa925255",
+	"24kbps/left/decode":  "33cb6437e33729a9",
+	"24kbps/left/encode":  "821174d166610eaa",
+	"24kbps/left/random":  "b250011bd9d39b07",
+	"24kbps/none/decode":  "33cb6437e33729a9",
+	"24kbps/none/encode":  "b48818acbe9a5bab",
+	"24kbps/none/random":  "fda91a491c15c5ab",
+	"24kbps/right/decode": "33cb6437e33729a9",
+	"24kbps/right/encode": "10a938ff7cd6166e",
+	"24kbps/right/random": "50d4de8a5b7fd109",
+	"32kbps/group/encode": "eaba31ee2ec4fa7c",
+	"32kbps/group/random": "4e0ed501786e9e78",
+	"32kbps/left/decode":  "d361070be674e77c",
+	"32kbps/left/encode":  "eaba31ee2ec4fa7c",
+	"32kbps/left/random":  "afda6f5deb3d7648",
+	"32kbps/none/decode":  "d361070be674e77c",
+	"32kbps/none/encode":  "667d85ccc7a4c4a9",
+	"32kbps/none/random":  "da6a3abc9973d121",
+	"32kbps/right/decode": "d361070be674e77c",
+	"32kbps/right/encode": "2e1f4dd61ae0387d",
+	"32kbps/right/random": "0ce79b3088536803",
+	"40kbps/group/encode": "2876901c39bf3252",
+	"40kbps/group/random": "9ca3b025a90202aa",
+	"40kbps/left/decode":  "1f0b39509b7071bb",
+	"40kbps/left/encode":  "2876901c39bf3252",
+	"40kbps/left/random":  "6bec397f5a6c6d14",
+	"40kbps/none/decode":  "1f0b39509b7071bb",
+	"40kbps/none/encode":  "97bdad3b2af31026",
+	"40kbps/none/random":  "b9b6d3bf32437627",
+	"40kbps/right/decode": "1f0b39509b7071bb",
+	"40kbps/right/encode": "b9e03ce2466a2dbe",
+	"40kbps/right/random": "0a4030c2af8fba68",
+}
+
+func TestGolden(t *testing.T) {
+	pcm := goldenInput(t)
+	random := goldenBitstream()
+
+	got := map[string]string{}
+	for _, packing := range []PackingType{PackingNone, PackingLeft, PackingRight} {
+		for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+			key := rate.String() + "/" + []string{"none", "left", "right"}[packing]
+
+			enc := G726_init_state(rate, packing)
+			data := enc.AppendFlush(enc.EncodeV2(pcm))
+			got[key+"/encode"] = digest(data)
+
+			dec := G726_init_state(rate, packing)
+			got[key+"/decode"] = digest16(dec.DecodeV2(data))
+
+			dec = G726_init_state(rate, packing)
+			got[key+"/random"] = digest16(dec.DecodeV2(random))
+		}
+	}
+
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		key := rate.String() + "/group"
+		enc := G726_init_state(rate, PackingLeft)
+		data, err := enc.Encode(pcm[:len(pcm)/8*8])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[key+"/encode"] = digest(data)
+
+		dec := G726_init_state(rate, PackingLeft)
+		out, err := dec.Decode(random[:len(random)/15*15])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[key+"/random"] = digest16(out)
+	}
+
+	for key, want := range goldenDigests {
+		if got[key] != want {
+			t.Errorf("%s: got %s, want %s", key, got[key], want)
+		}
+	}
+	if len(goldenDigests) != len(got) {
+		for key, v := range got {
+			t.Logf("%q: %q,", key, v)
+		}
+		t.Errorf("got %d digests, want %d", len(got), len(goldenDigests))
+	}
+}
@@ -0,0 +1,75 @@
+package conformance
+
+import (
+	"github.com/general252/g726"
+	"github.com/general252/g726/spandsp"
+)
+
+// Codecs returns adapters for every G.726 implementation in the module.
+func Codecs() []Codec {
+	return []Codec{rootCodec{}, spandspCodec{}}
+}
+
+// rootCodec drives g726.G726_state.
+type rootCodec struct{}
+
+func (rootCodec) Name() string { return "g726" }
+
+func (rootCodec) Encode(rate int, law Law, samples []int16) ([]byte, error) {
+	s, err := g726.New(g726.Rate(rate/8-2), g726.PackingNone, g726.WithExtCoding(rootCoding(law)))
+	if err != nil {
+		return nil, err
+	}
+	return s.AppendEncode(nil, samples), nil
+}
+
+func (rootCodec) Decode(rate int, law Law, codes []byte) ([]int16, error) {
+	s, err := g726.New(g726.Rate(rate/8-2), g726.PackingNone, g726.WithExtCoding(rootCoding(law)))
+	if err != nil {
+		return nil, err
+	}
+	return s.AppendDecode(nil, codes), nil
+}
+
+func rootCoding(law Law) g726.ExtCoding {
+	switch law {
+	case ALaw:
+		return g726.ExtCodingALaw
+	case ULaw:
+		return g726.ExtCodingULaw
+	default:
+		return g726.ExtCodingLinear
+	}
+}
+
+// spandspCodec drives spandsp.NewG726State.
+type spandspCodec struct{}
+
+func (spandspCodec) Name() string { return "spandsp" }
+
+func (spandspCodec) Encode(rate int, law Law, samples []int16) ([]byte, error) {
+	s, err := spandsp.NewG726State(rate*1000, spandspCoding(law), spandsp.PackingNone)
+	if err != nil {
+		return nil, err
+	}
+	return s.Encode(samples), nil
+}
+
+func (spandspCodec) Decode(rate int, law Law, codes []byte) ([]int16, error) {
+	s, err := spandsp.NewG726State(rate*1000, spandspCoding(law), spandsp.PackingNone)
+	if err != nil {
+		return nil, err
+	}
+	return s.Decode(codes), nil
+}
+
+func spandspCoding(law Law) spandsp.Encoding {
+	switch law {
+	case ALaw:
+		return spandsp.EncodingALaw
+	case ULaw:
+		return spandsp.EncodingULaw
+	default:
+		return spandsp.EncodingLinear
+	}
+}
@@ -0,0 +1,381 @@
+// Package conformance runs the ITU-T G.726 Appendix II test sequences
+// through the codecs in this module and reports per-vector mismatches.
+//
+// The test sequences are not distributed with this module. Copy them into a
+// directory (by default testdata/itu next to this file, or the directory
+// named by the G726_ITU_VECTORS environment variable) and run go test.
+//
+// Without a manifest the standard file names are used:
+//
+//	nrm.a, nrm.m, ovr.a, ovr.m          G.711 encoder inputs (normal / overload)
+//	rn16fa.i ... rv40fm.i               ADPCM codes, one per sample
+//	rn16fa.o ... rv40fm.o               decoder output, same law as the input
+//	rn16fx.o ... rv40fx.o               A-law codes decoded to u-law output
+//	rn16fc.o ... rv40fc.o               u-law codes decoded to A-law output
+//	i16 ... i40, ri16fa.o ... ri40fm.o  decoder-only sequences
+//
+// Missing files are ignored. A file named vectors.txt replaces the default
+// list; each line is
+//
+//	enc <rate> <input law> <input file> <expected codes>
+//	dec <rate> <output law> <input codes> <expected output>
+//
+// where rate is 16, 24, 32 or 40 and law is a (A-law), m (u-law) or l
+// (16-bit little-endian linear). Lines starting with # are comments.
+//
+// Files holding one sample per byte and files holding one sample per 16-bit
+// word (either byte order, as produced by the ITU-T STL tools) are both
+// accepted.
+package conformance
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Law is the sample coding at the PCM side of the codec.
+type Law int
+
+const (
+	Linear Law = iota
+	ALaw
+	ULaw
+)
+
+func (l Law) String() string {
+	switch l {
+	case Linear:
+		return "linear"
+	case ALaw:
+		return "A-law"
+	case ULaw:
+		return "u-law"
+	default:
+		return "unknown"
+	}
+}
+
+// Direction says whether a vector checks the encoder or the decoder.
+type Direction int
+
+const (
+	Encode Direction = iota
+	Decode
+)
+
+// Vector is one input/expected-output pair.
+type Vector struct {
+	Dir      Direction
+	Rate     int // kbit/s: 16, 24, 32 or 40
+	Law      Law // input law for Encode, output law for Decode
+	Input    string
+	Expected string
+}
+
+func (v Vector) String() string {
+	dir := "enc"
+	if v.Dir == Decode {
+		dir = "dec"
+	}
+	return fmt.Sprintf("%s-%d-%s-%s", dir, v.Rate, v.Law, filepath.Base(v.Expected))
+}
+
+// ErrUnsupported is returned by a Codec that cannot run a vector.
+var ErrUnsupported = errors.New("unsupported by codec")
+
+// Codec adapts a G.726 implementation to the harness. Encode returns one
+// ADPCM code per input sample. For G.711 laws the samples carry the octets
+// in their low 8 bits.
+type Codec interface {
+	Name() string
+	Encode(rate int, law Law, samples []int16) ([]byte, error)
+	Decode(rate int, law Law, codes []byte) ([]int16, error)
+}
+
+// Result is the outcome of one vector on one codec.
+type Result struct {
+	Vector  Vector
+	Codec   string
+	Skipped string // reason, if the vector was not run
+
+	Samples    int
+	Mismatches int
+	First      int // index of the first mismatch, -1 if none
+	Got, Want  int // values at First
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Skipped != "":
+		return fmt.Sprintf("%s %s: skipped: %s", r.Codec, r.Vector, r.Skipped)
+	case r.Mismatches == 0:
+		return fmt.Sprintf("%s %s: ok, %d samples", r.Codec, r.Vector, r.Samples)
+	default:
+		return fmt.Sprintf("%s %s: %d of %d samples differ, first at %d: got %#x, want %#x",
+			r.Codec, r.Vector, r.Mismatches, r.Samples, r.First, r.Got, r.Want)
+	}
+}
+
+// Load returns the vectors present in dir.
+func Load(dir string) ([]Vector, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			files[strings.ToLower(e.Name())] = filepath.Join(dir, e.Name())
+		}
+	}
+
+	if manifest, ok := files["vectors.txt"]; ok {
+		return loadManifest(manifest, files)
+	}
+
+	var vectors []Vector
+	add := func(dir Direction, rate int, law Law, input, expected string) {
+		in, ok1 := files[input]
+		exp, ok2 := files[expected]
+		if ok1 && ok2 {
+			vectors = append(vectors, Vector{Dir: dir, Rate: rate, Law: law, Input: in, Expected: exp})
+		}
+	}
+	for _, rate := range []int{16, 24, 32, 40} {
+		for _, seq := range []string{"n", "v"} {
+			input := map[string]string{"n": "nrm", "v": "ovr"}[seq]
+			prefix := fmt.Sprintf("r%s%df", seq, rate)
+
+			add(Encode, rate, ALaw, input+".a", prefix+"a.i")
+			add(Encode, rate, ULaw, input+".m", prefix+"m.i")
+			add(Decode, rate, ALaw, prefix+"a.i", prefix+"a.o")
+			add(Decode, rate, ULaw, prefix+"m.i", prefix+"m.o")
+			add(Decode, rate, ULaw, prefix+"a.i", prefix+"x.o")
+			add(Decode, rate, ALaw, prefix+"m.i", prefix+"c.o")
+		}
+		add(Decode, rate, ALaw, fmt.Sprintf("i%d", rate), fmt.Sprintf("ri%dfa.o", rate))
+		add(Decode, rate, ULaw, fmt.Sprintf("i%d", rate), fmt.Sprintf("ri%dfm.o", rate))
+	}
+
+	return vectors, nil
+}
+
+func loadManifest(name string, files map[string]string) ([]Vector, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vectors []Vector
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%s:%d: want 5 fields, got %d", name, line, len(fields))
+		}
+
+		var v Vector
+		switch fields[0] {
+		case "enc":
+			v.Dir = Encode
+		case "dec":
+			v.Dir = Decode
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown direction %q", name, line, fields[0])
+		}
+		if v.Rate, err = strconv.Atoi(fields[1]); err != nil || v.Rate < 16 || v.Rate > 40 || v.Rate%8 != 0 {
+			return nil, fmt.Errorf("%s:%d: invalid rate %q", name, line, fields[1])
+		}
+		switch fields[2] {
+		case "a":
+			v.Law = ALaw
+		case "m", "u":
+			v.Law = ULaw
+		case "l":
+			v.Law = Linear
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown law %q", name, line, fields[2])
+		}
+
+		var ok bool
+		if v.Input, ok = files[strings.ToLower(fields[3])]; !ok {
+			return nil, fmt.Errorf("%s:%d: missing file %s", name, line, fields[3])
+		}
+		if v.Expected, ok = files[strings.ToLower(fields[4])]; !ok {
+			return nil, fmt.Errorf("%s:%d: missing file %s", name, line, fields[4])
+		}
+		vectors = append(vectors, v)
+	}
+
+	return vectors, scanner.Err()
+}
+
+// Run runs v through c and compares the output with the expected file.
+func Run(c Codec, v Vector) (Result, error) {
+	r := Result{Vector: v, Codec: c.Name(), First: -1}
+
+	var got, want []int
+	switch v.Dir {
+	case Encode:
+		in, err := readSamples(v.Input, v.Law)
+		if err != nil {
+			return r, err
+		}
+		if want, err = readCodes(v.Expected); err != nil {
+			return r, err
+		}
+
+		codes, err := c.Encode(v.Rate, v.Law, in)
+		if errors.Is(err, ErrUnsupported) {
+			r.Skipped = err.Error()
+			return r, nil
+		} else if err != nil {
+			return r, err
+		}
+		for _, code := range codes {
+			got = append(got, int(code))
+		}
+	case Decode:
+		codes, err := readCodes(v.Input)
+		if err != nil {
+			return r, err
+		}
+		expected, err := readSamples(v.Expected, v.Law)
+		if err != nil {
+			return r, err
+		}
+
+		in := make([]byte, len(codes))
+		for i, code := range codes {
+			in[i] = byte(code)
+		}
+		out, err := c.Decode(v.Rate, v.Law, in)
+		if errors.Is(err, ErrUnsupported) {
+			r.Skipped = err.Error()
+			return r, nil
+		} else if err != nil {
+			return r, err
+		}
+		for _, s := range out {
+			got = append(got, sampleValue(s, v.Law))
+		}
+		for _, s := range expected {
+			want = append(want, sampleValue(s, v.Law))
+		}
+	}
+
+	r.Samples = len(want)
+	for i := range want {
+		g := -1
+		if i < len(got) {
+			g = got[i]
+		}
+		if g != want[i] {
+			if r.Mismatches == 0 {
+				r.First, r.Got, r.Want = i, g, want[i]
+			}
+			r.Mismatches++
+		}
+	}
+	if len(got) > len(want) {
+		if r.Mismatches == 0 {
+			r.First, r.Got, r.Want = len(want), got[len(want)], -1
+		}
+		r.Mismatches += len(got) - len(want)
+	}
+
+	return r, nil
+}
+
+func sampleValue(s int16, law Law) int {
+	if law == Linear {
+		return int(s)
+	}
+	return int(s) & 0xFF
+}
+
+// readCodes reads an ADPCM code file, one code per sample.
+func readCodes(name string) ([]int, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	values := unpackBytes(data)
+	for i := range values {
+		values[i] &= 0x1F
+	}
+	return values, nil
+}
+
+// readSamples reads a G.711 or linear sample file.
+func readSamples(name string, law Law) ([]int16, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if law == Linear {
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("%s: odd length for 16-bit linear samples", name)
+		}
+		out := make([]int16, len(data)/2)
+		for i := range out {
+			out[i] = int16(uint16(data[2*i]) | uint16(data[2*i+1])<<8)
+		}
+		return out, nil
+	}
+
+	values := unpackBytes(data)
+	out := make([]int16, len(values))
+	for i, v := range values {
+		out[i] = int16(v & 0xFF)
+	}
+	return out, nil
+}
+
+// unpackBytes returns one value per sample for files holding either one
+// sample per byte or one sample per 16-bit word. Word files are recognised by
+// their high bytes all being zero.
+func unpackBytes(data []byte) []int {
+	if len(data) >= 2 && len(data)%2 == 0 {
+		le, be := true, true
+		for i := 0; i < len(data); i += 2 {
+			if data[i+1] != 0 {
+				le = false
+			}
+			if data[i] != 0 {
+				be = false
+			}
+		}
+
+		if le != be {
+			off := 0
+			if be {
+				off = 1
+			}
+			out := make([]int, len(data)/2)
+			for i := range out {
+				out[i] = int(data[2*i+off])
+			}
+			return out
+		}
+	}
+
+	out := make([]int, len(data))
+	for i, b := range data {
+		out[i] = int(b)
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package conformance
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestITUVectors runs the ITU-T test sequences when they are available.
+func TestITUVectors(t *testing.T) {
+	dir := os.Getenv("G726_ITU_VECTORS")
+	if dir == "" {
+		dir = filepath.Join("testdata", "itu")
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Skipf("no test sequences in %s", dir)
+	}
+
+	vectors, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatalf("no test sequences found in %s", dir)
+	}
+
+	for _, c := range Codecs() {
+		for _, v := range vectors {
+			c, v := c, v
+			t.Run(c.Name()+"/"+v.String(), func(t *testing.T) {
+				r, err := Run(c, v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.Skipped != "" {
+					t.Skip(r.Skipped)
+				}
+				if r.Mismatches != 0 {
+					t.Error(r)
+				}
+			})
+		}
+	}
+}
+
+// TestHarness checks loading, file formats and mismatch reporting against
+// sequences produced by the encoder itself.
+func TestHarness(t *testing.T) {
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(711))
+
+	in := make([]int16, 2000)
+	for i := range in {
+		in[i] = int16(rnd.Intn(256))
+	}
+	codes, err := spandspCodec{}.Encode(32, ALaw, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, err := rootCodec{}.Decode(32, Linear, codes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A-law input as little-endian words, codes as big-endian words, as the STL
+	// tools write them.
+	var nrm, enc, dec []byte
+	for i := range in {
+		nrm = append(nrm, byte(in[i]), 0)
+		enc = append(enc, 0, codes[i])
+		dec = append(dec, byte(pcm[i]), byte(pcm[i]>>8))
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("NRM.A", nrm)
+	write("RN32FA.I", enc)
+
+	vectors, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 1 || vectors[0].Dir != Encode || vectors[0].Rate != 32 || vectors[0].Law != ALaw {
+		t.Fatalf("Load() = %v", vectors)
+	}
+	for _, c := range Codecs() {
+		r, err := Run(c, vectors[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Mismatches != 0 || r.Samples != len(in) {
+			t.Errorf("%v", r)
+		}
+	}
+
+	// A manifest replaces the default names.
+	write("speech.pcm", dec)
+	write("vectors.txt", []byte("# linear decoder check\ndec 32 l rn32fa.i speech.pcm\n"))
+	vectors, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 1 || vectors[0].Dir != Decode || vectors[0].Law != Linear {
+		t.Fatalf("Load() = %v", vectors)
+	}
+
+	// Corrupt two samples and check that both are reported.
+	dec[100] ^= 1
+	dec[301] ^= 0x80
+	write("speech.pcm", dec)
+	r, err := Run(rootCodec{}, vectors[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Mismatches != 2 || r.First != 50 {
+		t.Errorf("%v", r)
+	}
+
+	write("vectors.txt", []byte("dec 33 l rn32fa.i speech.pcm\n"))
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() accepted an invalid rate")
+	}
+}
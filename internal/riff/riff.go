@@ -0,0 +1,240 @@
+// Package riff reads and writes RIFF files (WAV, AVI). Chunk bodies are
+// padded to an even length as the format requires.
+package riff
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// FourCC is a chunk or form identifier.
+type FourCC [4]byte
+
+func (c FourCC) String() string {
+	return string(c[:])
+}
+
+var (
+	RIFF = FourCC{'R', 'I', 'F', 'F'}
+	LIST = FourCC{'L', 'I', 'S', 'T'}
+)
+
+var (
+	ErrFormat   = errors.New("riff: not a RIFF file")
+	ErrTooLarge = errors.New("riff: chunk larger than 4 GiB")
+)
+
+// ReadForm reads the RIFF header and returns the form type and a Reader
+// for the chunks inside the form.
+func ReadForm(r io.Reader) (FourCC, *Reader, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrFormat
+		}
+		return FourCC{}, nil, err
+	}
+	if FourCC(hdr[0:4]) != RIFF {
+		return FourCC{}, nil, ErrFormat
+	}
+	size := binary.LittleEndian.Uint32(hdr[4:8])
+	if size < 4 {
+		return FourCC{}, nil, ErrFormat
+	}
+	return FourCC(hdr[8:12]), NewReader(io.LimitReader(r, int64(size)-4)), nil
+}
+
+// Reader iterates over a sequence of chunks.
+type Reader struct {
+	r    io.Reader
+	body *io.LimitedReader
+	pad  bool
+}
+
+// NewReader returns a Reader for the chunks in r, for example the body of
+// a LIST chunk after its list type.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next skips what is left of the current chunk and returns the ID, size and
+// body of the next one. It returns io.EOF after the last chunk. A truncated
+// final chunk is returned with its full size; reading its body stops early
+// with io.ErrUnexpectedEOF.
+func (r *Reader) Next() (FourCC, uint32, io.Reader, error) {
+	if r.body != nil {
+		skip := r.body.N
+		if r.pad {
+			skip++
+		}
+		if _, err := io.CopyN(io.Discard, r.r, skip); err != nil && err != io.EOF {
+			return FourCC{}, 0, nil, err
+		}
+		r.body = nil
+	}
+
+	var hdr [8]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return FourCC{}, 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[4:])
+	r.body = &io.LimitedReader{R: r.r, N: int64(size)}
+	r.pad = size%2 != 0
+	return FourCC(hdr[:4]), size, &chunkBody{r.body}, nil
+}
+
+// chunkBody reports a chunk cut short by the end of the file.
+type chunkBody struct {
+	r *io.LimitedReader
+}
+
+func (b *chunkBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF && b.r.N > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Writer writes a RIFF form. Chunk sizes are filled in when the chunk is
+// ended, so the destination must be seekable.
+type Writer struct {
+	w      io.WriteSeeker
+	starts []int64 // offsets of the open chunks' size fields
+	base   int64   // offset of the RIFF header
+	pos    int64
+}
+
+// NewWriter writes the RIFF header for form and returns a Writer positioned
+// inside it.
+func NewWriter(w io.WriteSeeker, form FourCC) (*Writer, error) {
+	pos, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	rw := &Writer{w: w, base: pos, pos: pos}
+	if err := rw.StartList(RIFF, form); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// StartChunk opens a chunk. Data written until the matching EndChunk is its
+// body.
+func (w *Writer) StartChunk(id FourCC) error {
+	var hdr [8]byte
+	copy(hdr[:], id[:])
+	w.starts = append(w.starts, w.pos+4)
+	_, err := w.Write(hdr[:])
+	return err
+}
+
+// StartList opens a LIST (or RIFF) chunk of the given list type.
+func (w *Writer) StartList(id, listType FourCC) error {
+	if err := w.StartChunk(id); err != nil {
+		return err
+	}
+	_, err := w.Write(listType[:])
+	return err
+}
+
+// Write appends to the body of the innermost open chunk.
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.pos += int64(n)
+	return n, err
+}
+
+// Offset returns the number of bytes written since the start of the form.
+func (w *Writer) Offset() int64 {
+	return w.pos - w.base
+}
+
+// EndChunk closes the innermost open chunk, writing its size and the pad
+// byte if the body has an odd length.
+func (w *Writer) EndChunk() error {
+	if len(w.starts) == 0 {
+		return errors.New("riff: no open chunk")
+	}
+	start := w.starts[len(w.starts)-1]
+	w.starts = w.starts[:len(w.starts)-1]
+
+	size := w.pos - start - 4
+	if size > 0xFFFFFFFF {
+		return ErrTooLarge
+	}
+	if size%2 != 0 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	return w.patch(start, uint32(size))
+}
+
+// Patch overwrites four bytes at offset (relative to the start of the form)
+// with v, for header fields only known at the end.
+func (w *Writer) Patch(offset int64, v uint32) error {
+	return w.patch(w.base+offset, v)
+}
+
+func (w *Writer) patch(at int64, v uint32) error {
+	if _, err := w.w.Seek(at, io.SeekStart); err != nil {
+		return err
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	if _, err := w.w.Write(b[:]); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(w.pos, io.SeekStart)
+	return err
+}
+
+// Close ends all open chunks, including the RIFF form.
+func (w *Writer) Close() error {
+	for len(w.starts) > 0 {
+		if err := w.EndChunk(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Buffer is an in-memory io.WriteSeeker, for building a file that is then
+// copied to a writer that cannot seek.
+type Buffer struct {
+	buf []byte
+	pos int
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	if end := b.pos + len(p); end > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, end-len(b.buf))...)
+	}
+	n := copy(b.buf[b.pos:], p)
+	b.pos += n
+	return n, nil
+}
+
+func (b *Buffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(b.pos)
+	case io.SeekEnd:
+		offset += int64(len(b.buf))
+	}
+	if offset < 0 {
+		return 0, errors.New("riff: negative position")
+	}
+	b.pos = int(offset)
+	return offset, nil
+}
+
+// Bytes returns the data written so far.
+func (b *Buffer) Bytes() []byte {
+	return b.buf
+}
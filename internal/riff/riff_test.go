@@ -0,0 +1,75 @@
+package riff
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteRead(t *testing.T) {
+	var buf Buffer
+	w, err := NewWriter(&buf, FourCC{'T', 'E', 'S', 'T'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.StartChunk(FourCC{'o', 'd', 'd', ' '})
+	w.Write([]byte{1, 2, 3})
+	w.EndChunk()
+	w.StartList(LIST, FourCC{'s', 'u', 'b', ' '})
+	w.StartChunk(FourCC{'i', 'n', 'n', 'r'})
+	at := w.Offset()
+	w.Write([]byte{0, 0, 0, 0})
+	w.EndChunk()
+	if err := w.Patch(at, 0x04030201); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte("RIFF\x28\x00\x00\x00TESTodd \x03\x00\x00\x00\x01\x02\x03\x00" +
+		"LIST\x10\x00\x00\x00sub innr\x04\x00\x00\x00\x01\x02\x03\x04")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("got  %q\nwant %q", buf.Bytes(), want)
+	}
+
+	form, r, err := ReadForm(bytes.NewReader(buf.Bytes()))
+	if err != nil || form.String() != "TEST" {
+		t.Fatal(form, err)
+	}
+	id, size, _, err := r.Next()
+	if err != nil || id.String() != "odd " || size != 3 {
+		t.Fatal(id, size, err)
+	}
+	// 不读 chunk 的内容直接跳到下一个
+	id, size, body, err := r.Next()
+	if err != nil || id != LIST || size != 16 {
+		t.Fatal(id, size, err)
+	}
+	var listType FourCC
+	io.ReadFull(body, listType[:])
+	sub := NewReader(body)
+	id, _, body, err = sub.Next()
+	data, _ := io.ReadAll(body)
+	if err != nil || id.String() != "innr" || !bytes.Equal(data, []byte{1, 2, 3, 4}) {
+		t.Fatal(id, data, err)
+	}
+	if _, _, _, err := sub.Next(); err != io.EOF {
+		t.Fatal(err)
+	}
+	if _, _, _, err := r.Next(); err != io.EOF {
+		t.Fatal(err)
+	}
+
+	// 截断的 chunk
+	_, r, _ = ReadForm(bytes.NewReader(want[:len(want)-2]))
+	r.Next()
+	_, _, body, _ = r.Next()
+	if _, err := io.ReadAll(body); err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ReadForm(bytes.NewReader([]byte("RIFX\x04\x00\x00\x00TEST"))); err != ErrFormat {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,350 @@
+package g726
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidChannels = errors.New("invalid channel count")
+
+// MultiChannelEncoder 多声道编码器, 每个声道一个独立的 ADPCM 状态, 输入为按声道交织的 PCM
+//
+// 输出可以是每个声道一个码流(EncodePlanar), 也可以是 RFC 3551 4.5.4 的按采样交织布局
+// (EncodeInterleaved): 每个采样时刻依次是各声道的码字, 再按 packing 连续打包.
+// 两种输出各自保留不足一个字节的比特, 同一个编码器应只使用其中一种.
+type MultiChannelEncoder struct {
+	states  []*G726_state // 每声道的编码状态, PackingNone, 输出码字
+	packers []*G726_state // 每声道码流的打包状态
+	packer  *G726_state   // 交织码流的打包状态
+
+	mono  []int16
+	codes [][]byte
+	mixed []byte
+}
+
+// NewMultiChannelEncoder 创建 channels 个声道的编码器, opts 应用于每个声道的状态
+func NewMultiChannelEncoder(rate Rate, packing PackingType, channels int, opts ...Option) (*MultiChannelEncoder, error) {
+	if channels < 1 {
+		return nil, ErrInvalidChannels
+	}
+
+	e := &MultiChannelEncoder{codes: make([][]byte, channels)}
+	var err error
+	if e.states, e.packers, e.packer, err = newChannelStates(rate, packing, channels, opts); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// newChannelStates 创建每声道的编解码状态和打包状态
+func newChannelStates(rate Rate, packing PackingType, channels int, opts []Option) (states, packers []*G726_state, packer *G726_state, err error) {
+	if packer, err = New(rate, packing); err != nil {
+		return nil, nil, nil, err
+	}
+	for i := 0; i < channels; i++ {
+		s, err := New(rate, PackingNone, opts...)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		states = append(states, s)
+		packers = append(packers, packer.Clone())
+	}
+	return states, packers, packer, nil
+}
+
+// Channels 返回声道数
+func (e *MultiChannelEncoder) Channels() int {
+	return len(e.states)
+}
+
+// State 返回第 ch 个声道的编码状态, 它的 packing 总是 PackingNone
+func (e *MultiChannelEncoder) State(ch int) *G726_state {
+	return e.states[ch]
+}
+
+// encode 编码交织的 pcm, 每声道的码字(每个一字节)保存在 e.codes
+func (e *MultiChannelEncoder) encode(pcm []int16) error {
+	channels := len(e.states)
+	if len(pcm)%channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", ErrInputLength, len(pcm), channels)
+	}
+
+	for ch, s := range e.states {
+		e.mono = e.mono[:0]
+		for i := ch; i < len(pcm); i += channels {
+			e.mono = append(e.mono, pcm[i])
+		}
+		e.codes[ch] = s.AppendEncode(e.codes[ch][:0], e.mono)
+	}
+	return nil
+}
+
+// EncodePlanar 编码按声道交织的 pcm, 返回每个声道的码流
+func (e *MultiChannelEncoder) EncodePlanar(pcm []int16) ([][]byte, error) {
+	return e.AppendEncodePlanar(make([][]byte, len(e.states)), pcm)
+}
+
+// AppendEncodePlanar 与 EncodePlanar 相同, 第 ch 个声道的码流追加到 dst[ch]
+// dst 的长度必须等于声道数
+func (e *MultiChannelEncoder) AppendEncodePlanar(dst [][]byte, pcm []int16) ([][]byte, error) {
+	if len(dst) != len(e.states) {
+		return dst, fmt.Errorf("%w: %d outputs for %d channels", ErrInvalidChannels, len(dst), len(e.states))
+	}
+	if err := e.encode(pcm); err != nil {
+		return dst, err
+	}
+
+	for ch, p := range e.packers {
+		dst[ch] = p.pack(dst[ch], e.codes[ch])
+	}
+	return dst, nil
+}
+
+// EncodeInterleaved 编码按声道交织的 pcm, 返回按采样交织的码流(RFC 3551 布局)
+func (e *MultiChannelEncoder) EncodeInterleaved(pcm []int16) ([]byte, error) {
+	return e.AppendEncodeInterleaved(nil, pcm)
+}
+
+// AppendEncodeInterleaved 与 EncodeInterleaved 相同, 码流追加到 dst
+func (e *MultiChannelEncoder) AppendEncodeInterleaved(dst []byte, pcm []int16) ([]byte, error) {
+	if err := e.encode(pcm); err != nil {
+		return dst, err
+	}
+
+	e.mixed = e.mixed[:0]
+	for i := range e.codes[0] {
+		for ch := range e.codes {
+			e.mixed = append(e.mixed, e.codes[ch][i])
+		}
+	}
+	return e.packer.pack(dst, e.mixed), nil
+}
+
+// FlushPlanar 输出每个声道码流中剩余不足一个字节的比特, 没有剩余比特的声道为 nil
+func (e *MultiChannelEncoder) FlushPlanar() [][]byte {
+	out := make([][]byte, len(e.packers))
+	for ch, p := range e.packers {
+		out[ch] = p.Flush()
+	}
+	return out
+}
+
+// FlushInterleaved 输出交织码流中剩余不足一个字节的比特
+func (e *MultiChannelEncoder) FlushInterleaved() []byte {
+	return e.packer.Flush()
+}
+
+// Reset 复位所有声道的状态和剩余的比特
+func (e *MultiChannelEncoder) Reset() {
+	resetAll(e.states, e.packers, e.packer)
+}
+
+func resetAll(states, packers []*G726_state, packer *G726_state) {
+	for i := range states {
+		states[i].Reset()
+		packers[i].Reset()
+	}
+	packer.Reset()
+}
+
+// MultiChannelDecoder 多声道解码器, 每个声道一个独立的 ADPCM 状态, 输出为按声道交织的 PCM
+// 码流的布局参见 MultiChannelEncoder
+type MultiChannelDecoder struct {
+	states  []*G726_state
+	packers []*G726_state
+	packer  *G726_state
+
+	codes   []byte    // 交织码流中未凑齐一个采样时刻的码字
+	pending [][]int16 // 每个声道码流解码后未输出的采样点
+	channel []byte
+	mono    []int16
+}
+
+// NewMultiChannelDecoder 创建 channels 个声道的解码器, opts 应用于每个声道的状态
+func NewMultiChannelDecoder(rate Rate, packing PackingType, channels int, opts ...Option) (*MultiChannelDecoder, error) {
+	if channels < 1 {
+		return nil, ErrInvalidChannels
+	}
+
+	d := &MultiChannelDecoder{pending: make([][]int16, channels)}
+	var err error
+	if d.states, d.packers, d.packer, err = newChannelStates(rate, packing, channels, opts); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Channels 返回声道数
+func (d *MultiChannelDecoder) Channels() int {
+	return len(d.states)
+}
+
+// State 返回第 ch 个声道的解码状态, 它的 packing 总是 PackingNone
+// 丢包时可以对它调用 Prime
+func (d *MultiChannelDecoder) State(ch int) *G726_state {
+	return d.states[ch]
+}
+
+// unpackAll 用打包状态 p 解出 data 中的全部码字追加到 dst
+func unpackAll(dst []byte, p *G726_state, data []byte) []byte {
+	for len(data) > 0 {
+		off := len(dst)
+		n := p.DecodedLen(len(data))
+		for i := 0; i < n; i++ {
+			dst = append(dst, 0)
+		}
+		got, used := p.unpack(dst[off:], data)
+		dst = dst[:off+got]
+		data = data[used:]
+		if got == 0 && used == 0 {
+			break
+		}
+	}
+	return dst
+}
+
+// DecodeInterleaved 解码按采样交织的码流, 返回按声道交织的 PCM
+// 不足一个采样时刻(所有声道)的码字保留到下次调用
+func (d *MultiChannelDecoder) DecodeInterleaved(data []byte) []int16 {
+	return d.AppendDecodeInterleaved(nil, data)
+}
+
+// AppendDecodeInterleaved 与 DecodeInterleaved 相同, PCM 追加到 dst
+func (d *MultiChannelDecoder) AppendDecodeInterleaved(dst []int16, data []byte) []int16 {
+	channels := len(d.states)
+	d.codes = unpackAll(d.codes, d.packer, data)
+	frames := len(d.codes) / channels
+
+	off := len(dst)
+	for i := 0; i < frames*channels; i++ {
+		dst = append(dst, 0)
+	}
+	for ch, s := range d.states {
+		d.channel = d.channel[:0]
+		for i := 0; i < frames; i++ {
+			d.channel = append(d.channel, d.codes[i*channels+ch])
+		}
+		d.mono = s.AppendDecode(d.mono[:0], d.channel)
+		for i, v := range d.mono {
+			dst[off+i*channels+ch] = v
+		}
+	}
+
+	d.codes = append(d.codes[:0], d.codes[frames*channels:]...)
+	return dst
+}
+
+// DecodePlanar 解码每个声道的码流, 返回按声道交织的 PCM, data 的长度必须等于声道数
+// 各声道解码出的采样点数不同时只输出共同的部分, 其余的保留到下次调用
+func (d *MultiChannelDecoder) DecodePlanar(data [][]byte) ([]int16, error) {
+	return d.AppendDecodePlanar(nil, data)
+}
+
+// AppendDecodePlanar 与 DecodePlanar 相同, PCM 追加到 dst
+func (d *MultiChannelDecoder) AppendDecodePlanar(dst []int16, data [][]byte) ([]int16, error) {
+	channels := len(d.states)
+	if len(data) != channels {
+		return dst, fmt.Errorf("%w: %d inputs for %d channels", ErrInvalidChannels, len(data), channels)
+	}
+
+	frames := -1
+	for ch, s := range d.states {
+		d.channel = unpackAll(d.channel[:0], d.packers[ch], data[ch])
+		d.pending[ch] = s.AppendDecode(d.pending[ch], d.channel)
+		if frames < 0 || len(d.pending[ch]) < frames {
+			frames = len(d.pending[ch])
+		}
+	}
+
+	dst = AppendInterleave(dst, d.pending, frames)
+	for ch := range d.pending {
+		d.pending[ch] = append(d.pending[ch][:0], d.pending[ch][frames:]...)
+	}
+	return dst, nil
+}
+
+// Reset 复位所有声道的状态, 丢弃保留的码字和采样点
+func (d *MultiChannelDecoder) Reset() {
+	resetAll(d.states, d.packers, d.packer)
+	d.codes = d.codes[:0]
+	for ch := range d.pending {
+		d.pending[ch] = d.pending[ch][:0]
+	}
+}
+
+// AppendInterleave 把每个声道的前 n 个采样点按声道交织后追加到 dst, 长度不足 n 的声道补零
+func AppendInterleave(dst []int16, planes [][]int16, n int) []int16 {
+	channels := len(planes)
+	off := len(dst)
+	for i := 0; i < n*channels; i++ {
+		dst = append(dst, 0)
+	}
+	for ch, p := range planes {
+		for i := 0; i < n && i < len(p); i++ {
+			dst[off+i*channels+ch] = p[i]
+		}
+	}
+	return dst
+}
+
+// mustChannels 检查声道数, 小于1时 panic, 用于没有 error 返回值的声道转换函数
+func mustChannels(channels int) {
+	if channels < 1 {
+		panic(fmt.Errorf("g726: %w %d", ErrInvalidChannels, channels))
+	}
+}
+
+// Deinterleave 把按声道交织的 pcm 拆分为每个声道的采样点, 追加到 dst[ch]
+// dst 的长度不足 channels 时自动扩展, 末尾不完整的采样时刻被忽略. channels 小于1时 panic
+func Deinterleave(dst [][]int16, pcm []int16, channels int) [][]int16 {
+	mustChannels(channels)
+	for len(dst) < channels {
+		dst = append(dst, nil)
+	}
+	frames := len(pcm) / channels
+	for ch := 0; ch < channels; ch++ {
+		for i := 0; i < frames; i++ {
+			dst[ch] = append(dst[ch], pcm[i*channels+ch])
+		}
+	}
+	return dst
+}
+
+// Downmix 把按声道交织的 pcm 平均为单声道, channels 小于1时 panic
+func Downmix(pcm []int16, channels int) []int16 {
+	mustChannels(channels)
+	return AppendDownmix(make([]int16, 0, len(pcm)/channels), pcm, channels)
+}
+
+// AppendDownmix 与 Downmix 相同, 结果追加到 dst, 末尾不完整的采样时刻被忽略
+func AppendDownmix(dst []int16, pcm []int16, channels int) []int16 {
+	mustChannels(channels)
+	if channels == 1 {
+		return append(dst, pcm...)
+	}
+	for i := 0; i+channels <= len(pcm); i += channels {
+		sum := 0
+		for _, v := range pcm[i : i+channels] {
+			sum += int(v)
+		}
+		dst = append(dst, int16(sum/channels))
+	}
+	return dst
+}
+
+// Upmix 把单声道 pcm 复制到 channels 个声道, 返回按声道交织的 PCM, channels 小于1时 panic
+func Upmix(mono []int16, channels int) []int16 {
+	mustChannels(channels)
+	return AppendUpmix(make([]int16, 0, len(mono)*channels), mono, channels)
+}
+
+// AppendUpmix 与 Upmix 相同, 结果追加到 dst
+func AppendUpmix(dst []int16, mono []int16, channels int) []int16 {
+	mustChannels(channels)
+	for _, v := range mono {
+		for ch := 0; ch < channels; ch++ {
+			dst = append(dst, v)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,173 @@
+package g726
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// stereo 生成两个声道频率不同的交织 PCM
+func stereo(frames int) []int16 {
+	pcm := make([]int16, 0, 2*frames)
+	for i := 0; i < frames; i++ {
+		t := float64(i) / 8000
+		pcm = append(pcm, int16(9000*math.Sin(2*math.Pi*300*t)), int16(7000*math.Sin(2*math.Pi*1100*t+1)))
+	}
+	return pcm
+}
+
+func TestMultiChannelPlanar(t *testing.T) {
+	pcm := stereo(1001)
+	var left, right []int16
+	for i := 0; i < len(pcm); i += 2 {
+		left = append(left, pcm[i])
+		right = append(right, pcm[i+1])
+	}
+
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		for _, packing := range []PackingType{PackingNone, PackingLeft, PackingRight} {
+			enc, err := NewMultiChannelEncoder(rate, packing, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 分两次编码, 第一次不是8的倍数
+			got, err := enc.EncodePlanar(pcm[:2*333])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err = enc.AppendEncodePlanar(got, pcm[2*333:]); err != nil {
+				t.Fatal(err)
+			}
+			for ch, tail := range enc.FlushPlanar() {
+				got[ch] = append(got[ch], tail...)
+			}
+
+			for ch, mono := range [][]int16{left, right} {
+				ref := G726_init_state(rate, packing)
+				want := ref.AppendFlush(ref.EncodeV2(mono))
+				if !bytes.Equal(got[ch], want) {
+					t.Fatalf("%v %v channel %d: bitstream differs", rate, packing, ch)
+				}
+			}
+
+			dec, _ := NewMultiChannelDecoder(rate, packing, 2)
+			out, err := dec.DecodePlanar([][]byte{got[0][:7], got[1][:3]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out, err = dec.AppendDecodePlanar(out, [][]byte{got[0][7:], got[1][3:]}); err != nil {
+				t.Fatal(err)
+			}
+			left := G726_init_state(rate, packing).DecodeV2(got[0])
+			right := G726_init_state(rate, packing).DecodeV2(got[1])
+			want := AppendInterleave(nil, [][]int16{left, right}, len(left))
+			if !reflect.DeepEqual(out, want) {
+				t.Fatalf("%v %v: decoded PCM differs", rate, packing)
+			}
+		}
+	}
+}
+
+func TestMultiChannelInterleaved(t *testing.T) {
+	pcm := stereo(800)
+
+	for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+		for _, packing := range []PackingType{PackingLeft, PackingRight} {
+			enc, _ := NewMultiChannelEncoder(rate, packing, 2)
+			data, err := enc.EncodeInterleaved(pcm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tail := enc.FlushInterleaved(); tail != nil {
+				t.Fatalf("%v: %d bytes left over", rate, len(tail))
+			}
+			if len(data) != len(pcm)*int(rate+2)/8 {
+				t.Fatalf("%v: %d bytes", rate, len(data))
+			}
+
+			// 与按采样交织的码字(PackingNone)再打包的结果相同
+			codes, _ := NewMultiChannelEncoder(rate, PackingNone, 2)
+			mixed, _ := codes.EncodeInterleaved(pcm)
+			packer := G726_init_state(rate, packing)
+			if want := packer.pack(nil, mixed); !bytes.Equal(data, want) {
+				t.Fatalf("%v %v: interleaved layout differs", rate, packing)
+			}
+
+			// 按单个字节解码与一次解码的结果相同
+			dec, _ := NewMultiChannelDecoder(rate, packing, 2)
+			want := dec.DecodeInterleaved(data)
+			if len(want) != len(pcm) {
+				t.Fatalf("%v: %d samples", rate, len(want))
+			}
+			dec.Reset()
+			var got []int16
+			for i := range data {
+				got = dec.AppendDecodeInterleaved(got, data[i:i+1])
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%v %v: byte-wise decoding differs", rate, packing)
+			}
+		}
+	}
+}
+
+func TestMultiChannelErrors(t *testing.T) {
+	if _, err := NewMultiChannelEncoder(Rate32kbps, PackingLeft, 0); !errors.Is(err, ErrInvalidChannels) {
+		t.Fatal(err)
+	}
+	if _, err := NewMultiChannelDecoder(Rate(9), PackingLeft, 2); !errors.Is(err, ErrInvalidRate) {
+		t.Fatal(err)
+	}
+
+	enc, _ := NewMultiChannelEncoder(Rate32kbps, PackingLeft, 2)
+	if _, err := enc.EncodeInterleaved(make([]int16, 3)); !errors.Is(err, ErrInputLength) {
+		t.Fatal(err)
+	}
+	if _, err := enc.AppendEncodePlanar(make([][]byte, 3), nil); !errors.Is(err, ErrInvalidChannels) {
+		t.Fatal(err)
+	}
+	dec, _ := NewMultiChannelDecoder(Rate32kbps, PackingLeft, 2)
+	if _, err := dec.DecodePlanar(make([][]byte, 1)); !errors.Is(err, ErrInvalidChannels) {
+		t.Fatal(err)
+	}
+}
+
+func TestMix(t *testing.T) {
+	pcm := []int16{100, 300, -5, -6, 32767, 32767, 7}
+	if got := Downmix(pcm, 2); !reflect.DeepEqual(got, []int16{200, -5, 32767}) {
+		t.Fatal(got)
+	}
+	if got := Upmix([]int16{1, -2}, 3); !reflect.DeepEqual(got, []int16{1, 1, 1, -2, -2, -2}) {
+		t.Fatal(got)
+	}
+
+	planes := Deinterleave(nil, pcm, 2)
+	if !reflect.DeepEqual(planes, [][]int16{{100, -5, 32767}, {300, -6, 32767}}) {
+		t.Fatal(planes)
+	}
+	if got := AppendInterleave(nil, planes, 3); !reflect.DeepEqual(got, pcm[:6]) {
+		t.Fatal(got)
+	}
+
+	// 声道数小于1时 panic, 而不是死循环或除零
+	for _, channels := range []int{0, -2} {
+		for name, f := range map[string]func(){
+			"Downmix":       func() { Downmix(pcm, channels) },
+			"AppendDownmix": func() { AppendDownmix(nil, pcm, channels) },
+			"Upmix":         func() { Upmix(pcm, channels) },
+			"AppendUpmix":   func() { AppendUpmix(nil, pcm, channels) },
+			"Deinterleave":  func() { Deinterleave(nil, pcm, channels) },
+		} {
+			func() {
+				defer func() {
+					if err, ok := recover().(error); !ok || !errors.Is(err, ErrInvalidChannels) {
+						t.Errorf("%s(%d): recovered %v", name, channels, err)
+					}
+				}()
+				f()
+			}()
+		}
+	}
+}
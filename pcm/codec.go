@@ -0,0 +1,135 @@
+package pcm
+
+import (
+	"github.com/general252/g726"
+)
+
+// Encoder 把任意格式的 PCM 数据直接编码为 G.726, 不是并发安全的
+// 数据可以按任意长度分块传入, 不完整的采样点保留到下次调用
+type Encoder struct {
+	state   *g726.G726_state
+	format  Format
+	rest    []byte  // 上次剩余的不完整采样点
+	pcm     []int16 // 转换缓冲
+	clipped int64
+}
+
+// NewEncoder 创建输入为格式 f 的编码器, 编码使用 state
+func NewEncoder(state *g726.G726_state, f Format) (*Encoder, error) {
+	if !f.valid() {
+		return nil, ErrFormat
+	}
+	return &Encoder{state: state, format: f}, nil
+}
+
+// Format 返回输入格式
+func (e *Encoder) Format() Format {
+	return e.format
+}
+
+// Encode 编码 data 并返回码流
+func (e *Encoder) Encode(data []byte) []byte {
+	n := (len(e.rest) + len(data)) / e.format.BytesPerSample()
+	return e.AppendEncode(make([]byte, 0, e.state.EncodedLen(n)), data)
+}
+
+// AppendEncode 编码 data 并把码流追加到 dst
+func (e *Encoder) AppendEncode(dst []byte, data []byte) []byte {
+	size := e.format.BytesPerSample()
+
+	if len(e.rest) > 0 {
+		k := size - len(e.rest)
+		if len(data) < k {
+			e.rest = append(e.rest, data...)
+			return dst
+		}
+		e.rest = append(e.rest, data[:k]...)
+		data = data[k:]
+		dst = e.encode(dst, e.rest)
+		e.rest = e.rest[:0]
+	}
+
+	n := len(data) - len(data)%size
+	dst = e.encode(dst, data[:n])
+	e.rest = append(e.rest, data[n:]...)
+	return dst
+}
+
+func (e *Encoder) encode(dst []byte, data []byte) []byte {
+	if e.format == S16LE {
+		if pcm, ok := AsInt16(data); ok {
+			return e.state.AppendEncode(dst, pcm)
+		}
+	}
+
+	pcm, clipped, _ := appendDecode(e.pcm[:0], e.format, data)
+	e.pcm = pcm
+	e.clipped += int64(clipped)
+	return e.state.AppendEncode(dst, pcm)
+}
+
+// Flush 输出码流中剩余的比特, 不完整的采样点被丢弃
+func (e *Encoder) Flush() []byte {
+	return e.AppendFlush(nil)
+}
+
+// AppendFlush 同 Flush, 把结果追加到 dst
+func (e *Encoder) AppendFlush(dst []byte) []byte {
+	e.rest = e.rest[:0]
+	return e.state.AppendFlush(dst)
+}
+
+// Buffered 返回保留的不完整采样点的字节数
+func (e *Encoder) Buffered() int {
+	return len(e.rest)
+}
+
+// Clipped 返回转换为16位时饱和的采样点个数
+func (e *Encoder) Clipped() int64 {
+	return e.clipped
+}
+
+// Reset 重置编码器状态, 丢弃不完整的采样点
+func (e *Encoder) Reset() {
+	e.state.Reset()
+	e.rest = e.rest[:0]
+	e.clipped = 0
+}
+
+// Decoder 把 G.726 码流直接解码为任意格式的 PCM 数据, 不是并发安全的
+type Decoder struct {
+	state  *g726.G726_state
+	format Format
+	pcm    []int16 // 转换缓冲
+}
+
+// NewDecoder 创建输出为格式 f 的解码器, 解码使用 state
+func NewDecoder(state *g726.G726_state, f Format) (*Decoder, error) {
+	if !f.valid() {
+		return nil, ErrFormat
+	}
+	return &Decoder{state: state, format: f}, nil
+}
+
+// Format 返回输出格式
+func (d *Decoder) Format() Format {
+	return d.format
+}
+
+// Decode 解码 bitstream 并返回格式为 Format() 的 PCM 数据
+func (d *Decoder) Decode(bitstream []byte) []byte {
+	n := d.state.DecodedLen(len(bitstream))
+	return d.AppendDecode(make([]byte, 0, n*d.format.BytesPerSample()), bitstream)
+}
+
+// AppendDecode 解码 bitstream 并把 PCM 数据追加到 dst
+func (d *Decoder) AppendDecode(dst []byte, bitstream []byte) []byte {
+	d.pcm = d.state.AppendDecode(d.pcm[:0], bitstream)
+	dst, _ = AppendEncode(dst, d.format, d.pcm)
+	return dst
+}
+
+// Reset 重置解码器状态
+func (d *Decoder) Reset() {
+	d.state.Reset()
+}
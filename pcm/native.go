@@ -0,0 +1,37 @@
+package pcm
+
+import "unsafe"
+
+// nativeLittleEndian 本机是否为小端字节序
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// AsInt16 不复制地把 s16le 数据解释为采样点, 返回的切片与 data 共享内存
+// 只有本机为小端, data 按2字节对齐且长度为偶数时才可以, 否则返回 false, 需要用 Decode 转换
+func AsInt16(data []byte) ([]int16, bool) {
+	if !nativeLittleEndian || len(data)%2 != 0 {
+		return nil, false
+	}
+	if len(data) == 0 {
+		return []int16{}, true
+	}
+	p := unsafe.Pointer(unsafe.SliceData(data))
+	if uintptr(p)%unsafe.Alignof(int16(0)) != 0 {
+		return nil, false
+	}
+	return unsafe.Slice((*int16)(p), len(data)/2), true
+}
+
+// AsBytes 不复制地把采样点解释为 s16le 数据, 返回的切片与 pcm 共享内存
+// 本机为大端时返回 false, 需要用 Encode 转换
+func AsBytes(pcm []int16) ([]byte, bool) {
+	if !nativeLittleEndian {
+		return nil, false
+	}
+	if len(pcm) == 0 {
+		return []byte{}, true
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(pcm))), len(pcm)*2), true
+}
@@ -0,0 +1,243 @@
+// Package pcm 在各种线性 PCM 采样格式与编解码器使用的16位采样点之间转换
+//
+// 支持 s16le, s16be, u8, s24le, s32le 以及 32/64 位浮点(小端, 范围 [-1, 1)).
+// 转换为16位时, 精度更高的格式四舍五入, 超出范围的值饱和到 [-32768, 32767], 浮点的 NaN 转换为0.
+// 从16位转换到更高精度的格式是无损的.
+package pcm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+var (
+	ErrFormat = errors.New("pcm: unknown sample format")
+	ErrLength = errors.New("pcm: data length is not a multiple of the sample size")
+)
+
+// Format 采样格式, 多声道数据按声道交织
+type Format int
+
+const (
+	S16LE Format = iota + 1 // 16位有符号, 小端
+	S16BE                   // 16位有符号, 大端
+	U8                      // 8位无符号, 128为零点
+	S24LE                   // 24位有符号, 小端, 每个采样点3字节
+	S32LE                   // 32位有符号, 小端
+	F32LE                   // 32位浮点, 小端
+	F64LE                   // 64位浮点, 小端
+)
+
+var formatNames = map[Format]string{
+	S16LE: "s16le",
+	S16BE: "s16be",
+	U8:    "u8",
+	S24LE: "s24le",
+	S32LE: "s32le",
+	F32LE: "f32le",
+	F64LE: "f64le",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// BytesPerSample 返回每个采样点的字节数, 未知格式返回0
+func (f Format) BytesPerSample() int {
+	switch f {
+	case U8:
+		return 1
+	case S16LE, S16BE:
+		return 2
+	case S24LE:
+		return 3
+	case S32LE, F32LE:
+		return 4
+	case F64LE:
+		return 8
+	default:
+		return 0
+	}
+}
+
+func (f Format) valid() bool {
+	return f.BytesPerSample() > 0
+}
+
+// ParseFormat 按名称(例如 "s16le", 与 String 的结果相同, 不区分大小写)返回格式
+// 也接受 "f32" / "float32" 和 "f64" / "float64"
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "f32", "float32":
+		return F32LE, nil
+	case "f64", "float64":
+		return F64LE, nil
+	}
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrFormat, name)
+}
+
+// Decode 把格式为 f 的数据转换为16位采样点
+func Decode(f Format, data []byte) ([]int16, error) {
+	if !f.valid() {
+		return nil, ErrFormat
+	}
+	return AppendDecode(make([]int16, 0, len(data)/f.BytesPerSample()), f, data)
+}
+
+// AppendDecode 把格式为 f 的数据转换为16位采样点追加到 dst
+// data 的长度不是采样点大小的整数倍时返回 ErrLength, dst 保持不变
+func AppendDecode(dst []int16, f Format, data []byte) ([]int16, error) {
+	dst, _, err := appendDecode(dst, f, data)
+	return dst, err
+}
+
+// appendDecode 同 AppendDecode, 另外返回饱和的采样点个数
+func appendDecode(dst []int16, f Format, data []byte) ([]int16, int, error) {
+	size := f.BytesPerSample()
+	if size == 0 {
+		return dst, 0, ErrFormat
+	}
+	if len(data)%size != 0 {
+		return dst, 0, ErrLength
+	}
+
+	clipped := 0
+	switch f {
+	case S16LE:
+		if s, ok := AsInt16(data); ok {
+			return append(dst, s...), 0, nil
+		}
+		for i := 0; i < len(data); i += 2 {
+			dst = append(dst, int16(binary.LittleEndian.Uint16(data[i:])))
+		}
+	case S16BE:
+		for i := 0; i < len(data); i += 2 {
+			dst = append(dst, int16(binary.BigEndian.Uint16(data[i:])))
+		}
+	case U8:
+		for _, b := range data {
+			dst = append(dst, int16(b-128)<<8)
+		}
+	case S24LE:
+		for i := 0; i < len(data); i += 3 {
+			v := int32(uint32(data[i])<<8|uint32(data[i+1])<<16|uint32(data[i+2])<<24) >> 8
+			s, c := saturate((int64(v) + 1<<7) >> 8)
+			dst = append(dst, s)
+			clipped += c
+		}
+	case S32LE:
+		for i := 0; i < len(data); i += 4 {
+			v := int32(binary.LittleEndian.Uint32(data[i:]))
+			s, c := saturate((int64(v) + 1<<15) >> 16)
+			dst = append(dst, s)
+			clipped += c
+		}
+	case F32LE:
+		for i := 0; i < len(data); i += 4 {
+			s, c := fromFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i:]))))
+			dst = append(dst, s)
+			clipped += c
+		}
+	case F64LE:
+		for i := 0; i < len(data); i += 8 {
+			s, c := fromFloat(math.Float64frombits(binary.LittleEndian.Uint64(data[i:])))
+			dst = append(dst, s)
+			clipped += c
+		}
+	}
+
+	return dst, clipped, nil
+}
+
+// Encode 把16位采样点转换为格式 f
+func Encode(f Format, pcm []int16) ([]byte, error) {
+	if !f.valid() {
+		return nil, ErrFormat
+	}
+	return AppendEncode(make([]byte, 0, len(pcm)*f.BytesPerSample()), f, pcm)
+}
+
+// AppendEncode 把16位采样点转换为格式 f 追加到 dst
+// 转换为 u8 时四舍五入, 其他格式无损
+func AppendEncode(dst []byte, f Format, pcm []int16) ([]byte, error) {
+	switch f {
+	case S16LE:
+		if b, ok := AsBytes(pcm); ok {
+			return append(dst, b...), nil
+		}
+		for _, v := range pcm {
+			dst = binary.LittleEndian.AppendUint16(dst, uint16(v))
+		}
+	case S16BE:
+		for _, v := range pcm {
+			dst = binary.BigEndian.AppendUint16(dst, uint16(v))
+		}
+	case U8:
+		for _, v := range pcm {
+			u := (int(v) + 1<<7) >> 8
+			if u > 127 {
+				u = 127
+			}
+			dst = append(dst, byte(u+128))
+		}
+	case S24LE:
+		for _, v := range pcm {
+			u := uint32(int32(v) << 8)
+			dst = append(dst, byte(u), byte(u>>8), byte(u>>16))
+		}
+	case S32LE:
+		for _, v := range pcm {
+			dst = binary.LittleEndian.AppendUint32(dst, uint32(int32(v)<<16))
+		}
+	case F32LE:
+		for _, v := range pcm {
+			dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(float32(v)/32768))
+		}
+	case F64LE:
+		for _, v := range pcm {
+			dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(float64(v)/32768))
+		}
+	default:
+		return dst, ErrFormat
+	}
+
+	return dst, nil
+}
+
+// saturate 把 v 限制在16位范围内, 第二个返回值在发生饱和时为1
+func saturate(v int64) (int16, int) {
+	if v > math.MaxInt16 {
+		return math.MaxInt16, 1
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16, 1
+	}
+	return int16(v), 0
+}
+
+// fromFloat 把 [-1, 1) 范围的浮点数转换为16位采样点
+func fromFloat(x float64) (int16, int) {
+	if math.IsNaN(x) {
+		return 0, 1
+	}
+	x = math.Round(x * 32768)
+	if x > math.MaxInt16 {
+		return math.MaxInt16, 1
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16, 1
+	}
+	return int16(x), 0
+}
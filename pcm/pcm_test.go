@@ -0,0 +1,242 @@
+package pcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+var formats = []Format{S16LE, S16BE, U8, S24LE, S32LE, F32LE, F64LE}
+
+func ramp() []int16 {
+	pcm := make([]int16, 0, 1024)
+	for v := math.MinInt16; v <= math.MaxInt16; v += 67 {
+		pcm = append(pcm, int16(v))
+	}
+	return append(pcm, math.MaxInt16)
+}
+
+func TestRoundTrip(t *testing.T) {
+	pcm := ramp()
+	for _, f := range formats {
+		data, err := Encode(f, pcm)
+		if err != nil {
+			t.Fatal(f, err)
+		}
+		if len(data) != len(pcm)*f.BytesPerSample() {
+			t.Fatalf("%s: %d bytes", f, len(data))
+		}
+		got, err := Decode(f, data)
+		if err != nil {
+			t.Fatal(f, err)
+		}
+
+		if f != U8 {
+			if !reflect.DeepEqual(got, pcm) {
+				t.Fatalf("%s: round trip differs", f)
+			}
+			continue
+		}
+		// u8 只保留高8位, 误差不超过半个量化级(最大值附近饱和)
+		for i := range pcm {
+			if d := int(got[i]) - int(pcm[i]); (d < -128 || d > 128) && pcm[i] < 0x7F00 {
+				t.Fatalf("u8: sample %d: %d -> %d", i, pcm[i], got[i])
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	f32 := func(v float32) []byte { return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v)) }
+	f64 := func(v float64) []byte { return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)) }
+	s32 := func(v int32) []byte { return binary.LittleEndian.AppendUint32(nil, uint32(v)) }
+
+	tests := []struct {
+		f       Format
+		data    []byte
+		want    int16
+		clipped int
+	}{
+		{S16LE, []byte{0x34, 0x12}, 0x1234, 0},
+		{S16BE, []byte{0x12, 0x34}, 0x1234, 0},
+		{U8, []byte{0x00}, -32768, 0},
+		{U8, []byte{0x80}, 0, 0},
+		{U8, []byte{0xFF}, 0x7F00, 0},
+		{S24LE, []byte{0x7F, 0x34, 0x12}, 0x1234, 0},
+		{S24LE, []byte{0x80, 0x34, 0x12}, 0x1235, 0},
+		{S24LE, []byte{0xFF, 0xFF, 0x7F}, 32767, 1},
+		{S24LE, []byte{0x00, 0x00, 0x80}, -32768, 0},
+		{S32LE, s32(0x12348000), 0x1235, 0},
+		{S32LE, s32(math.MaxInt32), 32767, 1},
+		{S32LE, s32(math.MinInt32), -32768, 0},
+		{F32LE, f32(0.5), 16384, 0},
+		{F32LE, f32(-1), -32768, 0},
+		{F32LE, f32(1), 32767, 1},
+		{F32LE, f32(-3), -32768, 1},
+		{F64LE, f64(0.25), 8192, 0},
+		{F64LE, f64(1.0 / 65536), 1, 0},
+		{F64LE, f64(math.NaN()), 0, 1},
+		{F64LE, f64(math.Inf(1)), 32767, 1},
+	}
+	for _, tt := range tests {
+		got, clipped, err := appendDecode(nil, tt.f, tt.data)
+		if err != nil {
+			t.Fatal(tt.f, err)
+		}
+		if len(got) != 1 || got[0] != tt.want || clipped != tt.clipped {
+			t.Errorf("%s % x: got %v (%d clipped), want %d (%d clipped)", tt.f, tt.data, got, clipped, tt.want, tt.clipped)
+		}
+	}
+}
+
+func TestEncodeU8(t *testing.T) {
+	got, _ := Encode(U8, []int16{-32768, -129, -128, 0, 127, 128, 32767})
+	if want := []byte{0x00, 0x7F, 0x80, 0x80, 0x80, 0x81, 0xFF}; !bytes.Equal(got, want) {
+		t.Fatalf("got % x, want % x", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Decode(Format(0), nil); !errors.Is(err, ErrFormat) {
+		t.Fatal(err)
+	}
+	if _, err := Encode(Format(99), nil); !errors.Is(err, ErrFormat) {
+		t.Fatal(err)
+	}
+
+	dst := []int16{1}
+	got, err := AppendDecode(dst, S24LE, make([]byte, 4))
+	if !errors.Is(err, ErrLength) || len(got) != 1 {
+		t.Fatal(got, err)
+	}
+
+	for _, f := range formats {
+		if p, err := ParseFormat(f.String()); err != nil || p != f {
+			t.Fatal(f, p, err)
+		}
+	}
+	if p, err := ParseFormat("Float32"); err != nil || p != F32LE {
+		t.Fatal(p, err)
+	}
+	if _, err := ParseFormat("s8"); !errors.Is(err, ErrFormat) {
+		t.Fatal(err)
+	}
+}
+
+func TestZeroCopy(t *testing.T) {
+	if !nativeLittleEndian {
+		t.Skip("big-endian host")
+	}
+
+	pcm := []int16{1, -2, 0x1234}
+	b, ok := AsBytes(pcm)
+	if !ok || !bytes.Equal(b, []byte{1, 0, 0xFE, 0xFF, 0x34, 0x12}) {
+		t.Fatal(b, ok)
+	}
+	s, ok := AsInt16(b)
+	if !ok || &s[0] != &pcm[0] || !reflect.DeepEqual(s, pcm) {
+		t.Fatal(s, ok)
+	}
+
+	// 没有对齐或长度为奇数时不能直接解释
+	buf, _ := AsBytes(make([]int16, 5))
+	if _, ok := AsInt16(buf[1:]); ok {
+		t.Fatal("unaligned data accepted")
+	}
+	if _, ok := AsInt16(buf[:3]); ok {
+		t.Fatal("odd length accepted")
+	}
+	got, _ := Decode(S16LE, append(buf[:1], 0x34, 0x12)[1:])
+	if !reflect.DeepEqual(got, []int16{0x1234}) {
+		t.Fatal(got)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	pcm := make([]int16, 800)
+	for i := range pcm {
+		pcm[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+
+	ref, _ := g726.New(g726.Rate24kbps, g726.PackingLeft)
+	want := ref.EncodeV2(pcm)
+	want = ref.AppendFlush(want)
+
+	for _, f := range formats {
+		if f == U8 {
+			continue
+		}
+		data, _ := Encode(f, pcm)
+
+		state, _ := g726.New(g726.Rate24kbps, g726.PackingLeft)
+		enc, err := NewEncoder(state, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 按不是采样点大小整数倍的长度分块输入
+		var got []byte
+		for len(data) > 0 {
+			n := 7
+			if n > len(data) {
+				n = len(data)
+			}
+			got = enc.AppendEncode(got, data[:n])
+			data = data[n:]
+		}
+		if enc.Buffered() != 0 || enc.Clipped() != 0 {
+			t.Fatalf("%s: %d buffered, %d clipped", f, enc.Buffered(), enc.Clipped())
+		}
+		got = enc.AppendFlush(got)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: bitstream differs", f)
+		}
+	}
+
+	state, _ := g726.New(g726.Rate24kbps, g726.PackingLeft)
+	enc, _ := NewEncoder(state, F32LE)
+	enc.Encode(append(binary.LittleEndian.AppendUint32(nil, math.Float32bits(2)), 0))
+	if enc.Clipped() != 1 || enc.Buffered() != 1 {
+		t.Fatal(enc.Clipped(), enc.Buffered())
+	}
+	enc.Encode([]byte{0, 0, 0, 0})
+	if enc.Clipped() != 1 || enc.Buffered() != 1 {
+		t.Fatal(enc.Clipped(), enc.Buffered())
+	}
+	enc.Reset()
+	if enc.Clipped() != 0 || enc.Buffered() != 0 {
+		t.Fatal(enc.Clipped(), enc.Buffered())
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	bitstream := make([]byte, 100)
+	for i := range bitstream {
+		bitstream[i] = byte(i * 37)
+	}
+	ref, _ := g726.New(g726.Rate32kbps, g726.PackingRight)
+	pcm := ref.DecodeV2(bitstream)
+
+	for _, f := range formats {
+		state, _ := g726.New(g726.Rate32kbps, g726.PackingRight)
+		dec, err := NewDecoder(state, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := dec.Decode(bitstream[:33])
+		got = dec.AppendDecode(got, bitstream[33:])
+
+		want, _ := Encode(f, pcm)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: output differs", f)
+		}
+	}
+
+	if _, err := NewDecoder(ref, Format(0)); !errors.Is(err, ErrFormat) {
+		t.Fatal(err)
+	}
+}
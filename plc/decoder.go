@@ -0,0 +1,69 @@
+package plc
+
+import (
+	"github.com/general252/g726"
+)
+
+// Decoder 带丢包隐藏的 G.726 解码器
+//
+// 收到数据时调用 Decode, 丢包时调用 Conceal 代替解码. Conceal 默认把合成的信号送入编码器模型
+// (参见 g726.G726_state.Prime), 使解码器的状态接近发送端编码器的状态, 减小恢复收包后的失真.
+// 解码器必须输出线性 PCM, 即不能使用 g726.WithExtCoding 设置 G.711 输出.
+type Decoder struct {
+	dec     *g726.G726_state
+	plc     PLC
+	priming bool
+}
+
+// Option 用于 NewDecoder 的可选配置
+type Option func(*Decoder)
+
+// WithoutPriming 丢包时不更新解码器状态
+func WithoutPriming() Option {
+	return func(d *Decoder) {
+		d.priming = false
+	}
+}
+
+// NewDecoder 用 dec 创建带丢包隐藏的解码器, 之后不应该再直接使用 dec
+func NewDecoder(dec *g726.G726_state, opts ...Option) *Decoder {
+	d := &Decoder{dec: dec, priming: true}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Decode 解码收到的数据
+func (d *Decoder) Decode(g726Data []byte) []int16 {
+	return d.AppendDecode(nil, g726Data)
+}
+
+// AppendDecode 与 Decode 相同, 结果追加到 dst
+func (d *Decoder) AppendDecode(dst []int16, g726Data []byte) []int16 {
+	off := len(dst)
+	dst = d.dec.AppendDecode(dst, g726Data)
+	d.plc.Receive(dst[off:])
+	return dst
+}
+
+// Conceal 生成 n 个采样点替代丢失的数据
+func (d *Decoder) Conceal(n int) []int16 {
+	return d.AppendConceal(nil, n)
+}
+
+// AppendConceal 与 Conceal 相同, 结果追加到 dst
+func (d *Decoder) AppendConceal(dst []int16, n int) []int16 {
+	off := len(dst)
+	dst = d.plc.Conceal(dst, n)
+	if d.priming {
+		d.dec.Prime(dst[off:])
+	}
+	return dst
+}
+
+// Reset 复位解码器和丢包隐藏状态
+func (d *Decoder) Reset() {
+	d.dec.Reset()
+	d.plc.Reset()
+}
@@ -0,0 +1,203 @@
+// Package plc 实现丢包隐藏(Packet Loss Concealment), 移植自 spandsp 的 plc.c
+//
+// 丢包时根据最近收到的信号估计基音周期, 重复最后一个基音周期来填补丢失的数据,
+// 并在 50ms 内线性衰减到静音; 恢复收包后把真实信号与合成信号交叠相加, 平滑过渡.
+// 采样率为 8000Hz, 采样点为16位有符号线性 PCM.
+//
+// PLC 实现了 rtp.Concealer, 可以通过 rtp.WithConcealer 用于 rtp 包的解包器和抖动缓冲.
+package plc
+
+const (
+	pitchMin        = 120                        // 最低基音 8000/120 = 66Hz, 对应最长的周期
+	pitchMax        = 40                         // 最高基音 8000/40 = 200Hz, 对应最短的周期
+	correlationSpan = 160                        // 估计基音使用的采样点数, 必须大于 pitchMin
+	historyLen      = correlationSpan + pitchMin // 保存的历史采样点数
+
+	// 填补时每个采样点的衰减量, 50ms 衰减到静音
+	attenuationIncrement = 0.0025
+)
+
+// PLC 丢包隐藏状态, 零值可以直接使用, 每个声道使用一个独立的实例
+type PLC struct {
+	missing     int // 连续填补的采样点数
+	pitchOffset int // 下一个填补的采样点在 pitchbuf 中的位置
+	pitch       int // 基音周期
+	pitchbuf    [pitchMin]float32
+	history     [historyLen]int16
+	bufPtr      int // history 是环形缓冲, bufPtr 为最早的采样点
+}
+
+// New 创建丢包隐藏状态
+func New() *PLC {
+	return &PLC{}
+}
+
+// Reset 清除历史, 之后的填补输出静音直到收到新的数据
+func (p *PLC) Reset() {
+	*p = PLC{}
+}
+
+// Missing 返回连续填补的采样点数, 收到数据后清零
+func (p *PLC) Missing() int {
+	return p.missing
+}
+
+// Receive 处理收到的一段信号, pcm 会被原地修改:
+// 如果之前有填补的数据, pcm 开头的1/4个基音周期与合成信号交叠相加, 使过渡平滑
+func (p *PLC) Receive(pcm []int16) {
+	if p.missing > 0 {
+		// 真实信号的开头与后续1/4周期的合成信号交叠
+		overlap := p.pitch >> 2
+		if overlap > len(pcm) {
+			overlap = len(pcm)
+		}
+		gain := 1 - float32(p.missing)*attenuationIncrement
+		if gain < 0 {
+			gain = 0
+		}
+		newStep := 1 / float32(overlap)
+		oldStep := newStep * gain
+		newWeight := newStep
+		oldWeight := (1 - newStep) * gain
+		for i := 0; i < overlap; i++ {
+			pcm[i] = saturate(oldWeight*p.pitchbuf[p.pitchOffset] + newWeight*float32(pcm[i]))
+			if p.pitchOffset++; p.pitchOffset >= p.pitch {
+				p.pitchOffset = 0
+			}
+			newWeight += newStep
+			oldWeight -= oldStep
+			if oldWeight < 0 {
+				oldWeight = 0
+			}
+		}
+		p.missing = 0
+	}
+	p.saveHistory(pcm)
+}
+
+// Conceal 生成 n 个采样点追加到 dst, 替代丢失的数据
+func (p *PLC) Conceal(dst []int16, n int) []int16 {
+	off := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, 0)
+	}
+	amp := dst[off:]
+
+	var gain float32
+	i := 0
+	if p.missing == 0 {
+		// 丢包开始, 根据最近的信号估计基音周期, 准备用于填补的一个周期
+		p.normaliseHistory()
+		p.pitch = amdfPitch(pitchMin, pitchMax, p.history[historyLen-correlationSpan-pitchMin:], correlationSpan)
+		// 交叠1/4个周期
+		overlap := p.pitch >> 2
+		// 周期的前3/4直接复制
+		for i = 0; i < p.pitch-overlap; i++ {
+			p.pitchbuf[i] = float32(p.history[historyLen-p.pitch+i])
+		}
+		// 后1/4与前一个周期的结尾交叠, 使首尾相接
+		newStep := 1 / float32(overlap)
+		newWeight := newStep
+		for ; i < p.pitch; i++ {
+			p.pitchbuf[i] = float32(p.history[historyLen-p.pitch+i])*(1-newWeight) +
+				float32(p.history[historyLen-2*p.pitch+i])*newWeight
+			newWeight += newStep
+		}
+
+		// 合成信号开头的1/4周期需要与之前的真实信号交叠. 为了不引入延时,
+		// 把真实信号最后的1/4周期反转后与之交叠
+		gain = 1
+		oldStep := newStep
+		newWeight = newStep
+		oldWeight := 1 - newStep
+		for i = 0; i < overlap && i < n; i++ {
+			amp[i] = saturate(oldWeight*float32(p.history[historyLen-1-i]) + newWeight*p.pitchbuf[i])
+			newWeight += newStep
+			oldWeight -= oldStep
+			if oldWeight < 0 {
+				oldWeight = 0
+			}
+		}
+		p.pitchOffset = i
+	} else {
+		gain = 1 - float32(p.missing)*attenuationIncrement
+	}
+	for ; gain > 0 && i < n; i++ {
+		amp[i] = int16(p.pitchbuf[p.pitchOffset] * gain)
+		gain -= attenuationIncrement
+		if p.pitchOffset++; p.pitchOffset >= p.pitch {
+			p.pitchOffset = 0
+		}
+	}
+	for ; i < n; i++ {
+		amp[i] = 0
+	}
+	p.missing += n
+	p.saveHistory(amp)
+	return dst
+}
+
+// saveHistory 把 pcm 保存到环形缓冲
+func (p *PLC) saveHistory(pcm []int16) {
+	if len(pcm) >= historyLen {
+		// 只保留最后的部分, 从缓冲开头开始
+		copy(p.history[:], pcm[len(pcm)-historyLen:])
+		p.bufPtr = 0
+		return
+	}
+	if p.bufPtr+len(pcm) > historyLen {
+		// 需要回绕
+		n := copy(p.history[p.bufPtr:], pcm)
+		p.bufPtr = copy(p.history[:], pcm[n:])
+		return
+	}
+	copy(p.history[p.bufPtr:], pcm)
+	p.bufPtr += len(pcm)
+}
+
+// normaliseHistory 调整环形缓冲, 使最早的采样点位于开头
+func (p *PLC) normaliseHistory() {
+	if p.bufPtr == 0 {
+		return
+	}
+	var tmp [historyLen]int16
+	n := copy(tmp[:], p.history[p.bufPtr:])
+	copy(tmp[n:], p.history[:p.bufPtr])
+	p.history = tmp
+	p.bufPtr = 0
+}
+
+// amdfPitch 用平均幅度差函数(AMDF)在 [maxPitch, minPitch] 范围内估计基音周期
+func amdfPitch(minPitch, maxPitch int, amp []int16, n int) int {
+	pitch := minPitch
+	minAcc := int(^uint(0) >> 1)
+	for i := maxPitch; i <= minPitch; i++ {
+		acc := 0
+		for j := 0; j < n; j++ {
+			d := int(amp[i+j]) - int(amp[j])
+			if d < 0 {
+				d = -d
+			}
+			acc += d
+		}
+		if acc < minAcc {
+			minAcc = acc
+			pitch = i
+		}
+	}
+	return pitch
+}
+
+func saturate(amp float32) int16 {
+	if amp > 32767 {
+		return 32767
+	}
+	if amp < -32768 {
+		return -32768
+	}
+	// 舍入到最近的整数
+	if amp >= 0 {
+		return int16(amp + 0.5)
+	}
+	return int16(amp - 0.5)
+}
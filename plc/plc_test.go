@@ -0,0 +1,154 @@
+package plc
+
+import (
+	"math"
+	"testing"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/rtp"
+)
+
+var _ rtp.Concealer = (*PLC)(nil)
+
+// voiced 生成基音为 freq 的带谐波的信号
+func voiced(n int, freq float64) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		ph := 2 * math.Pi * freq * float64(i) / 8000
+		pcm[i] = int16(6000*math.Sin(ph) + 3000*math.Sin(2*ph+1) + 1500*math.Sin(3*ph+2))
+	}
+	return pcm
+}
+
+func energy(pcm []int16) (sum float64) {
+	for _, v := range pcm {
+		sum += float64(v) * float64(v)
+	}
+	return sum
+}
+
+func errorEnergy(got, want []int16) (sum float64) {
+	for i := range got {
+		d := float64(got[i]) - float64(want[i])
+		sum += d * d
+	}
+	return sum
+}
+
+func TestConceal(t *testing.T) {
+	signal := voiced(1200, 100)
+
+	var p PLC
+	for i := 0; i < 480; i += 160 {
+		p.Receive(append([]int16(nil), signal[i:i+160]...))
+	}
+	if got := amdfPitch(pitchMin, pitchMax, signal[480-historyLen:], correlationSpan); got != 80 {
+		t.Fatalf("pitch %d, want 80", got)
+	}
+
+	// 开头交叠的1/4周期之后, 填补的信号接近原信号的延续
+	out := p.Conceal(nil, 80)
+	if snr := 10 * math.Log10(energy(signal[500:560])/errorEnergy(out[20:], signal[500:560])); snr < 15 {
+		t.Fatalf("concealment SNR %.1f dB", snr)
+	}
+	if p.pitch != 80 || p.Missing() != 80 {
+		t.Fatalf("pitch %d, missing %d", p.pitch, p.Missing())
+	}
+
+	// 50ms 后衰减到静音
+	out = p.Conceal(out, 400)
+	for i := 400; i < len(out); i++ {
+		if out[i] != 0 {
+			t.Fatalf("sample %d = %d after fade out", i, out[i])
+		}
+	}
+	if energy(out[80:160]) <= energy(out[240:320]) {
+		t.Fatal("concealment does not fade")
+	}
+}
+
+func TestReceiveSmoothing(t *testing.T) {
+	signal := voiced(800, 100)
+
+	var p PLC
+	p.Receive(append([]int16(nil), signal[:320]...))
+	p.Conceal(nil, 160)
+
+	// 恢复后开头的1/4个周期被平滑, 其余不变
+	in := append([]int16(nil), signal[480:640]...)
+	p.Receive(in)
+	if p.Missing() != 0 {
+		t.Fatalf("missing %d after receive", p.Missing())
+	}
+	changed := 0
+	for i := range in {
+		if in[i] != signal[480+i] {
+			if i >= 20 {
+				t.Fatalf("sample %d changed", i)
+			}
+			changed++
+		}
+	}
+	if changed == 0 {
+		t.Fatal("transition not smoothed")
+	}
+
+	// 没有历史时输出静音
+	var empty PLC
+	for i, v := range empty.Conceal(nil, 100) {
+		if v != 0 {
+			t.Fatalf("sample %d = %d", i, v)
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	const frame = 160
+	signal := voiced(frame*20, 125)
+
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		enc, _ := g726.New(rate, g726.PackingNone)
+		codes := enc.AppendEncode(nil, signal)
+
+		ref, _ := g726.New(rate, g726.PackingNone)
+		want := ref.AppendDecode(nil, codes)
+
+		// 丢失第8个包, 分别用丢包隐藏(更新和不更新解码器状态)和静音替代
+		decode := func(d *Decoder, conceal bool) []int16 {
+			var out []int16
+			for i := 0; i < 20; i++ {
+				switch {
+				case i != 8:
+					out = d.AppendDecode(out, codes[i*frame:(i+1)*frame])
+				case conceal:
+					out = d.AppendConceal(out, frame)
+				default:
+					out = append(out, make([]int16, frame)...)
+				}
+			}
+			return out
+		}
+
+		dec, _ := g726.New(rate, g726.PackingNone)
+		concealed := decode(NewDecoder(dec), true)
+		dec, _ = g726.New(rate, g726.PackingNone)
+		unprimed := decode(NewDecoder(dec, WithoutPriming()), true)
+		dec, _ = g726.New(rate, g726.PackingNone)
+		silent := decode(NewDecoder(dec, WithoutPriming()), false)
+
+		lost := want[8*frame : 9*frame]
+		if e1, e2 := errorEnergy(concealed[8*frame:9*frame], lost), errorEnergy(silent[8*frame:9*frame], lost); e1 >= e2/4 {
+			t.Fatalf("rate %v: concealment error %.0f, silence error %.0f", rate, e1, e2)
+		}
+		after := want[9*frame : 11*frame]
+		e1, e2 := errorEnergy(concealed[9*frame:11*frame], after), errorEnergy(unprimed[9*frame:11*frame], after)
+		if e1 == e2 || e1 > energy(after)/5 {
+			t.Fatalf("rate %v: error after loss %.0f with priming, %.0f without", rate, e1, e2)
+		}
+		for i := 0; i < 8*frame; i++ {
+			if concealed[i] != want[i] {
+				t.Fatalf("rate %v: sample %d differs before the loss", rate, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package quality
+
+import (
+	"math"
+	"math/bits"
+)
+
+// nextPow2 返回不小于 n 的2的幂
+func nextPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// fft 原地计算 x 的离散傅里叶变换, len(x) 必须是2的幂, inverse 为 true 时计算逆变换(不除以 n)
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := sign * 2 * math.Pi / float64(size)
+		w := complex(math.Cos(step), math.Sin(step))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// Align 返回 deg 相对于 ref 的延迟 d (deg[i+d] 对应 ref[i]), 在 [-maxDelay, maxDelay] 中选择归一化互相关最大的值.
+// 相关程度相同时选择绝对值较小的延迟. 重叠部分至少为较短信号的一半,
+// 否则很短的重叠(例如只有一个采样点)的归一化互相关接近 ±1, 信号比 maxDelay 短时会选中错误的延迟
+func Align(ref, deg []int16, maxDelay int) int {
+	if len(ref) == 0 || len(deg) == 0 || maxDelay <= 0 {
+		return 0
+	}
+	minOverlap := len(ref)
+	if len(deg) < minOverlap {
+		minOverlap = len(deg)
+	}
+	minOverlap = (minOverlap + 1) / 2
+
+	// 用 FFT 计算互相关 c[d] = sum ref[i]*deg[i+d], 负的延迟在末尾
+	n := nextPow2(len(ref) + len(deg))
+	a := make([]complex128, n)
+	b := make([]complex128, n)
+	for i, v := range ref {
+		a[i] = complex(float64(v), 0)
+	}
+	for i, v := range deg {
+		b[i] = complex(float64(v), 0)
+	}
+	fft(a, false)
+	fft(b, false)
+	for i := range a {
+		a[i] = complex(real(a[i]), -imag(a[i])) * b[i]
+	}
+	fft(a, true)
+
+	// 能量的前缀和, 用于归一化重叠部分的互相关
+	refEnergy := prefixEnergy(ref)
+	degEnergy := prefixEnergy(deg)
+
+	best, bestScore := 0, math.Inf(-1)
+	for k := 0; k <= 2*maxDelay; k++ {
+		d := (k + 1) / 2
+		if k%2 == 0 {
+			d = -d
+		}
+		r0, d0 := 0, d // 重叠部分的起点
+		if d < 0 {
+			r0, d0 = -d, 0
+		}
+		length := len(ref) - r0
+		if l := len(deg) - d0; l < length {
+			length = l
+		}
+		if length < minOverlap {
+			continue
+		}
+
+		energy := (refEnergy[r0+length] - refEnergy[r0]) * (degEnergy[d0+length] - degEnergy[d0])
+		if energy <= 0 {
+			continue
+		}
+		score := real(a[(d+n)%n]) / float64(n) / math.Sqrt(energy)
+		if score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	return best
+}
+
+func prefixEnergy(pcm []int16) []float64 {
+	sum := make([]float64, len(pcm)+1)
+	for i, v := range pcm {
+		sum[i+1] = sum[i] + float64(v)*float64(v)
+	}
+	return sum
+}
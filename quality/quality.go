@@ -0,0 +1,280 @@
+// Package quality 计算原始 PCM 与编解码之后的 PCM (例如 DecodeV2 的输出)之间的客观质量指标:
+// 信噪比(SNR), 分段信噪比(segmental SNR), 对数谱距离(log-spectral distance)和频率加权分段信噪比
+// (frequency-weighted segmental SNR), 可以用来代替对比波形截图.
+//
+// Compare 先用互相关估计两个信号之间的延迟并对齐, 然后只比较重叠的部分.
+// 分段指标按不重叠的帧计算(默认 160 个采样点, 即 8kHz 时 20ms), 原信号低于 -50dBFS 的静音帧不参与;
+// 每帧的分段信噪比限制在 [-10, 35] dB 之内.
+package quality
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrEmpty   = errors.New("quality: not enough samples to compare")
+	ErrSilence = errors.New("quality: reference signal is silent")
+	ErrOption  = errors.New("quality: invalid option")
+)
+
+const (
+	minFrameSNR = -10 // 每帧信噪比的下限 dB
+	maxFrameSNR = 35  // 每帧信噪比的上限 dB
+
+	silence = -50 // 静音帧的阈值 dBFS
+
+	fwGamma = 0.2 // 频率加权分段信噪比的权重指数
+)
+
+// criticalBands 临界频带的边界 Hz, 频率加权分段信噪比在这些频带上计算
+var criticalBands = []float64{0, 100, 200, 300, 400, 510, 630, 770, 920, 1080, 1270, 1480, 1720, 2000,
+	2320, 2700, 3150, 3700, 4400, 5300, 6400, 7700, 9500, 12000, 15500, 20500}
+
+// Report 质量指标
+type Report struct {
+	Delay   int // 解码信号相对于原信号的延迟(采样点), 正数表示解码信号滞后
+	Samples int // 对齐后比较的采样点数
+	Frames  int // 参与分段指标的帧数
+
+	SNR      float64 // 信噪比 dB, 两个信号完全相同时为 +Inf
+	SegSNR   float64 // 分段信噪比 dB
+	LSD      float64 // 对数谱距离 dB, 越小越好
+	FWSegSNR float64 // 频率加权分段信噪比 dB
+}
+
+type config struct {
+	maxDelay   int
+	frameSize  int
+	sampleRate int
+}
+
+// Option 设置 Compare 的参数
+type Option func(*config)
+
+// WithMaxDelay 设置搜索延迟的范围 [-n, n] 个采样点, 默认 800 (8kHz 时 100ms), 0 表示不对齐
+func WithMaxDelay(n int) Option {
+	return func(c *config) {
+		c.maxDelay = n
+	}
+}
+
+// WithFrameSize 设置分段指标的帧长(采样点), 默认 160
+func WithFrameSize(n int) Option {
+	return func(c *config) {
+		c.frameSize = n
+	}
+}
+
+// WithSampleRate 设置采样率, 用于频率加权分段信噪比的频带划分, 默认 8000
+func WithSampleRate(rate int) Option {
+	return func(c *config) {
+		c.sampleRate = rate
+	}
+}
+
+// Compare 对齐 ref 和 deg 并计算全部指标, ref 是原信号, deg 是编解码之后的信号
+func Compare(ref, deg []int16, opts ...Option) (Report, error) {
+	c := config{maxDelay: 800, frameSize: 160, sampleRate: 8000}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.maxDelay < 0 || c.frameSize < 2 || c.sampleRate <= 0 {
+		return Report{}, ErrOption
+	}
+
+	delay := Align(ref, deg, c.maxDelay)
+	if delay >= 0 {
+		deg = deg[delay:]
+	} else {
+		ref = ref[-delay:]
+	}
+	if len(deg) < len(ref) {
+		ref = ref[:len(deg)]
+	}
+	deg = deg[:len(ref)]
+	if len(ref) < c.frameSize {
+		return Report{}, ErrEmpty
+	}
+
+	r := Report{
+		Delay:    delay,
+		Samples:  len(ref),
+		Frames:   len(activeFrames(ref, c.frameSize)),
+		SNR:      SNR(ref, deg),
+		SegSNR:   SegmentalSNR(ref, deg, c.frameSize),
+		LSD:      LogSpectralDistance(ref, deg, c.frameSize),
+		FWSegSNR: FrequencyWeightedSegSNR(ref, deg, c.frameSize, c.sampleRate),
+	}
+	if r.Frames == 0 {
+		return r, ErrSilence
+	}
+	return r, nil
+}
+
+// SNR 返回已经对齐的 ref 和 deg 的信噪比 dB, 只比较两者都有的部分
+func SNR(ref, deg []int16) float64 {
+	var signal, noise float64
+	for i := 0; i < len(ref) && i < len(deg); i++ {
+		d := float64(ref[i]) - float64(deg[i])
+		signal += float64(ref[i]) * float64(ref[i])
+		noise += d * d
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+// SegmentalSNR 返回已经对齐的 ref 和 deg 的分段信噪比 dB, 即每帧信噪比(限制在 [-10, 35] dB)的平均值.
+// 没有非静音帧时返回 NaN
+func SegmentalSNR(ref, deg []int16, frameSize int) float64 {
+	var sum float64
+	frames := activeFrames(ref[:minLen(ref, deg)], frameSize)
+	for _, start := range frames {
+		sum += clampSNR(SNR(ref[start:start+frameSize], deg[start:start+frameSize]))
+	}
+	return sum / float64(len(frames))
+}
+
+// LogSpectralDistance 返回已经对齐的 ref 和 deg 的对数谱距离 dB, 即每帧功率谱之差(dB)的均方根的平均值.
+// 每帧加 Hann 窗, 功率谱加上约 1 LSB 的噪声底, 避免对数发散. 没有非静音帧时返回 NaN
+func LogSpectralDistance(ref, deg []int16, frameSize int) float64 {
+	var sum float64
+	frames := activeFrames(ref[:minLen(ref, deg)], frameSize)
+	s := newSpectrum(frameSize)
+	var x, y []float64
+	for _, start := range frames {
+		x = s.power(ref[start:start+frameSize], x)
+		y = s.power(deg[start:start+frameSize], y)
+		var d2 float64
+		for k := range x {
+			d := 10 * math.Log10((x[k]+s.floor)/(y[k]+s.floor))
+			d2 += d * d
+		}
+		sum += math.Sqrt(d2 / float64(len(x)))
+	}
+	return sum / float64(len(frames))
+}
+
+// FrequencyWeightedSegSNR 返回已经对齐的 ref 和 deg 的频率加权分段信噪比 dB.
+// 每帧在临界频带上计算原信号的幅度谱与两者幅度谱之差的信噪比(限制在 [-10, 35] dB 之内),
+// 按原信号频带幅度的 0.2 次方加权平均, 然后对所有帧平均. 没有非静音帧时返回 NaN
+func FrequencyWeightedSegSNR(ref, deg []int16, frameSize, sampleRate int) float64 {
+	var sum float64
+	frames := activeFrames(ref[:minLen(ref, deg)], frameSize)
+	s := newSpectrum(frameSize)
+	bands := s.bands(sampleRate)
+	var x, y []float64
+	for _, start := range frames {
+		x = s.power(ref[start:start+frameSize], x)
+		y = s.power(deg[start:start+frameSize], y)
+
+		var weighted, weights float64
+		for _, b := range bands {
+			var signal, noise float64
+			for k := b[0]; k < b[1]; k++ {
+				d := math.Sqrt(x[k]) - math.Sqrt(y[k])
+				signal += x[k]
+				noise += d * d
+			}
+			n := float64(b[1] - b[0])
+			w := math.Pow(signal/n, fwGamma/2) // 频带幅度的 gamma 次方
+			weighted += w * clampSNR(10*math.Log10(signal/noise))
+			weights += w
+		}
+		if weights > 0 {
+			sum += weighted / weights
+		} else {
+			sum += maxFrameSNR
+		}
+	}
+	return sum / float64(len(frames))
+}
+
+// activeFrames 返回 pcm 中非静音帧的起点
+func activeFrames(pcm []int16, frameSize int) []int {
+	threshold := float64(frameSize) * math.Pow(10, silence/10.0) * 32768 * 32768
+	var frames []int
+	for start := 0; start+frameSize <= len(pcm); start += frameSize {
+		var energy float64
+		for _, v := range pcm[start : start+frameSize] {
+			energy += float64(v) * float64(v)
+		}
+		if energy >= threshold {
+			frames = append(frames, start)
+		}
+	}
+	return frames
+}
+
+func clampSNR(snr float64) float64 {
+	if snr < minFrameSNR {
+		return minFrameSNR
+	}
+	if snr > maxFrameSNR || math.IsNaN(snr) {
+		return maxFrameSNR
+	}
+	return snr
+}
+
+func minLen(a, b []int16) int {
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
+
+// spectrum 计算加窗的功率谱
+type spectrum struct {
+	window []float64
+	buf    []complex128
+	floor  float64 // 噪声底, 幅度为 1 的白噪声在每个频点的功率
+}
+
+func newSpectrum(frameSize int) *spectrum {
+	s := &spectrum{
+		window: make([]float64, frameSize),
+		buf:    make([]complex128, nextPow2(frameSize)),
+	}
+	for i := range s.window {
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*(float64(i)+0.5)/float64(frameSize))
+		s.floor += s.window[i] * s.window[i]
+	}
+	return s
+}
+
+// power 返回 frame 的功率谱(0 到采样率的一半), 结果追加到 dst[:0]
+func (s *spectrum) power(frame []int16, dst []float64) []float64 {
+	for i := range s.buf {
+		s.buf[i] = 0
+	}
+	for i, v := range frame {
+		s.buf[i] = complex(float64(v)*s.window[i], 0)
+	}
+	fft(s.buf, false)
+
+	dst = dst[:0]
+	for _, v := range s.buf[:len(s.buf)/2+1] {
+		dst = append(dst, real(v)*real(v)+imag(v)*imag(v))
+	}
+	return dst
+}
+
+// bands 返回每个临界频带的频点范围 [lo, hi)
+func (s *spectrum) bands(sampleRate int) [][2]int {
+	n := len(s.buf)
+	bins := n/2 + 1
+	var bands [][2]int
+	for i := 0; i+1 < len(criticalBands); i++ {
+		lo := int(math.Round(criticalBands[i] * float64(n) / float64(sampleRate)))
+		hi := int(math.Round(criticalBands[i+1] * float64(n) / float64(sampleRate)))
+		if hi > bins {
+			hi = bins
+		}
+		if lo < hi {
+			bands = append(bands, [2]int{lo, hi})
+		}
+		if hi == bins {
+			break
+		}
+	}
+	return bands
+}
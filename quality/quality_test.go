@@ -0,0 +1,181 @@
+package quality
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+// speech 生成基音和共振峰随时间变化的信号, 中间有一段静音
+func speech(n int) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		t := float64(i) / 8000
+		if i >= n/2 && i < n/2+800 {
+			continue
+		}
+		f0 := 120 + 40*math.Sin(2*math.Pi*2*t)
+		pcm[i] = int16(5000*math.Sin(2*math.Pi*f0*t) + 2500*math.Sin(2*math.Pi*3*f0*t+1) + 800*math.Sin(2*math.Pi*2300*t))
+	}
+	return pcm
+}
+
+func TestIdentical(t *testing.T) {
+	ref := speech(8000)
+	r, err := Compare(ref, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Delay != 0 || r.Samples != len(ref) || r.Frames != 45 {
+		t.Fatalf("%+v", r)
+	}
+	if !math.IsInf(r.SNR, 1) || r.SegSNR != 35 || r.LSD != 0 || r.FWSegSNR != 35 {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestNoise(t *testing.T) {
+	ref := speech(16000)
+	rnd := rand.New(rand.NewSource(1))
+
+	// 信噪比约 20dB 的白噪声
+	var power float64
+	for _, v := range ref {
+		power += float64(v) * float64(v)
+	}
+	sigma := math.Sqrt(power / float64(len(ref)) / 100)
+	deg := make([]int16, len(ref))
+	for i, v := range ref {
+		deg[i] = int16(float64(v) + sigma*rnd.NormFloat64())
+	}
+
+	r, err := Compare(ref, deg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Delay != 0 || math.Abs(r.SNR-20) > 0.5 || math.Abs(r.SegSNR-20) > 2 {
+		t.Fatalf("%+v", r)
+	}
+	if r.LSD <= 0 || r.FWSegSNR <= 0 || r.FWSegSNR >= 35 {
+		t.Fatalf("%+v", r)
+	}
+
+	// 噪声越大, 各项指标越差
+	for i, v := range ref {
+		deg[i] = int16(float64(v) + 3*sigma*rnd.NormFloat64())
+	}
+	worse, _ := Compare(ref, deg)
+	if worse.SNR >= r.SNR || worse.SegSNR >= r.SegSNR || worse.LSD <= r.LSD || worse.FWSegSNR >= r.FWSegSNR {
+		t.Fatalf("%+v\n%+v", worse, r)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	ref := speech(8000)
+	for _, delay := range []int{37, -120, 800} {
+		var deg []int16
+		if delay >= 0 {
+			deg = append(make([]int16, delay), ref...)
+		} else {
+			deg = ref[-delay:]
+		}
+		r, err := Compare(ref, deg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Delay != delay || r.Samples != len(ref)-max0(-delay) || !math.IsInf(r.SNR, 1) {
+			t.Fatalf("delay %d: %+v", delay, r)
+		}
+	}
+
+	// 不对齐时延迟为 0, 信噪比很差
+	deg := append(make([]int16, 37), ref...)
+	if r, _ := Compare(ref, deg, WithMaxDelay(0)); r.Delay != 0 || r.SNR > 0 {
+		t.Fatalf("%+v", r)
+	}
+}
+
+// 信号比 maxDelay 短时不能因为只重叠几个采样点而选中很大的延迟
+func TestShort(t *testing.T) {
+	for _, n := range []int{320, 480, 1000} {
+		ref := speech(n)
+		enc := g726.G726_init_state(g726.Rate16kbps, g726.PackingRight)
+		dec := g726.G726_init_state(g726.Rate16kbps, g726.PackingRight)
+		deg := dec.DecodeV2(enc.AppendFlush(enc.EncodeV2(ref)))
+		if d := Align(ref, deg, 800); d != 0 {
+			t.Fatalf("%d samples: delay %d", n, d)
+		}
+		if r, err := Compare(ref, deg); err != nil || r.Delay != 0 || r.Samples != n {
+			t.Fatalf("%d samples: %+v, %v", n, r, err)
+		}
+		if d := Align(ref, append(make([]int16, 40), ref...), 800); d != 40 {
+			t.Fatalf("%d samples: delay %d, want 40", n, d)
+		}
+	}
+}
+
+func max0(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+func TestErrors(t *testing.T) {
+	ref := speech(1000)
+	if _, err := Compare(ref, ref, WithFrameSize(0)); !errors.Is(err, ErrOption) {
+		t.Fatal(err)
+	}
+	if _, err := Compare(ref[:100], ref[:100]); !errors.Is(err, ErrEmpty) {
+		t.Fatal(err)
+	}
+	if _, err := Compare(make([]int16, 1000), ref); !errors.Is(err, ErrSilence) {
+		t.Fatal(err)
+	}
+}
+
+// 编解码示例音频的质量门限(LSD 为上限, 其他为下限), 超出说明编解码器的质量下降了
+var g726Limits = map[g726.Rate]Report{
+	g726.Rate16kbps: {SNR: 17.5, SegSNR: 16.5, LSD: 20, FWSegSNR: 13.5},
+	g726.Rate24kbps: {SNR: 24.5, SegSNR: 25, LSD: 14, FWSegSNR: 19.5},
+	g726.Rate32kbps: {SNR: 31, SegSNR: 32.5, LSD: 9.5, FWSegSNR: 24},
+	g726.Rate40kbps: {SNR: 36.5, SegSNR: 34.5, LSD: 7.2, FWSegSNR: 26.5},
+}
+
+func TestG726(t *testing.T) {
+	data, err := os.ReadFile("../example/audio-samples.pcm")
+	if err != nil {
+		t.Skip(err)
+	}
+	ref := make([]int16, len(data)/2)
+	for i := range ref {
+		ref[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+
+	var prev Report
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		enc := g726.G726_init_state(rate, g726.PackingRight)
+		dec := g726.G726_init_state(rate, g726.PackingRight)
+		deg := dec.DecodeV2(enc.AppendFlush(enc.EncodeV2(ref)))
+
+		r, err := Compare(ref, deg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%v: %+v", rate, r)
+
+		limit := g726Limits[rate]
+		if r.Delay != 0 || r.SNR < limit.SNR || r.SegSNR < limit.SegSNR || r.LSD > limit.LSD || r.FWSegSNR < limit.FWSegSNR {
+			t.Errorf("%v: %+v, limits %+v", rate, r, limit)
+		}
+		if rate > g726.Rate16kbps && (r.SNR <= prev.SNR || r.SegSNR <= prev.SegSNR || r.LSD >= prev.LSD) {
+			t.Errorf("%v is not better than the next lower rate: %+v, %+v", rate, r, prev)
+		}
+		prev = r
+	}
+}
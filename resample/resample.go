@@ -0,0 +1,242 @@
+// Package resample 实现有理数比例的多相(polyphase)采样率转换, 用于在编码前把 PCM 转换为 8kHz,
+// 以及把解码得到的 8kHz PCM 转换为其他采样率
+//
+// 低通滤波器为 Kaiser 窗的 sinc, 通带到较低采样率的 0.45 倍, 阻带从 0.5 倍开始, 阻带衰减约 80dB.
+// 输出与输入在时间上对齐(没有滤波器的群延时), Process 只输出已经有足够输入的部分,
+// 输入结束后调用 Flush 输出剩余的采样点. 输入 n 个采样点(每声道)总共输出 ceil(n*outRate/inRate) 个.
+package resample
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrInvalidRate     = errors.New("resample: invalid sample rate")
+	ErrInvalidChannels = errors.New("resample: invalid channel count")
+	ErrRatio           = errors.New("resample: conversion ratio too complex")
+)
+
+const (
+	attenuation = 80   // 阻带衰减 dB
+	passband    = 0.45 // 通带截止, 相对于较低的采样率
+	stopband    = 0.5  // 阻带起始, 相对于较低的采样率
+
+	maxPhases = 1024 // 最多的相位数, 即化简后的输出采样率
+)
+
+// Resampler 流式采样率转换, 不是并发安全的
+type Resampler struct {
+	up, down int // 化简后的比例, 输出:输入 = up:down
+	channels int
+	taps     int         // 每个相位的系数个数
+	center   int64       // 原型滤波器的中心
+	phases   [][]float64 // phases[p][k] = h[p+k*up]
+
+	buf    [][]float64 // 每声道的输入, buf[ch][0] 对应输入序号 base
+	base   int64
+	in     int64 // 已经输入的采样点数(每声道)
+	out    int64 // 已经输出的采样点数(每声道)
+	frame  []float64
+	bypass bool
+}
+
+// New 创建从 inRate 转换到 outRate 的 Resampler, 输入输出按声道交织
+func New(inRate, outRate, channels int) (*Resampler, error) {
+	if inRate <= 0 || outRate <= 0 {
+		return nil, ErrInvalidRate
+	}
+	if channels < 1 {
+		return nil, ErrInvalidChannels
+	}
+
+	g := gcd(inRate, outRate)
+	r := &Resampler{
+		up:       outRate / g,
+		down:     inRate / g,
+		channels: channels,
+		buf:      make([][]float64, channels),
+		frame:    make([]float64, channels),
+	}
+	if r.up == r.down {
+		r.bypass = true
+		return r, nil
+	}
+	if r.up > maxPhases {
+		return nil, ErrRatio
+	}
+
+	r.design(float64(min(inRate, outRate)) / float64(inRate) / float64(r.up))
+	r.Reset()
+	return r, nil
+}
+
+// design 设计原型低通滤波器, scale 为较低的采样率相对于原型滤波器采样率(inRate*up)的比例
+func (r *Resampler) design(scale float64) {
+	// Kaiser 窗的参数
+	beta := 0.1102 * (attenuation - 8.7)
+	width := 2 * math.Pi * (stopband - passband) * scale
+	n := int(math.Ceil((attenuation-8)/(2.285*width))) | 1 // 奇数长度, 中心为整数
+	fc := (passband + stopband) / 2 * scale                // 截止频率, 相对于原型采样率
+
+	r.taps = (n + r.up - 1) / r.up
+	r.center = int64(n / 2)
+	r.phases = make([][]float64, r.up)
+	for p := range r.phases {
+		r.phases[p] = make([]float64, r.taps)
+	}
+
+	i0beta := besselI0(beta)
+	for i := 0; i < n; i++ {
+		x := float64(i) - float64(n/2)
+		h := 2 * fc
+		if x != 0 {
+			h = math.Sin(2*math.Pi*fc*x) / (math.Pi * x)
+		}
+		t := x / float64(n/2)
+		w := besselI0(beta*math.Sqrt(1-t*t)) / i0beta
+		// 插值时每个相位只有 1/up 的输入, 需要乘以 up 保持增益
+		r.phases[i%r.up][i/r.up] = h * w * float64(r.up)
+	}
+}
+
+// besselI0 第一类零阶修正贝塞尔函数
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 100; k++ {
+		term *= (x / 2) / float64(k)
+		sum += term * term
+		if term*term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Reset 清除历史, 开始新的流
+func (r *Resampler) Reset() {
+	r.in, r.out = 0, 0
+	// 输入开始之前的采样点为0
+	r.base = -int64(r.taps)
+	for ch := range r.buf {
+		r.buf[ch] = append(r.buf[ch][:0], make([]float64, r.taps)...)
+	}
+}
+
+// Process 转换 pcm (按声道交织), 返回已经可以输出的采样点
+func (r *Resampler) Process(pcm []int16) []int16 {
+	return r.AppendProcess(nil, pcm)
+}
+
+// AppendProcess 与 Process 相同, 结果追加到 dst
+func (r *Resampler) AppendProcess(dst []int16, pcm []int16) []int16 {
+	if r.bypass {
+		return append(dst, pcm...)
+	}
+
+	n := len(pcm) / r.channels
+	for ch := range r.buf {
+		for i := 0; i < n; i++ {
+			r.buf[ch] = append(r.buf[ch], float64(pcm[i*r.channels+ch]))
+		}
+	}
+	r.in += int64(n)
+
+	return r.generate(dst, -1)
+}
+
+// Flush 输入结束, 输出剩余的采样点, 之后可以开始新的流
+func (r *Resampler) Flush() []int16 {
+	return r.AppendFlush(nil)
+}
+
+// AppendFlush 与 Flush 相同, 结果追加到 dst
+func (r *Resampler) AppendFlush(dst []int16) []int16 {
+	if r.bypass {
+		return dst
+	}
+
+	// 输入结束之后的采样点为0
+	for ch := range r.buf {
+		r.buf[ch] = append(r.buf[ch], make([]float64, r.taps+1)...)
+	}
+	total := (r.in*int64(r.up) + int64(r.down) - 1) / int64(r.down)
+	dst = r.generate(dst, total)
+	r.Reset()
+	return dst
+}
+
+// generate 输出所有已经有足够输入的采样点, limit 不为负数时最多输出到第 limit 个
+func (r *Resampler) generate(dst []int16, limit int64) []int16 {
+	end := r.base + int64(len(r.buf[0])) // 缓存的输入的结尾
+	for limit < 0 || r.out < limit {
+		// 输出 n 对应原型滤波器的位置 n*down, 需要输入 [imax-taps+1, imax]
+		j := r.out*int64(r.down) + r.center
+		imax := j / int64(r.up)
+		if imax >= end {
+			break
+		}
+		coef := r.phases[j-imax*int64(r.up)]
+
+		for ch, buf := range r.buf {
+			x := buf[imax-r.base-int64(r.taps)+1 : imax-r.base+1]
+			var acc float64
+			for k, c := range coef {
+				acc += c * x[len(x)-1-k]
+			}
+			r.frame[ch] = acc
+		}
+		for _, v := range r.frame {
+			dst = append(dst, saturate(v))
+		}
+		r.out++
+	}
+
+	// 丢弃不再需要的输入
+	j := r.out*int64(r.down) + r.center
+	keep := j/int64(r.up) - int64(r.taps) + 1
+	if drop := keep - r.base; drop > 0 {
+		if drop > int64(len(r.buf[0])) {
+			drop = int64(len(r.buf[0]))
+		}
+		for ch := range r.buf {
+			r.buf[ch] = append(r.buf[ch][:0], r.buf[ch][drop:]...)
+		}
+		r.base += drop
+	}
+	return dst
+}
+
+func saturate(v float64) int16 {
+	v = math.Round(v)
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
+
+// Resample 一次转换整段 PCM (按声道交织)
+func Resample(pcm []int16, inRate, outRate, channels int) ([]int16, error) {
+	r, err := New(inRate, outRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	return r.AppendFlush(r.Process(pcm)), nil
+}
@@ -0,0 +1,161 @@
+package resample
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(n, rate int, freq, amp float64) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(math.Round(amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))))
+	}
+	return pcm
+}
+
+// snr 比较 got 与 want 中间的部分(两端有输入之外的0的影响)
+func snr(got, want []int16) float64 {
+	var signal, noise float64
+	for i := len(want) / 4; i < len(want)*3/4; i++ {
+		d := float64(got[i]) - float64(want[i])
+		signal += float64(want[i]) * float64(want[i])
+		noise += d * d
+	}
+	return 10 * math.Log10(signal/(noise+1e-9))
+}
+
+func rms(pcm []int16) float64 {
+	var sum float64
+	for i := len(pcm) / 4; i < len(pcm)*3/4; i++ {
+		sum += float64(pcm[i]) * float64(pcm[i])
+	}
+	return math.Sqrt(sum / float64(len(pcm)/2))
+}
+
+var rates = []int{11025, 16000, 22050, 32000, 44100, 48000}
+
+func TestResample(t *testing.T) {
+	for _, rate := range rates {
+		for _, c := range []struct{ in, out int }{{rate, 8000}, {8000, rate}} {
+			n := c.in / 2
+			in := sine(n, c.in, 1000, 10000)
+			out, err := Resample(in, c.in, c.out, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (n*c.out + c.in - 1) / c.in; len(out) != want {
+				t.Fatalf("%d -> %d: %d samples, want %d", c.in, c.out, len(out), want)
+			}
+			// 输出与输入在时间上对齐
+			if s := snr(out, sine(len(out), c.out, 1000, 10000)); s < 60 {
+				t.Fatalf("%d -> %d: SNR %.1f dB", c.in, c.out, s)
+			}
+		}
+	}
+}
+
+func TestAntiAlias(t *testing.T) {
+	for _, rate := range rates {
+		// 通带内的信号幅度不变
+		out, _ := Resample(sine(rate, rate, 3400, 10000), rate, 8000, 1)
+		if gain := 20 * math.Log10(rms(out)/(10000/math.Sqrt2)); math.Abs(gain) > 0.1 {
+			t.Fatalf("%d Hz: passband gain %.2f dB", rate, gain)
+		}
+
+		// 8kHz 的奈奎斯特频率以上的信号被滤除
+		for _, freq := range []float64{4200, 5000} {
+			if freq >= float64(rate)/2 {
+				continue
+			}
+			out, _ := Resample(sine(rate, rate, freq, 10000), rate, 8000, 1)
+			if level := 20 * math.Log10(rms(out)/10000); level > -70 {
+				t.Fatalf("%d Hz: %v Hz aliased at %.1f dB", rate, freq, level)
+			}
+		}
+
+		// 上采样不产生镜像
+		out, _ = Resample(sine(8000, 8000, 1000, 10000), 8000, rate, 1)
+		want := sine(len(out), rate, 1000, 10000)
+		var noise float64
+		for i := len(out) / 4; i < len(out)*3/4; i++ {
+			d := float64(out[i] - want[i])
+			noise += d * d
+		}
+		if level := 10 * math.Log10(noise/float64(len(out)/2)/(10000*10000/2)); level > -70 {
+			t.Fatalf("8000 -> %d: images at %.1f dB", rate, level)
+		}
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	left := sine(48000, 48000, 440, 8000)
+	right := sine(48000, 48000, 1300, 5000)
+	stereo := make([]int16, 0, 2*len(left))
+	for i := range left {
+		stereo = append(stereo, left[i], right[i])
+	}
+
+	for _, c := range []struct{ in, out int }{{48000, 8000}, {44100, 8000}, {8000, 44100}} {
+		want := make([][]int16, 2)
+		for ch, in := range [][]int16{left, right} {
+			want[ch], _ = Resample(in[:c.in], c.in, c.out, 1)
+		}
+
+		// 按不同的长度分块送入, 结果与一次转换相同
+		r, err := New(c.in, c.out, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []int16
+		in := stereo[:2*c.in]
+		for i, size := 0, 1; i < len(in); size = size*7%1000 + 1 {
+			end := i + 2*size
+			if end > len(in) {
+				end = len(in)
+			}
+			out = r.AppendProcess(out, in[i:end])
+			i = end
+		}
+		out = r.AppendFlush(out)
+
+		if len(out) != 2*len(want[0]) {
+			t.Fatalf("%d -> %d: %d samples, want %d", c.in, c.out, len(out), 2*len(want[0]))
+		}
+		for i := range want[0] {
+			if out[2*i] != want[0][i] || out[2*i+1] != want[1][i] {
+				t.Fatalf("%d -> %d: sample %d differs", c.in, c.out, i)
+			}
+		}
+
+		// Flush 之后开始新的流
+		again := r.AppendFlush(r.Process(in))
+		for i := range out {
+			if again[i] != out[i] {
+				t.Fatalf("%d -> %d: second stream differs at %d", c.in, c.out, i)
+			}
+		}
+	}
+}
+
+func TestBypass(t *testing.T) {
+	in := sine(100, 8000, 1000, 1000)
+	out, err := Resample(in, 8000, 8000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if out[i] != in[i] {
+			t.Fatal("same rate changed the signal")
+		}
+	}
+
+	if _, err := New(0, 8000, 1); err != ErrInvalidRate {
+		t.Fatal(err)
+	}
+	if _, err := New(8000, 8000, 0); err != ErrInvalidChannels {
+		t.Fatal(err)
+	}
+	if _, err := New(8000, 44101, 1); err != ErrRatio {
+		t.Fatal(err)
+	}
+}
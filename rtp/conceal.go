@@ -0,0 +1,51 @@
+package rtp
+
+// Concealer 丢包隐藏策略, 每个声道使用一个独立的实例
+type Concealer interface {
+	// Receive 在正常解码一个包后调用, pcm 为该声道解码得到的采样点
+	// 可以原地修改 pcm, 例如平滑丢包后的过渡(参见 plc 包)
+	Receive(pcm []int16)
+	// Conceal 生成 n 个采样点追加到 dst, 替代丢失的数据
+	Conceal(dst []int16, n int) []int16
+}
+
+// Silence 用静音替代丢失的数据
+type Silence struct{}
+
+func (Silence) Receive(pcm []int16) {}
+
+func (Silence) Conceal(dst []int16, n int) []int16 {
+	for i := 0; i < n; i++ {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// Repeat 重复最近收到的一个包, 每次重复衰减一半, 连续丢包时逐渐变为静音
+type Repeat struct {
+	last  []int16
+	pos   int
+	shift uint
+}
+
+func (r *Repeat) Receive(pcm []int16) {
+	r.last = append(r.last[:0], pcm...)
+	r.pos = 0
+	r.shift = 0
+}
+
+func (r *Repeat) Conceal(dst []int16, n int) []int16 {
+	for i := 0; i < n; i++ {
+		if len(r.last) == 0 || r.shift >= 16 {
+			dst = append(dst, 0)
+			continue
+		}
+		if r.pos == len(r.last) {
+			r.pos = 0
+			r.shift++
+		}
+		dst = append(dst, r.last[r.pos]>>(r.shift+1))
+		r.pos++
+	}
+	return dst
+}
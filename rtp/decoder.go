@@ -0,0 +1,101 @@
+package rtp
+
+import (
+	"github.com/general252/g726"
+)
+
+// channelDecoder 按声道解码 RTP 负载, 并在丢包时调用丢包隐藏, Depacketizer 和 JitterBuffer 共用
+type channelDecoder struct {
+	bits     int
+	channels int
+	priming  bool
+
+	decoder    *g726.MultiChannelDecoder
+	concealers []Concealer
+
+	mixed []int16   // 交织的解码结果
+	pcm   [][]int16 // 最近一次解码或隐藏的结果, 每声道一个
+}
+
+func newChannelDecoder(rate g726.Rate, packing g726.PackingType, c *config) (*channelDecoder, error) {
+	if packing != g726.PackingRight && packing != g726.PackingLeft {
+		return nil, g726.ErrInvalidPacking
+	}
+	if c.channels < 1 || c.channels > 255 {
+		return nil, ErrInvalidChannels
+	}
+
+	dec, err := g726.NewMultiChannelDecoder(rate, packing, c.channels)
+	if err != nil {
+		return nil, err
+	}
+	d := &channelDecoder{
+		bits:     bitsPerSample(rate),
+		channels: c.channels,
+		priming:  !c.noPriming,
+		decoder:  dec,
+		pcm:      make([][]int16, c.channels),
+	}
+	for i := 0; i < c.channels; i++ {
+		d.concealers = append(d.concealers, c.concealer())
+	}
+
+	return d, nil
+}
+
+// samples 返回负载中每声道的采样点数, 负载长度不合法时返回0
+func (d *channelDecoder) samples(payload []byte) int {
+	bits := len(payload) * 8
+	if bits%(d.bits*d.channels) != 0 {
+		return 0
+	}
+	return bits / (d.bits * d.channels)
+}
+
+// decode 解码一个包的负载, 返回每声道的采样点数
+func (d *channelDecoder) decode(payload []byte) int {
+	// 合法的负载总是包含整数个采样时刻, 解码器不会保留码字
+	d.mixed = d.decoder.AppendDecodeInterleaved(d.mixed[:0], payload)
+
+	for ch := range d.pcm {
+		d.pcm[ch] = d.pcm[ch][:0]
+	}
+	d.pcm = g726.Deinterleave(d.pcm, d.mixed, d.channels)
+	for ch, c := range d.concealers {
+		c.Receive(d.pcm[ch])
+	}
+	return len(d.mixed) / d.channels
+}
+
+// conceal 为每个声道生成 n 个隐藏的采样点
+func (d *channelDecoder) conceal(n int) {
+	for ch, c := range d.concealers {
+		d.pcm[ch] = c.Conceal(d.pcm[ch][:0], n)
+		if d.priming {
+			d.decoder.State(ch).Prime(d.pcm[ch])
+		}
+	}
+}
+
+// silence 为每个声道生成 n 个静音采样点, 不改变解码器状态
+func (d *channelDecoder) silence(n int) {
+	for ch := range d.pcm {
+		d.pcm[ch] = d.pcm[ch][:0]
+		for i := 0; i < n; i++ {
+			d.pcm[ch] = append(d.pcm[ch], 0)
+		}
+	}
+}
+
+// reset 复位解码器和丢包隐藏
+func (d *channelDecoder) reset() {
+	d.decoder.Reset()
+	for _, c := range d.concealers {
+		c.Receive(nil)
+	}
+}
+
+// appendInterleaved 把最近的结果按声道交织后追加到 dst
+func (d *channelDecoder) appendInterleaved(dst []int16, samples int) []int16 {
+	return g726.AppendInterleave(dst, d.pcm, samples)
+}
@@ -0,0 +1,294 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/general252/g726"
+)
+
+var (
+	ErrPayloadType   = errors.New("rtp: unexpected payload type")
+	ErrPayloadLength = errors.New("rtp: payload is not a whole number of samples")
+	ErrInvalidWindow = errors.New("rtp: reorder window and conceal limit must not be negative")
+)
+
+const (
+	DefaultReorder    = 2 // 默认最多等待的乱序包数
+	DefaultMaxConceal = 5 // 默认最多隐藏的连续丢包数
+
+	maxMisorder = 100 // 序号落后超过这个值时认为对端重新开始了序号
+)
+
+// WithReorder 设置重排窗口: 缺少某个包时最多再缓存 n 个后续的包等待它, 超过后认为该包丢失
+// n 为0时不等待, 乱序到达的包都当作迟到丢弃
+func WithReorder(n int) Option {
+	return func(c *config) {
+		c.reorder = n
+	}
+}
+
+// WithMaxConceal 设置最多隐藏的连续丢包数, 丢包更多时不再隐藏, 而是复位解码器重新开始
+func WithMaxConceal(n int) Option {
+	return func(c *config) {
+		c.maxConceal = n
+	}
+}
+
+// WithConcealer 设置丢包隐藏策略, newConcealer 为每个声道创建一个实例, 默认为 Repeat
+func WithConcealer(newConcealer func() Concealer) Option {
+	return func(c *config) {
+		c.concealer = newConcealer
+	}
+}
+
+// WithoutPriming 隐藏丢包后不用隐藏的信号更新解码器状态(参见 g726.G726_state.Prime)
+func WithoutPriming() Option {
+	return func(c *config) {
+		c.noPriming = true
+	}
+}
+
+// Frame 按序输出的一个包的 PCM
+type Frame struct {
+	PCM            []int16 // 按声道交织
+	Timestamp      uint32
+	SequenceNumber uint16
+	Concealed      bool // 由丢包隐藏生成
+	Discontinuity  bool // 此前有数据被跳过(长时间丢包或 SSRC 改变), 解码器已复位
+}
+
+// Stats 接收统计
+type Stats struct {
+	Received   uint64 // 收到的有效包
+	Lost       uint64 // 判定丢失的包
+	Concealed  uint64 // 隐藏的包
+	Duplicates uint64 // 重复的包
+	Late       uint64 // 已经判定丢失后才到达的包
+	Reordered  uint64 // 乱序到达但仍按序输出的包
+	Invalid    uint64 // 格式错误的包
+	Resets     uint64 // 解码器复位次数
+}
+
+type heldPacket struct {
+	seq     uint16
+	ts      uint32
+	payload []byte
+}
+
+// Depacketizer 解析 G.726 RTP 包, 按序号顺序解码, 并处理丢包, 重复和乱序
+type Depacketizer struct {
+	*channelDecoder
+	payloadType uint8
+	reorder     int
+	maxConceal  int
+
+	started bool
+	ssrc    uint32
+	next    uint16 // 期望的下一个序号
+	nextTs  uint32 // 期望的下一个时间戳
+	frame   int    // 最近一个包每声道的采样点数
+	history uint64 // 最近输出的64个序号, 第 i 位对应 next-1-i
+	discont bool
+	held    []heldPacket // 按序号排列的乱序包
+	stats   Stats
+}
+
+// NewDepacketizer 创建解包器
+func NewDepacketizer(rate g726.Rate, packing g726.PackingType, payloadType uint8, opts ...Option) (*Depacketizer, error) {
+	c := newConfig(opts)
+	if c.reorder < 0 || c.maxConceal < 0 {
+		return nil, ErrInvalidWindow
+	}
+	dec, err := newChannelDecoder(rate, packing, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Depacketizer{
+		channelDecoder: dec,
+		payloadType:    payloadType,
+		reorder:        c.reorder,
+		maxConceal:     c.maxConceal,
+		frame:          int(c.ptime / time.Millisecond * ClockRate / 1000),
+	}, nil
+}
+
+// Stats 返回接收统计
+func (d *Depacketizer) Stats() Stats {
+	return d.stats
+}
+
+// Push 解析一个 RTP 包, 返回可以按序输出的帧(可能包含隐藏的帧)
+func (d *Depacketizer) Push(data []byte) ([]Frame, error) {
+	var pkt Packet
+	if err := pkt.Unmarshal(data); err != nil {
+		d.stats.Invalid++
+		return nil, err
+	}
+	return d.PushPacket(&pkt)
+}
+
+// PushPacket 与 Push 相同, 输入为已经解析的包
+func (d *Depacketizer) PushPacket(pkt *Packet) ([]Frame, error) {
+	if pkt.PayloadType != d.payloadType {
+		d.stats.Invalid++
+		return nil, fmt.Errorf("%w %d", ErrPayloadType, pkt.PayloadType)
+	}
+	if d.samples(pkt.Payload) == 0 {
+		d.stats.Invalid++
+		return nil, ErrPayloadLength
+	}
+
+	var frames []Frame
+	if !d.started || pkt.SSRC != d.ssrc {
+		// 新的流
+		if d.started {
+			frames = d.Flush()
+			d.reset()
+		}
+		d.started = true
+		d.ssrc = pkt.SSRC
+		d.next = pkt.SequenceNumber
+		d.nextTs = pkt.Timestamp
+		d.history = 0
+	}
+
+	delta := int16(pkt.SequenceNumber - d.next)
+	switch {
+	case delta < -maxMisorder:
+		// 对端重新开始了序号
+		frames = append(frames, d.Flush()...)
+		d.reset()
+		d.next = pkt.SequenceNumber
+		d.nextTs = pkt.Timestamp
+		d.history = 0
+	case delta < 0:
+		if bit := uint(-delta - 1); bit < 64 && d.history&(1<<bit) != 0 {
+			d.stats.Duplicates++
+		} else {
+			d.stats.Late++
+		}
+		return frames, nil
+	}
+
+	for _, h := range d.held {
+		if h.seq == pkt.SequenceNumber {
+			d.stats.Duplicates++
+			return frames, nil
+		}
+	}
+
+	d.stats.Received++
+	if len(d.held) > 0 && int16(pkt.SequenceNumber-d.held[len(d.held)-1].seq) < 0 {
+		d.stats.Reordered++
+	}
+	d.hold(heldPacket{
+		seq:     pkt.SequenceNumber,
+		ts:      pkt.Timestamp,
+		payload: append([]byte(nil), pkt.Payload...),
+	})
+
+	for len(d.held) > 0 {
+		h := d.held[0]
+		if h.seq != d.next {
+			if len(d.held) <= d.reorder {
+				break
+			}
+			frames = d.conceal(frames, h)
+		}
+		frames = append(frames, d.decode(h))
+		d.held = d.held[1:]
+	}
+
+	return frames, nil
+}
+
+// Flush 不再等待缺少的包, 隐藏丢失的包并输出所有缓存的包, 用于流结束时
+func (d *Depacketizer) Flush() []Frame {
+	var frames []Frame
+	for _, h := range d.held {
+		if h.seq != d.next {
+			frames = d.conceal(frames, h)
+		}
+		frames = append(frames, d.decode(h))
+	}
+	d.held = d.held[:0]
+	return frames
+}
+
+// hold 按序号插入乱序缓存
+func (d *Depacketizer) hold(h heldPacket) {
+	i := len(d.held)
+	for i > 0 && int16(h.seq-d.held[i-1].seq) < 0 {
+		i--
+	}
+	d.held = append(d.held, heldPacket{})
+	copy(d.held[i+1:], d.held[i:])
+	d.held[i] = h
+}
+
+// conceal 处理 next 到 h 之间丢失的包
+func (d *Depacketizer) conceal(frames []Frame, h heldPacket) []Frame {
+	lost := int(h.seq - d.next)
+	d.stats.Lost += uint64(lost)
+
+	if lost > d.maxConceal {
+		d.reset()
+		// 跳过的序号没有输出, 在 history 中记为未收到, 使其后到达的包仍按 Late 统计
+		if lost >= 64 {
+			d.history = 0
+		} else {
+			d.history <<= uint(lost)
+		}
+		return frames
+	}
+
+	for i := 0; i < lost; i++ {
+		d.channelDecoder.conceal(d.frame)
+		frames = append(frames, Frame{
+			PCM:            d.appendInterleaved(nil, d.frame),
+			Timestamp:      d.nextTs,
+			SequenceNumber: d.next,
+			Concealed:      true,
+		})
+		d.stats.Concealed++
+		d.advance(d.frame, false)
+	}
+	return frames
+}
+
+// decode 解码一个包
+func (d *Depacketizer) decode(h heldPacket) Frame {
+	samples := d.channelDecoder.decode(h.payload)
+	f := Frame{
+		PCM:            d.appendInterleaved(nil, samples),
+		Timestamp:      h.ts,
+		SequenceNumber: h.seq,
+		Discontinuity:  d.discont,
+	}
+	d.discont = false
+	d.nextTs = h.ts
+	d.next = h.seq
+	d.advance(samples, true)
+	return f
+}
+
+// advance 输出一个包后更新期望的序号和时间戳
+func (d *Depacketizer) advance(samples int, received bool) {
+	d.history <<= 1
+	if received {
+		d.history |= 1
+	}
+	d.next++
+	d.nextTs += uint32(samples)
+	d.frame = samples
+}
+
+// reset 复位解码器和丢包隐藏
+func (d *Depacketizer) reset() {
+	d.channelDecoder.reset()
+	d.discont = true
+	d.stats.Resets++
+}
@@ -0,0 +1,235 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+// testPackets 把 pcm 打包成 20ms 的包
+func testPackets(t *testing.T, rate g726.Rate, packing g726.PackingType, pcm []int16, opts ...Option) [][]byte {
+	p, err := NewPacketizer(rate, packing, 96, append([]Option{WithSSRC(1), WithSequence(65530)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := p.Packetize(pcm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out [][]byte
+	for _, pkt := range packets {
+		out = append(out, pkt.Marshal())
+	}
+	return out
+}
+
+func pushAll(t *testing.T, d *Depacketizer, packets [][]byte) []Frame {
+	var frames []Frame
+	for _, b := range packets {
+		out, err := d.Push(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, out...)
+	}
+	return append(frames, d.Flush()...)
+}
+
+func TestDepacketizer(t *testing.T) {
+	pcm := testSignal(1600, 440)
+
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		for _, packing := range []g726.PackingType{g726.PackingLeft, g726.PackingRight} {
+			packets := testPackets(t, rate, packing, pcm)
+
+			// 参考结果: 单独编解码
+			enc, _ := g726.New(rate, packing)
+			dec, _ := g726.New(rate, packing)
+			want := dec.AppendDecode(nil, enc.AppendEncode(nil, pcm))
+
+			d, err := NewDepacketizer(rate, packing, 96)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frames := pushAll(t, d, packets)
+			if len(frames) != 10 {
+				t.Fatalf("rate %v packing %v: %d frames", rate, packing, len(frames))
+			}
+
+			var got []int16
+			for i, f := range frames {
+				if f.Concealed || f.Discontinuity || f.SequenceNumber != uint16(65530+i) || f.Timestamp-frames[0].Timestamp != uint32(160*i) {
+					t.Fatalf("frame %d: %+v", i, f)
+				}
+				got = append(got, f.PCM...)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("rate %v packing %v: sample %d = %d, want %d", rate, packing, i, got[i], want[i])
+				}
+			}
+			if s := d.Stats(); s.Received != 10 || s.Lost != 0 {
+				t.Fatalf("stats %+v", s)
+			}
+		}
+	}
+}
+
+func TestDepacketizerChannels(t *testing.T) {
+	left, right := testSignal(640, 440), testSignal(640, 1000)
+	pcm := make([]int16, 0, 2*len(left))
+	for i := range left {
+		pcm = append(pcm, left[i], right[i])
+	}
+	packets := testPackets(t, g726.Rate24kbps, g726.PackingRight, pcm, WithChannels(2))
+
+	d, _ := NewDepacketizer(g726.Rate24kbps, g726.PackingRight, 96, WithChannels(2))
+	var got []int16
+	for _, f := range pushAll(t, d, packets) {
+		got = append(got, f.PCM...)
+	}
+
+	for ch, in := range [][]int16{left, right} {
+		enc, _ := g726.New(g726.Rate24kbps, g726.PackingNone)
+		dec, _ := g726.New(g726.Rate24kbps, g726.PackingNone)
+		want := dec.AppendDecode(nil, enc.AppendEncode(nil, in))
+		for i := range want {
+			if got[2*i+ch] != want[i] {
+				t.Fatalf("channel %d sample %d = %d, want %d", ch, i, got[2*i+ch], want[i])
+			}
+		}
+	}
+}
+
+func TestDepacketizerLoss(t *testing.T) {
+	pcm := testSignal(1600, 440)
+	packets := testPackets(t, g726.Rate32kbps, g726.PackingRight, pcm)
+
+	// 丢掉第3, 4个包
+	d, _ := NewDepacketizer(g726.Rate32kbps, g726.PackingRight, 96)
+	frames := pushAll(t, d, append(packets[:3:3], packets[5:]...))
+	if len(frames) != 10 {
+		t.Fatalf("%d frames", len(frames))
+	}
+	for i, f := range frames {
+		concealed := i == 3 || i == 4
+		if f.Concealed != concealed || f.SequenceNumber != uint16(65530+i) || len(f.PCM) != 160 {
+			t.Fatalf("frame %d: concealed %v seq %d len %d", i, f.Concealed, f.SequenceNumber, len(f.PCM))
+		}
+	}
+	// 第一个隐藏的包重复上一个包并衰减一半
+	if frames[3].PCM[10] != frames[2].PCM[10]>>1 {
+		t.Fatalf("concealed sample %d, last %d", frames[3].PCM[10], frames[2].PCM[10])
+	}
+	if s := d.Stats(); s.Received != 8 || s.Lost != 2 || s.Concealed != 2 || s.Resets != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	// 用隐藏的信号更新解码器后, 恢复后的输出与不更新时不同, 两者最终都收敛到无丢包的结果
+	enc, _ := g726.New(g726.Rate32kbps, g726.PackingRight)
+	dec, _ := g726.New(g726.Rate32kbps, g726.PackingRight)
+	want := dec.AppendDecode(nil, enc.AppendEncode(nil, pcm))
+	frameError := func(f Frame) (sum float64) {
+		for i, v := range f.PCM {
+			e := float64(v) - float64(want[int(f.SequenceNumber-65530)*160+i])
+			sum += e * e
+		}
+		return sum
+	}
+	d, _ = NewDepacketizer(g726.Rate32kbps, g726.PackingRight, 96, WithoutPriming())
+	unprimed := pushAll(t, d, append(packets[:3:3], packets[5:]...))
+	if frameError(frames[5]) == frameError(unprimed[5]) {
+		t.Fatal("priming did not change the decoder state")
+	}
+	for _, f := range [][]Frame{frames, unprimed} {
+		if e5, e9 := frameError(f[5]), frameError(f[9]); e9 >= e5/10 {
+			t.Fatalf("error after loss %.0f, at end %.0f", e5, e9)
+		}
+	}
+
+	// 丢包过多时复位
+	d, _ = NewDepacketizer(g726.Rate32kbps, g726.PackingRight, 96, WithMaxConceal(1), WithConcealer(func() Concealer { return Silence{} }))
+	frames = pushAll(t, d, append(packets[:3:3], packets[5:]...))
+	if len(frames) != 8 || !frames[3].Discontinuity || frames[3].SequenceNumber != 65535 {
+		t.Fatalf("%d frames, frame 3 %+v", len(frames), frames[3])
+	}
+	if s := d.Stats(); s.Lost != 2 || s.Concealed != 0 || s.Resets != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	// 复位跳过的包在之后到达时是 Late, 之前输出过的包仍是 Duplicate
+	d, _ = NewDepacketizer(g726.Rate32kbps, g726.PackingRight, 96, WithMaxConceal(1), WithReorder(0))
+	for _, i := range []int{0, 1, 2, 5, 4, 3, 2} {
+		if _, err := d.Push(packets[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := d.Stats(); s.Received != 4 || s.Late != 2 || s.Duplicates != 1 || s.Resets != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestDepacketizerReorder(t *testing.T) {
+	pcm := testSignal(1600, 440)
+	packets := testPackets(t, g726.Rate16kbps, g726.PackingLeft, pcm)
+
+	d, _ := NewDepacketizer(g726.Rate16kbps, g726.PackingLeft, 96)
+	order := []int{0, 2, 1, 3, 3, 5, 4, 6, 7, 8, 9, 1}
+	var frames []Frame
+	for _, i := range order {
+		out, err := d.Push(packets[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, out...)
+	}
+	if len(frames) != 10 {
+		t.Fatalf("%d frames", len(frames))
+	}
+	for i, f := range frames {
+		if f.Concealed || f.SequenceNumber != uint16(65530+i) {
+			t.Fatalf("frame %d: %+v", i, f)
+		}
+	}
+	if s := d.Stats(); s.Received != 10 || s.Reordered != 2 || s.Duplicates != 2 || s.Late != 0 || s.Lost != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	// 超出重排窗口后到达的包
+	d, _ = NewDepacketizer(g726.Rate16kbps, g726.PackingLeft, 96, WithReorder(0))
+	frames = frames[:0]
+	for _, i := range []int{0, 2, 1, 3} {
+		out, _ := d.Push(packets[i])
+		frames = append(frames, out...)
+	}
+	if len(frames) != 4 || !frames[1].Concealed {
+		t.Fatalf("%d frames", len(frames))
+	}
+	if s := d.Stats(); s.Late != 1 || s.Lost != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestDepacketizerInvalid(t *testing.T) {
+	packets := testPackets(t, g726.Rate40kbps, g726.PackingRight, testSignal(320, 440))
+	d, _ := NewDepacketizer(g726.Rate40kbps, g726.PackingRight, 97)
+	if _, err := d.Push(packets[0]); err == nil {
+		t.Fatal("payload type not checked")
+	}
+
+	d, _ = NewDepacketizer(g726.Rate40kbps, g726.PackingRight, 96)
+	if _, err := d.Push(packets[0][:len(packets[0])-1]); err == nil {
+		t.Fatal("payload length not checked")
+	}
+	if _, err := d.Push(packets[0][:5]); err == nil {
+		t.Fatal("short packet accepted")
+	}
+	if s := d.Stats(); s.Invalid != 2 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	if _, err := NewDepacketizer(g726.Rate40kbps, g726.PackingNone, 96); err == nil {
+		t.Fatal("PackingNone accepted")
+	}
+}
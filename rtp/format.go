@@ -0,0 +1,55 @@
+package rtp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/general252/g726"
+)
+
+// ClockRate G.726 的 RTP 时钟频率
+const ClockRate = 8000
+
+// EncodingName 返回 SDP rtpmap 中使用的负载名
+// PackingRight 对应 G726-xx, PackingLeft 对应 AAL2-G726-xx
+func EncodingName(rate g726.Rate, packing g726.PackingType) (string, error) {
+	var kbps int
+	switch rate {
+	case g726.Rate16kbps, g726.Rate24kbps, g726.Rate32kbps, g726.Rate40kbps:
+		kbps = 16 + 8*int(rate-g726.Rate16kbps)
+	default:
+		return "", g726.ErrInvalidRate
+	}
+
+	switch packing {
+	case g726.PackingRight:
+		return fmt.Sprintf("G726-%d", kbps), nil
+	case g726.PackingLeft:
+		return fmt.Sprintf("AAL2-G726-%d", kbps), nil
+	default:
+		return "", g726.ErrInvalidPacking
+	}
+}
+
+// ParseEncodingName 解析负载名, 不区分大小写
+func ParseEncodingName(name string) (g726.Rate, g726.PackingType, error) {
+	packing := g726.PackingRight
+	upper := strings.ToUpper(name)
+	if strings.HasPrefix(upper, "AAL2-") {
+		packing = g726.PackingLeft
+		upper = upper[len("AAL2-"):]
+	}
+
+	switch upper {
+	case "G726-16":
+		return g726.Rate16kbps, packing, nil
+	case "G726-24":
+		return g726.Rate24kbps, packing, nil
+	case "G726-32":
+		return g726.Rate32kbps, packing, nil
+	case "G726-40":
+		return g726.Rate40kbps, packing, nil
+	default:
+		return 0, 0, fmt.Errorf("rtp: unknown G.726 encoding name %q", name)
+	}
+}
@@ -0,0 +1,152 @@
+// Package rtp 实现 G.726 的 RTP 负载格式(RFC 3551 4.5.4)
+//
+// 负载名 G726-16/24/32/40 使用低位优先打包(g726.PackingRight),
+// AAL2-G726-16/24/32/40 使用高位优先打包(g726.PackingLeft), 时钟频率都是 8000Hz
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrShortPacket = errors.New("rtp: packet too short")
+	ErrVersion     = errors.New("rtp: unsupported version")
+	ErrPadding     = errors.New("rtp: invalid padding")
+)
+
+// Header RTP 固定头(RFC 3550 5.1)
+type Header struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+
+	// 头扩展, 仅在 Extension 为 true 时有效, ExtensionData 的长度必须是4的倍数
+	Extension        bool
+	ExtensionProfile uint16
+	ExtensionData    []byte
+}
+
+// MarshalSize 返回头的字节数
+func (h *Header) MarshalSize() int {
+	n := 12 + 4*len(h.CSRC)
+	if h.Extension {
+		n += 4 + len(h.ExtensionData)
+	}
+	return n
+}
+
+// AppendMarshal 把头追加到 dst
+func (h *Header) AppendMarshal(dst []byte) []byte {
+	b0 := byte(2<<6) | byte(len(h.CSRC)&0x0F)
+	if h.Extension {
+		b0 |= 1 << 4
+	}
+	b1 := h.PayloadType & 0x7F
+	if h.Marker {
+		b1 |= 0x80
+	}
+
+	dst = append(dst, b0, b1)
+	dst = binary.BigEndian.AppendUint16(dst, h.SequenceNumber)
+	dst = binary.BigEndian.AppendUint32(dst, h.Timestamp)
+	dst = binary.BigEndian.AppendUint32(dst, h.SSRC)
+	for _, csrc := range h.CSRC {
+		dst = binary.BigEndian.AppendUint32(dst, csrc)
+	}
+	if h.Extension {
+		dst = binary.BigEndian.AppendUint16(dst, h.ExtensionProfile)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(len(h.ExtensionData)/4))
+		dst = append(dst, h.ExtensionData...)
+	}
+	return dst
+}
+
+// Unmarshal 解析 data 开头的头, 返回头的字节数
+// ExtensionData 引用 data 的内容, 不复制
+func (h *Header) Unmarshal(data []byte) (int, error) {
+	if len(data) < 12 {
+		return 0, ErrShortPacket
+	}
+	if data[0]>>6 != 2 {
+		return 0, ErrVersion
+	}
+
+	cc := int(data[0] & 0x0F)
+	h.Extension = data[0]&0x10 != 0
+	h.Marker = data[1]&0x80 != 0
+	h.PayloadType = data[1] & 0x7F
+	h.SequenceNumber = binary.BigEndian.Uint16(data[2:])
+	h.Timestamp = binary.BigEndian.Uint32(data[4:])
+	h.SSRC = binary.BigEndian.Uint32(data[8:])
+
+	n := 12
+	if len(data) < n+4*cc {
+		return 0, ErrShortPacket
+	}
+	h.CSRC = h.CSRC[:0]
+	for i := 0; i < cc; i++ {
+		h.CSRC = append(h.CSRC, binary.BigEndian.Uint32(data[n:]))
+		n += 4
+	}
+
+	h.ExtensionProfile = 0
+	h.ExtensionData = nil
+	if h.Extension {
+		if len(data) < n+4 {
+			return 0, ErrShortPacket
+		}
+		h.ExtensionProfile = binary.BigEndian.Uint16(data[n:])
+		length := 4 * int(binary.BigEndian.Uint16(data[n+2:]))
+		n += 4
+		if len(data) < n+length {
+			return 0, ErrShortPacket
+		}
+		h.ExtensionData = data[n : n+length]
+		n += length
+	}
+
+	return n, nil
+}
+
+// Packet RTP 包
+type Packet struct {
+	Header
+	Payload []byte
+}
+
+// Marshal 返回包的字节
+func (p *Packet) Marshal() []byte {
+	return p.AppendMarshal(make([]byte, 0, p.MarshalSize()+len(p.Payload)))
+}
+
+// AppendMarshal 把包追加到 dst
+func (p *Packet) AppendMarshal(dst []byte) []byte {
+	dst = p.Header.AppendMarshal(dst)
+	return append(dst, p.Payload...)
+}
+
+// Unmarshal 解析 RTP 包, 去掉填充字节, Payload 引用 data 的内容
+func (p *Packet) Unmarshal(data []byte) error {
+	n, err := p.Header.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+
+	end := len(data)
+	if data[0]&0x20 != 0 {
+		if end == n {
+			return ErrPadding
+		}
+		padding := int(data[end-1])
+		if padding == 0 || end-n < padding {
+			return ErrPadding
+		}
+		end -= padding
+	}
+	p.Payload = data[n:end]
+	return nil
+}
@@ -0,0 +1,74 @@
+package rtp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+func TestPacketMarshal(t *testing.T) {
+	pkt := Packet{
+		Header: Header{
+			Marker:           true,
+			PayloadType:      111,
+			SequenceNumber:   65535,
+			Timestamp:        0xDEADBEEF,
+			SSRC:             0x01020304,
+			CSRC:             []uint32{7, 8},
+			Extension:        true,
+			ExtensionProfile: 0xBEDE,
+			ExtensionData:    []byte{1, 2, 3, 4},
+		},
+		Payload: []byte{9, 8, 7, 6, 5},
+	}
+
+	data := pkt.Marshal()
+	if len(data) != pkt.MarshalSize()+len(pkt.Payload) {
+		t.Fatalf("marshalled %d bytes", len(data))
+	}
+
+	var got Packet
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pkt) {
+		t.Fatalf("got %+v, want %+v", got, pkt)
+	}
+
+	// 填充字节不属于负载
+	padded := append([]byte{}, data...)
+	padded[0] |= 0x20
+	padded = append(padded, 0, 0, 3)
+	if err := got.Unmarshal(padded); err != nil || string(got.Payload) != string(pkt.Payload) {
+		t.Fatalf("padded packet: %v, payload %v", err, got.Payload)
+	}
+
+	for _, bad := range [][]byte{data[:11], data[:15], append([]byte{0x40}, data[1:]...)} {
+		if err := got.Unmarshal(bad); err == nil {
+			t.Fatalf("accepted % x", bad)
+		}
+	}
+}
+
+func TestEncodingName(t *testing.T) {
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		for _, packing := range []g726.PackingType{g726.PackingLeft, g726.PackingRight} {
+			name, err := EncodingName(rate, packing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, p, err := ParseEncodingName(name)
+			if err != nil || r != rate || p != packing {
+				t.Fatalf("%s: got %v %v %v", name, r, p, err)
+			}
+		}
+	}
+
+	if name, _ := EncodingName(g726.Rate24kbps, g726.PackingLeft); name != "AAL2-G726-24" {
+		t.Fatalf("got %s", name)
+	}
+	if _, _, err := ParseEncodingName("G726"); err == nil {
+		t.Fatal("accepted G726")
+	}
+}
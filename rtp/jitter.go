@@ -0,0 +1,355 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/general252/g726"
+)
+
+var ErrInvalidDelay = errors.New("rtp: invalid jitter buffer delay")
+
+const (
+	DefaultMinDelay = 20 * time.Millisecond  // 默认最小缓冲时延
+	DefaultMaxDelay = 200 * time.Millisecond // 默认最大缓冲时延
+)
+
+// Clock 提供当前时间, 测试时可以替换为假的时钟
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock 设置 JitterBuffer 使用的时钟, 默认为系统时钟
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithDelay 设置 JitterBuffer 缓冲时延的范围, 实际的时延在范围内随抖动调整
+func WithDelay(min, max time.Duration) Option {
+	return func(c *config) {
+		c.minDelay = min
+		c.maxDelay = max
+	}
+}
+
+// JitterStats JitterBuffer 的统计
+type JitterStats struct {
+	Received   uint64        // 收到的有效包
+	Late       uint64        // 到达时已经过了播放时间的包
+	Lost       uint64        // 播放时跳过的序号(包括迟到的包)
+	Discarded  uint64        // 缓冲过多时为了降低时延丢弃的包
+	Duplicates uint64        // 重复的包
+	Invalid    uint64        // 格式错误的包
+	Concealed  uint64        // 隐藏的采样点(每声道)
+	Underruns  uint64        // 缓冲为空时被拉取的次数
+	Resets     uint64        // 解码器复位次数
+	Jitter     time.Duration // RFC 3550 到达间隔抖动
+	Delay      time.Duration // 当前的目标缓冲时延
+}
+
+type jitterPacket struct {
+	seq     uint16
+	ts      uint32
+	marker  bool
+	payload []byte
+}
+
+// JitterBuffer 自适应抖动缓冲
+//
+// Push 在收到 RTP 包时调用, Pull 每 ptime 调用一次, 每次返回一个 ptime 的 PCM.
+// 缓冲时延根据 RFC 3550 计算的到达间隔抖动在 WithDelay 给出的范围内调整.
+// 中间缺少的包由丢包隐藏替代, 缓冲为空时输出隐藏的数据并增加时延, 缓冲过多时丢弃数据降低时延.
+// JitterBuffer 不是并发安全的.
+type JitterBuffer struct {
+	*channelDecoder
+	payloadType uint8
+	clock       Clock
+	frame       int // 每次 Pull 输出的每声道采样点数
+	minDelay    int // 采样点
+	maxDelay    int
+	maxConceal  int // 采样点
+
+	started bool
+	ssrc    uint32
+	epoch   time.Time
+	arrived bool  // 是否已经收到了包
+	first   int64 // 第一个包的到达时间(采样点)
+	transit int64 // 上一个包的 RFC 3550 transit
+	jitter  float64
+	held    []jitterPacket // 按时间戳排列
+	playing bool
+	playTs  uint32 // 下一个要解码的采样点的时间戳
+	lastSeq uint16 // 最近解码的包的序号
+	decoded bool   // lastSeq 是否有效
+	gap     int    // 连续隐藏的采样点数
+	pending []int16
+	stats   JitterStats
+}
+
+// NewJitterBuffer 创建抖动缓冲, ptime 由 WithPtime 设置
+func NewJitterBuffer(rate g726.Rate, packing g726.PackingType, payloadType uint8, opts ...Option) (*JitterBuffer, error) {
+	c := newConfig(opts)
+	if c.ptime <= 0 || c.ptime%time.Millisecond != 0 {
+		return nil, ErrInvalidPtime
+	}
+	if c.minDelay < 0 || c.maxDelay < c.minDelay || c.maxConceal < 0 {
+		return nil, ErrInvalidDelay
+	}
+	dec, err := newChannelDecoder(rate, packing, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := int(c.ptime / time.Millisecond * ClockRate / 1000)
+	return &JitterBuffer{
+		channelDecoder: dec,
+		payloadType:    payloadType,
+		clock:          c.clock,
+		frame:          frame,
+		minDelay:       durationToSamples(c.minDelay),
+		maxDelay:       durationToSamples(c.maxDelay),
+		maxConceal:     c.maxConceal * frame,
+	}, nil
+}
+
+func durationToSamples(d time.Duration) int {
+	return int(d / (time.Second / ClockRate))
+}
+
+func samplesToDuration(n float64) time.Duration {
+	return time.Duration(n * float64(time.Second) / ClockRate)
+}
+
+// Stats 返回统计
+func (j *JitterBuffer) Stats() JitterStats {
+	s := j.stats
+	s.Jitter = samplesToDuration(j.jitter)
+	s.Delay = samplesToDuration(float64(j.target()))
+	return s
+}
+
+// target 返回目标缓冲时延(采样点), 为一个 ptime 加上4倍的抖动
+func (j *JitterBuffer) target() int {
+	t := j.frame + int(math.Ceil(4*j.jitter))
+	if t < j.minDelay {
+		t = j.minDelay
+	}
+	if t > j.maxDelay {
+		t = j.maxDelay
+	}
+	return t
+}
+
+// now 返回当前时间(采样点)
+func (j *JitterBuffer) now() int64 {
+	return int64(j.clock.Now().Sub(j.epoch) / (time.Second / ClockRate))
+}
+
+// Push 解析收到的一个 RTP 包并放入缓冲
+func (j *JitterBuffer) Push(data []byte) error {
+	var pkt Packet
+	if err := pkt.Unmarshal(data); err != nil {
+		j.stats.Invalid++
+		return err
+	}
+	return j.PushPacket(&pkt)
+}
+
+// PushPacket 与 Push 相同, 输入为已经解析的包
+func (j *JitterBuffer) PushPacket(pkt *Packet) error {
+	if pkt.PayloadType != j.payloadType {
+		j.stats.Invalid++
+		return fmt.Errorf("%w %d", ErrPayloadType, pkt.PayloadType)
+	}
+	if j.samples(pkt.Payload) == 0 {
+		j.stats.Invalid++
+		return ErrPayloadLength
+	}
+
+	if !j.started || pkt.SSRC != j.ssrc {
+		j.restart(pkt.SSRC)
+	}
+
+	// RFC 3550 6.4.1 到达间隔抖动
+	arrival := j.now()
+	transit := arrival - int64(pkt.Timestamp)
+	if j.arrived {
+		d := float64(transit - j.transit)
+		j.jitter += (math.Abs(d) - j.jitter) / 16
+	} else {
+		j.arrived = true
+		j.first = arrival
+	}
+	j.transit = transit
+
+	if j.playing && int32(pkt.Timestamp-j.playTs) < 0 {
+		j.stats.Late++
+		return nil
+	}
+
+	i := len(j.held)
+	for i > 0 && int32(pkt.Timestamp-j.held[i-1].ts) < 0 {
+		i--
+	}
+	if i > 0 && j.held[i-1].ts == pkt.Timestamp {
+		j.stats.Duplicates++
+		return nil
+	}
+	j.stats.Received++
+
+	j.held = append(j.held, jitterPacket{})
+	copy(j.held[i+1:], j.held[i:])
+	j.held[i] = jitterPacket{
+		seq:     pkt.SequenceNumber,
+		ts:      pkt.Timestamp,
+		marker:  pkt.Marker,
+		payload: append([]byte(nil), pkt.Payload...),
+	}
+	return nil
+}
+
+// restart 开始新的流
+func (j *JitterBuffer) restart(ssrc uint32) {
+	if j.started {
+		j.reset()
+	}
+	j.started = true
+	j.ssrc = ssrc
+	j.epoch = j.clock.Now()
+	j.jitter = 0
+	j.held = j.held[:0]
+	j.playing = false
+	j.decoded = false
+	j.gap = 0
+	j.pending = j.pending[:0]
+	j.arrived = false
+}
+
+func (j *JitterBuffer) reset() {
+	j.channelDecoder.reset()
+	j.stats.Resets++
+}
+
+// buffered 返回缓冲中的数据长度(采样点), 从下一个要输出的采样点到最后一个包的结尾
+func (j *JitterBuffer) buffered() int {
+	n := len(j.pending) / j.channels
+	if len(j.held) > 0 {
+		last := j.held[len(j.held)-1]
+		n += int(int32(last.ts-j.playTs)) + j.samples(last.payload)
+	}
+	return n
+}
+
+// Pull 返回一个 ptime 的 PCM(按声道交织), 应该每 ptime 调用一次
+func (j *JitterBuffer) Pull() []int16 {
+	return j.AppendPull(nil)
+}
+
+// AppendPull 与 Pull 相同, 结果追加到 dst
+func (j *JitterBuffer) AppendPull(dst []int16) []int16 {
+	if !j.playing {
+		// 第一个包到达后等待目标时延再开始播放
+		if len(j.held) == 0 || j.now()-j.first < int64(j.target()) {
+			j.silence(j.frame)
+			return j.appendInterleaved(dst, j.frame)
+		}
+		j.playing = true
+		j.playTs = j.held[0].ts
+	}
+
+	// 缓冲超过目标时延一个 ptime 以上时丢弃一个包, 仍然解码以保持解码器状态
+	if excess := j.buffered() - j.target() - j.frame; excess >= j.frame && len(j.pending) == 0 &&
+		len(j.held) > 1 && j.held[0].ts == j.playTs && j.samples(j.held[0].payload) <= excess {
+		h := j.held[0]
+		j.held = j.held[1:]
+		j.playTs += uint32(j.decodePacket(h))
+		j.pending = j.pending[:0]
+		j.stats.Discarded++
+	}
+
+	for len(j.pending) < j.frame*j.channels {
+		need := j.frame - len(j.pending)/j.channels
+
+		for len(j.held) > 0 && int32(j.held[0].ts-j.playTs) < 0 {
+			// 与已经输出的数据重叠
+			j.held = j.held[1:]
+			j.stats.Late++
+		}
+
+		if len(j.held) == 0 {
+			// 缓冲为空, 输出隐藏的数据, 播放时间不前进, 相当于增加了时延
+			j.stats.Underruns++
+			j.fill(need, false)
+			break
+		}
+
+		h := j.held[0]
+		gap := int(int32(h.ts - j.playTs))
+		switch {
+		case gap == 0:
+			j.held = j.held[1:]
+			j.playTs += uint32(j.decodePacket(h))
+			j.pending = j.appendInterleaved(j.pending, len(j.pcm[0]))
+		case gap > j.maxDelay:
+			// 静音抑制的间隔很长或者时间戳跳变, 直接跳到下一个包
+			if !h.marker {
+				j.reset()
+			}
+			j.playTs = h.ts
+		default:
+			// 中间缺少数据, 带有 marker 的包之前是静音抑制的间隔
+			if gap > need {
+				gap = need
+			}
+			j.fill(gap, h.marker)
+			j.playTs += uint32(gap)
+		}
+	}
+
+	dst = append(dst, j.pending[:j.frame*j.channels]...)
+	j.pending = append(j.pending[:0], j.pending[j.frame*j.channels:]...)
+	return dst
+}
+
+// decodePacket 解码一个包, 结果在 pcm 中, 返回每声道的采样点数
+func (j *JitterBuffer) decodePacket(h jitterPacket) int {
+	if j.decoded {
+		if lost := h.seq - j.lastSeq - 1; lost < maxMisorder {
+			j.stats.Lost += uint64(lost)
+		}
+	}
+	j.decoded = true
+	j.lastSeq = h.seq
+	j.gap = 0
+	return j.decode(h.payload)
+}
+
+// fill 生成 n 个采样点放入 pending, silent 为 true 时输出静音, 否则使用丢包隐藏
+// 连续隐藏超过上限后复位解码器并输出静音
+func (j *JitterBuffer) fill(n int, silent bool) {
+	switch {
+	case silent:
+		j.silence(n)
+	case j.gap+n > j.maxConceal:
+		if j.gap <= j.maxConceal {
+			j.reset()
+		}
+		j.gap += n
+		j.silence(n)
+	default:
+		j.gap += n
+		j.conceal(n)
+		j.stats.Concealed += uint64(n)
+	}
+	j.pending = j.appendInterleaved(j.pending, n)
+}
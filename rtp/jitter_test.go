@@ -0,0 +1,217 @@
+package rtp
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/general252/g726"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// simulate 按到达时间送入包, 每20ms拉取一次, 共 pulls 次
+// arrival 为每个包的到达时间, 负数表示丢失
+func simulate(t *testing.T, j *JitterBuffer, clock *fakeClock, packets [][]byte, arrival []time.Duration, pulls int) []int16 {
+	order := make([]int, 0, len(packets))
+	for i := range packets {
+		if arrival[i] >= 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return arrival[order[a]] < arrival[order[b]] })
+
+	start := clock.now
+	var out []int16
+	for k := 0; k < pulls; k++ {
+		tick := time.Duration(k)*20*time.Millisecond + 10*time.Millisecond
+		for len(order) > 0 && arrival[order[0]] <= tick {
+			clock.now = start.Add(arrival[order[0]])
+			if err := j.Push(packets[order[0]]); err != nil {
+				t.Fatal(err)
+			}
+			order = order[1:]
+		}
+		clock.now = start.Add(tick)
+		pcm := j.Pull()
+		if len(pcm) != 160 {
+			t.Fatalf("pull %d returned %d samples", k, len(pcm))
+		}
+		out = append(out, pcm...)
+	}
+	return out
+}
+
+func regularArrival(n int) []time.Duration {
+	arrival := make([]time.Duration, n)
+	for i := range arrival {
+		arrival[i] = time.Duration(i) * 20 * time.Millisecond
+	}
+	return arrival
+}
+
+func newTestJitterBuffer(t *testing.T, opts ...Option) (*JitterBuffer, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	j, err := NewJitterBuffer(g726.Rate32kbps, g726.PackingRight, 96, append([]Option{WithClock(clock)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j, clock
+}
+
+// findOffset 返回 want 在 got 中的起始位置
+func findOffset(got, want []int16) int {
+	for off := 0; off+len(want) <= len(got); off++ {
+		match := true
+		for i := range want {
+			if got[off+i] != want[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return off
+		}
+	}
+	return -1
+}
+
+func TestJitterBuffer(t *testing.T) {
+	pcm := testSignal(160*50, 440)
+	packets := testPackets(t, g726.Rate32kbps, g726.PackingRight, pcm)
+
+	enc, _ := g726.New(g726.Rate32kbps, g726.PackingRight)
+	dec, _ := g726.New(g726.Rate32kbps, g726.PackingRight)
+	want := dec.AppendDecode(nil, enc.AppendEncode(nil, pcm))
+
+	// 没有抖动时输出与直接解码相同, 只是延迟了最小时延
+	j, clock := newTestJitterBuffer(t)
+	got := simulate(t, j, clock, packets, regularArrival(50), 51)
+	if off := findOffset(got, want); off != 160 {
+		t.Fatalf("decoded stream found at offset %d", off)
+	}
+	if s := j.Stats(); s.Received != 50 || s.Late != 0 || s.Lost != 0 || s.Concealed != 0 || s.Jitter != 0 || s.Delay != DefaultMinDelay {
+		t.Fatalf("stats %+v", s)
+	}
+
+	// 丢包由隐藏替代
+	j, clock = newTestJitterBuffer(t)
+	arrival := regularArrival(50)
+	arrival[10] = -1
+	got = simulate(t, j, clock, packets, arrival, 51)
+	if s := j.Stats(); s.Received != 49 || s.Lost != 1 || s.Concealed != 160 || s.Underruns != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+	if findOffset(got, want[:160*10]) != 160 {
+		t.Fatal("output before the loss differs")
+	}
+
+	// 迟到的包
+	j, clock = newTestJitterBuffer(t)
+	arrival = regularArrival(50)
+	arrival[10] += 100 * time.Millisecond
+	simulate(t, j, clock, packets, arrival, 51)
+	if s := j.Stats(); s.Late != 1 || s.Lost != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestJitterBufferAdapt(t *testing.T) {
+	pcm := testSignal(160*200, 440)
+	packets := testPackets(t, g726.Rate32kbps, g726.PackingRight, pcm)
+
+	// 到达时间有最多60ms的抖动, 缓冲时延随之增加, 之后几乎没有迟到的包
+	arrival := regularArrival(200)
+	seed := uint32(1)
+	for i := range arrival {
+		seed = seed*1664525 + 1013904223
+		arrival[i] += time.Duration(seed>>24) * 60 * time.Millisecond / 256
+	}
+
+	j, clock := newTestJitterBuffer(t)
+	simulate(t, j, clock, packets, arrival, 204)
+	s := j.Stats()
+	if s.Jitter < 5*time.Millisecond || s.Delay <= DefaultMinDelay || s.Delay > DefaultMaxDelay {
+		t.Fatalf("stats %+v", s)
+	}
+	if s.Received+s.Late != 200 || s.Late > 10 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	// 延迟后突发到达的包超过目标时延时被丢弃, 时延恢复
+	arrival = regularArrival(100)
+	for i := 20; i < 40; i++ {
+		arrival[i] = 40 * 20 * time.Millisecond
+	}
+	j, clock = newTestJitterBuffer(t, WithDelay(20*time.Millisecond, 60*time.Millisecond))
+	simulate(t, j, clock, packets[:100], arrival, 102)
+	if s := j.Stats(); s.Discarded == 0 || s.Underruns == 0 || j.buffered() > j.target()+2*j.frame {
+		t.Fatalf("stats %+v, buffered %d", s, j.buffered())
+	}
+}
+
+func TestJitterBufferSilenceSuppression(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	p, _ := NewPacketizer(g726.Rate24kbps, g726.PackingLeft, 96, WithSSRC(1))
+	j, _ := NewJitterBuffer(g726.Rate24kbps, g726.PackingLeft, 96, WithClock(clock))
+
+	// 发送10个包, 静音1秒, 再发送10个包
+	var packets [][]byte
+	add := func(pcm []int16) {
+		out, _ := p.Packetize(pcm)
+		for _, pkt := range out {
+			packets = append(packets, pkt.Marshal())
+		}
+	}
+	add(testSignal(1600, 440))
+	p.Skip(8000)
+	add(testSignal(1600, 440))
+
+	arrival := regularArrival(20)
+	for i := 10; i < 20; i++ {
+		arrival[i] += time.Second
+	}
+	out := simulate(t, j, clock, packets, arrival, 70)
+	if s := j.Stats(); s.Lost != 0 || s.Received != 20 || s.Concealed > 800 {
+		t.Fatalf("stats %+v", s)
+	}
+	// 缓冲为空后先隐藏5个包, 然后输出静音直到下一段语音
+	for i := 160 * 16; i < 160*60; i++ {
+		if out[i] != 0 {
+			t.Fatalf("sample %d = %d in the silent period", i, out[i])
+		}
+	}
+	if out[160*60+100] == 0 {
+		t.Fatal("second talkspurt missing")
+	}
+}
+
+func TestJitterBufferSSRC(t *testing.T) {
+	pcm := testSignal(160*10, 440)
+	packets := testPackets(t, g726.Rate32kbps, g726.PackingRight, pcm)
+
+	j, clock := newTestJitterBuffer(t)
+	simulate(t, j, clock, packets[:5], regularArrival(5), 5)
+
+	var pkt Packet
+	if err := pkt.Unmarshal(packets[5]); err != nil {
+		t.Fatal(err)
+	}
+	pkt.SSRC++
+	if err := j.PushPacket(&pkt); err != nil {
+		t.Fatal(err)
+	}
+	if s := j.Stats(); s.Resets != 1 || j.playing {
+		t.Fatalf("stats %+v", s)
+	}
+
+	if _, err := NewJitterBuffer(g726.Rate32kbps, g726.PackingRight, 96, WithDelay(time.Second, time.Millisecond)); err == nil {
+		t.Fatal("invalid delay accepted")
+	}
+}
@@ -0,0 +1,249 @@
+package rtp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/general252/g726"
+)
+
+var (
+	ErrInvalidPtime    = errors.New("rtp: ptime must be a positive multiple of 1ms")
+	ErrInvalidChannels = errors.New("rtp: invalid channel count")
+)
+
+// DefaultPtime 默认打包时长
+const DefaultPtime = 20 * time.Millisecond
+
+type config struct {
+	ptime     time.Duration
+	channels  int
+	ssrc      *uint32
+	sequence  *uint16
+	timestamp *uint32
+
+	reorder    int
+	maxConceal int
+	concealer  func() Concealer
+	noPriming  bool
+
+	clock    Clock
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		ptime:      DefaultPtime,
+		channels:   1,
+		reorder:    DefaultReorder,
+		maxConceal: DefaultMaxConceal,
+		concealer:  func() Concealer { return &Repeat{} },
+		clock:      systemClock{},
+		minDelay:   DefaultMinDelay,
+		maxDelay:   DefaultMaxDelay,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Option 用于 NewPacketizer, NewDepacketizer 和 NewJitterBuffer 的可选配置
+type Option func(*config)
+
+// WithPtime 设置每个包的时长, 必须是1ms的整数倍(8个采样点, 保证24kbps和40kbps的负载是整数个字节)
+func WithPtime(ptime time.Duration) Option {
+	return func(c *config) {
+		c.ptime = ptime
+	}
+}
+
+// WithChannels 设置声道数, 默认为1
+// 多声道时按 RFC 3551 4.1 交织: 同一时刻各声道的码字依次排列, 然后是下一时刻
+func WithChannels(n int) Option {
+	return func(c *config) {
+		c.channels = n
+	}
+}
+
+// WithSSRC 设置 SSRC, 默认随机
+func WithSSRC(ssrc uint32) Option {
+	return func(c *config) {
+		c.ssrc = &ssrc
+	}
+}
+
+// WithSequence 设置第一个包的序号, 默认随机
+func WithSequence(seq uint16) Option {
+	return func(c *config) {
+		c.sequence = &seq
+	}
+}
+
+// WithTimestamp 设置第一个包的时间戳, 默认随机
+func WithTimestamp(ts uint32) Option {
+	return func(c *config) {
+		c.timestamp = &ts
+	}
+}
+
+// Packetizer 把 PCM 编码为 G.726 并打包为 RTP 包
+type Packetizer struct {
+	payloadType uint8
+	bits        int
+	channels    int
+	frame       int // 每个包每个声道的采样点数
+
+	encoder *g726.MultiChannelEncoder
+	pcm     []int16 // 未凑齐一个包的交织采样点
+
+	ssrc      uint32
+	sequence  uint16
+	timestamp uint32
+	marker    bool
+}
+
+// NewPacketizer 创建打包器, packing 为 PackingRight(G726-xx) 或 PackingLeft(AAL2-G726-xx)
+func NewPacketizer(rate g726.Rate, packing g726.PackingType, payloadType uint8, opts ...Option) (*Packetizer, error) {
+	if packing != g726.PackingRight && packing != g726.PackingLeft {
+		return nil, g726.ErrInvalidPacking
+	}
+	if payloadType > 127 {
+		return nil, fmt.Errorf("rtp: invalid payload type %d", payloadType)
+	}
+
+	c := newConfig(opts)
+	if c.ptime <= 0 || c.ptime%time.Millisecond != 0 {
+		return nil, ErrInvalidPtime
+	}
+	if c.channels < 1 || c.channels > 255 {
+		return nil, ErrInvalidChannels
+	}
+
+	p := &Packetizer{
+		payloadType: payloadType,
+		channels:    c.channels,
+		frame:       int(c.ptime / time.Millisecond * ClockRate / 1000),
+		marker:      true,
+	}
+	enc, err := g726.NewMultiChannelEncoder(rate, packing, c.channels)
+	if err != nil {
+		return nil, err
+	}
+	p.encoder = enc
+	p.bits = bitsPerSample(rate)
+
+	p.ssrc = randomUint32()
+	if c.ssrc != nil {
+		p.ssrc = *c.ssrc
+	}
+	p.sequence = uint16(randomUint32())
+	if c.sequence != nil {
+		p.sequence = *c.sequence
+	}
+	p.timestamp = randomUint32()
+	if c.timestamp != nil {
+		p.timestamp = *c.timestamp
+	}
+
+	return p, nil
+}
+
+// SSRC 返回包的 SSRC
+func (p *Packetizer) SSRC() uint32 {
+	return p.ssrc
+}
+
+// Samples 返回每个包每个声道的采样点数
+func (p *Packetizer) Samples() int {
+	return p.frame
+}
+
+// Packetize 编码 pcm 并返回凑齐的包, 不足一个包的采样点留到下次
+// 多声道时 pcm 按声道交织, 长度必须是声道数的整数倍
+func (p *Packetizer) Packetize(pcm []int16) ([]Packet, error) {
+	if len(pcm)%p.channels != 0 {
+		return nil, fmt.Errorf("%w: %d samples for %d channels", g726.ErrInputLength, len(pcm), p.channels)
+	}
+
+	var packets []Packet
+	size := p.frame * p.channels
+	for len(pcm) > 0 {
+		n := size - len(p.pcm)
+		if n > len(pcm) {
+			n = len(pcm)
+		}
+		p.pcm = append(p.pcm, pcm[:n]...)
+		pcm = pcm[n:]
+
+		if len(p.pcm) == size {
+			packets = append(packets, p.packet(p.pcm))
+			p.pcm = p.pcm[:0]
+		}
+	}
+
+	return packets, nil
+}
+
+// Flush 把缓冲中不足一个包的采样点补静音后打包, 没有缓冲的采样点时返回 nil
+func (p *Packetizer) Flush() []Packet {
+	if len(p.pcm) == 0 {
+		return nil
+	}
+	for len(p.pcm) < p.frame*p.channels {
+		p.pcm = append(p.pcm, 0)
+	}
+	packets := []Packet{p.packet(p.pcm)}
+	p.pcm = p.pcm[:0]
+	return packets
+}
+
+// Skip 跳过 samples 个采样点(每声道)而不发送, 用于静音抑制
+// 时间戳相应增加, 下一个包设置 marker 位表示新的话音段开始
+func (p *Packetizer) Skip(samples int) {
+	p.timestamp += uint32(samples)
+	p.marker = true
+}
+
+// MarkNext 让下一个包设置 marker 位
+func (p *Packetizer) MarkNext() {
+	p.marker = true
+}
+
+func (p *Packetizer) packet(pcm []int16) Packet {
+	// 每个声道独立编码, 按采样时刻交织后打包
+	// 每个包的采样点数是8的倍数, 负载总是整字节, 不会有剩余的比特
+	payload, _ := p.encoder.AppendEncodeInterleaved(make([]byte, 0, len(pcm)*p.bits/8), pcm)
+
+	pkt := Packet{
+		Header: Header{
+			Marker:         p.marker,
+			PayloadType:    p.payloadType,
+			SequenceNumber: p.sequence,
+			Timestamp:      p.timestamp,
+			SSRC:           p.ssrc,
+		},
+		Payload: payload,
+	}
+
+	p.marker = false
+	p.sequence++
+	p.timestamp += uint32(p.frame)
+	return pkt
+}
+
+func bitsPerSample(rate g726.Rate) int {
+	return 2 + int(rate-g726.Rate16kbps)
+}
+
+func randomUint32() uint32 {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return uint32(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
@@ -0,0 +1,129 @@
+package rtp
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/general252/g726"
+)
+
+func testSignal(n int, freq float64) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(8000 * math.Sin(2*math.Pi*freq*float64(i)/ClockRate))
+	}
+	return pcm
+}
+
+func TestPacketizer(t *testing.T) {
+	pcm := testSignal(1000, 440)
+
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		for _, packing := range []g726.PackingType{g726.PackingLeft, g726.PackingRight} {
+			p, err := NewPacketizer(rate, packing, 96, WithPtime(10*time.Millisecond), WithSSRC(1234), WithSequence(65534), WithTimestamp(100))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 分多次送入, 包的边界与送入的长度无关
+			var packets []Packet
+			off := 0
+			for _, n := range []int{7, 313, 480, 200} {
+				out, err := p.Packetize(pcm[off : off+n])
+				off += n
+				if err != nil {
+					t.Fatal(err)
+				}
+				packets = append(packets, out...)
+			}
+			if len(packets) != 12 {
+				t.Fatalf("got %d packets", len(packets))
+			}
+
+			ref, _ := g726.New(rate, packing)
+			for i, pkt := range packets {
+				if pkt.SSRC != 1234 || pkt.PayloadType != 96 || pkt.SequenceNumber != uint16(65534+i) || pkt.Timestamp != uint32(100+80*i) {
+					t.Fatalf("packet %d: header %+v", i, pkt.Header)
+				}
+				if pkt.Marker != (i == 0) {
+					t.Fatalf("packet %d: marker %v", i, pkt.Marker)
+				}
+				want := ref.EncodeV2(pcm[80*i : 80*(i+1)])
+				if string(pkt.Payload) != string(want) {
+					t.Fatalf("%v packing %v: packet %d payload differs", rate, packing, i)
+				}
+			}
+
+			p.Skip(160)
+			out, _ := p.Packetize(make([]int16, 80))
+			if !out[0].Marker || out[0].Timestamp != 100+80*12+160 {
+				t.Fatalf("after Skip: header %+v", out[0].Header)
+			}
+		}
+	}
+}
+
+func TestPacketizerChannels(t *testing.T) {
+	left, right := testSignal(160, 440), testSignal(160, 1000)
+	pcm := make([]int16, 0, 320)
+	for i := range left {
+		pcm = append(pcm, left[i], right[i])
+	}
+
+	p, err := NewPacketizer(g726.Rate24kbps, g726.PackingRight, 97, WithChannels(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := p.Packetize(pcm)
+	if err != nil || len(packets) != 1 {
+		t.Fatalf("got %d packets, %v", len(packets), err)
+	}
+	payload := packets[0].Payload
+	if len(payload) != 2*160*3/8 {
+		t.Fatalf("payload is %d bytes", len(payload))
+	}
+
+	// 解出码字, 偶数位置是左声道, 奇数位置是右声道
+	var codes []byte
+	var acc uint32
+	var n int
+	for _, b := range payload {
+		acc |= uint32(b) << n
+		for n += 8; n >= 3; n -= 3 {
+			codes = append(codes, byte(acc&7))
+			acc >>= 3
+		}
+	}
+
+	encL, _ := g726.New(g726.Rate24kbps, g726.PackingNone)
+	encR, _ := g726.New(g726.Rate24kbps, g726.PackingNone)
+	wantL, wantR := encL.EncodeV2(left), encR.EncodeV2(right)
+	for i := range left {
+		if codes[2*i] != wantL[i] || codes[2*i+1] != wantR[i] {
+			t.Fatalf("sample %d: codes %d %d, want %d %d", i, codes[2*i], codes[2*i+1], wantL[i], wantR[i])
+		}
+	}
+
+	if _, err = p.Packetize(pcm[:3]); err == nil {
+		t.Fatal("accepted an odd number of stereo samples")
+	}
+	if _, err = NewPacketizer(g726.Rate32kbps, g726.PackingRight, 96, WithPtime(2500*time.Microsecond)); err != ErrInvalidPtime {
+		t.Fatalf("got %v, want ErrInvalidPtime", err)
+	}
+	if _, err = NewPacketizer(g726.Rate32kbps, g726.PackingNone, 96); err != g726.ErrInvalidPacking {
+		t.Fatalf("got %v, want ErrInvalidPacking", err)
+	}
+}
+
+func TestPacketizerFlush(t *testing.T) {
+	p, _ := NewPacketizer(g726.Rate40kbps, g726.PackingLeft, 96)
+	if p.Flush() != nil {
+		t.Fatal("Flush with no samples returned packets")
+	}
+	p.Packetize(testSignal(50, 440))
+	packets := p.Flush()
+	if len(packets) != 1 || len(packets[0].Payload) != 160*5/8 {
+		t.Fatalf("Flush returned %d packets", len(packets))
+	}
+}
@@ -0,0 +1,140 @@
+package g726
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 状态快照格式版本
+const snapshotVersion = 1
+
+// 快照长度
+const snapshotSize = 1 + 4 + 5*4 + (2+6+2)*4 + 6*2 + 2*4 + 1 + 4 + 4 + 8 + 8
+
+// MarshalBinary 实现 encoding.BinaryMarshaler, 保存编解码器的全部状态(包括码流中剩余的比特)
+func (state_ptr *G726_state) MarshalBinary() ([]byte, error) {
+	s := state_ptr
+	b := make([]byte, 0, snapshotSize)
+
+	b = append(b, snapshotVersion, byte(s.rate), byte(s.packing), byte(s.ext_coding), byte(s.direction))
+	for _, v := range []int{s.yl, s.yu, s.dms, s.dml, s.ap} {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(v)))
+	}
+	for _, v := range s.a {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(v)))
+	}
+	for _, v := range s.b {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(v)))
+	}
+	for _, v := range s.pk {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(v)))
+	}
+	for _, v := range s.dq {
+		b = binary.BigEndian.AppendUint16(b, uint16(v))
+	}
+	for _, v := range s.sr {
+		b = binary.BigEndian.AppendUint32(b, uint32(int32(v)))
+	}
+	b = append(b, byte(s.td))
+	b = binary.BigEndian.AppendUint32(b, s.bs.bitstream)
+	b = binary.BigEndian.AppendUint32(b, uint32(s.bs.residue))
+	b = binary.BigEndian.AppendUint64(b, uint64(s.samples))
+	b = binary.BigEndian.AppendUint64(b, uint64(s.sample_limit))
+
+	return b, nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler, 从 MarshalBinary 的输出恢复状态
+func (state_ptr *G726_state) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty state snapshot")
+	}
+	if data[0] != snapshotVersion {
+		return fmt.Errorf("unsupported state snapshot version %d", data[0])
+	}
+	if len(data) != snapshotSize {
+		return fmt.Errorf("invalid state snapshot length %d", len(data))
+	}
+
+	var s G726_state
+	s.rate = Rate(data[1])
+	s.packing = PackingType(data[2])
+	if !s.packing.valid() {
+		return fmt.Errorf("%w %d in state snapshot", ErrInvalidPacking, data[2])
+	}
+	if !s.bind() {
+		return fmt.Errorf("%w %d in state snapshot", ErrInvalidRate, data[1])
+	}
+
+	s.ext_coding = ExtCoding(data[3])
+	if !s.ext_coding.valid() {
+		return fmt.Errorf("%w %d in state snapshot", ErrInvalidCoding, data[3])
+	}
+	s.direction = int8(data[4])
+	if s.direction != dir_none && s.direction != dir_encode && s.direction != dir_decode {
+		return fmt.Errorf("invalid direction %d in state snapshot", data[4])
+	}
+	p := data[5:]
+	next32 := func() int {
+		v := int(int32(binary.BigEndian.Uint32(p)))
+		p = p[4:]
+		return v
+	}
+
+	s.yl = next32()
+	s.yu = next32()
+	s.dms = next32()
+	s.dml = next32()
+	s.ap = next32()
+	for i := range s.a {
+		s.a[i] = next32()
+	}
+	for i := range s.b {
+		s.b[i] = next32()
+	}
+	for i := range s.pk {
+		s.pk[i] = next32()
+	}
+	for i := range s.dq {
+		s.dq[i] = int16(binary.BigEndian.Uint16(p))
+		p = p[2:]
+	}
+	for i := range s.sr {
+		s.sr[i] = next32()
+	}
+	s.td = int(p[0])
+	if s.td != 0 && s.td != 1 {
+		return fmt.Errorf("invalid tone detect %d in state snapshot", p[0])
+	}
+	p = p[1:]
+	s.bs.bitstream = uint32(next32())
+	s.bs.residue = int32(next32())
+	// 每次编解码之后剩余不足8个比特, SetRate 补齐后为8个
+	if s.bs.residue < 0 || s.bs.residue > 8 {
+		return fmt.Errorf("invalid bit residue %d in state snapshot", s.bs.residue)
+	}
+	s.samples = int64(binary.BigEndian.Uint64(p))
+	s.sample_limit = int64(binary.BigEndian.Uint64(p[8:]))
+
+	*state_ptr = s
+	return nil
+}
+
+// Clone 返回状态的独立副本, 副本与原状态互不影响
+func (state_ptr *G726_state) Clone() *G726_state {
+	c := *state_ptr
+	return &c
+}
+
+// Equal 比较两个状态是否完全相同(包括码流中剩余的比特和采样点计数)
+func (state_ptr *G726_state) Equal(other *G726_state) bool {
+	s, o := state_ptr, other
+	if s == nil || o == nil {
+		return s == o
+	}
+
+	return s.yl == o.yl && s.yu == o.yu && s.dms == o.dms && s.dml == o.dml && s.ap == o.ap &&
+		s.a == o.a && s.b == o.b && s.pk == o.pk && s.dq == o.dq && s.sr == o.sr && s.td == o.td &&
+		s.rate == o.rate && s.packing == o.packing && s.ext_coding == o.ext_coding && s.bs == o.bs && s.bits_per_sample == o.bits_per_sample &&
+		s.direction == o.direction && s.samples == o.samples && s.sample_limit == o.sample_limit
+}
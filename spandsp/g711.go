@@ -281,6 +281,7 @@ func (s *g711_state_s) g711_encode(amp []int16_t) (g711_data []uint8_t) {
 }
 
 func (s *g711_state_s) g711_transcode(g711_in []uint8_t) (g711_out []uint8_t) {
+	g711_out = make([]uint8_t, len(g711_in))
 	var i int
 
 	switch s.mode {
@@ -0,0 +1,38 @@
+package spandsp
+
+import (
+	"testing"
+
+	"github.com/general252/g726/g711"
+)
+
+func Test_g711_against_package(t *testing.T) {
+	for i := -32768; i <= 32767; i++ {
+		amp := int16_t(i)
+		if a, b := linear_to_alaw(amp), g711.EncodeALaw(amp); a != b {
+			t.Fatalf("A-law %d: %#x, want %#x", amp, a, b)
+		}
+		if a, b := linear_to_ulaw(int32_t(amp)), g711.EncodeULaw(amp); a != b {
+			t.Fatalf("u-law %d: %#x, want %#x", amp, a, b)
+		}
+	}
+
+	g711_in := make([]uint8_t, 256)
+	for i := range g711_in {
+		g711_in[i] = uint8_t(i)
+		if alaw_to_linear(uint8_t(i)) != g711.DecodeALaw(uint8_t(i)) || ulaw_to_linear(uint8_t(i)) != g711.DecodeULaw(uint8_t(i)) {
+			t.Fatalf("decoding %#x differs", i)
+		}
+	}
+
+	for _, mode := range []int{G711_ALAW, G711_ULAW} {
+		from := g711.ALaw
+		if mode == G711_ULAW {
+			from = g711.ULaw
+		}
+		got := g711_init(mode).g711_transcode(g711_in)
+		if want := g711.AppendTranscode(nil, from, g711_in); string(got) != string(want) {
+			t.Fatalf("mode %d: transcoding differs", mode)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package spandsp
 
 import (
 	"errors"
-	"fmt"
+	"io"
 )
 
 /*
@@ -558,7 +558,7 @@ func (s *g726_state_t) update(y, wi, fi, dq, sr, dqsez int_t) {
 }
 
 func tandem_adjust_alaw(
-	sr int16_t, /* decoder output linear PCM sample */
+	sr int_t, /* decoder output linear PCM sample */
 	se int_t, /* predictor estimate sample */
 	y int_t, /* quantizer step size */
 	i int_t, /* decoder input code */
@@ -575,7 +575,7 @@ func tandem_adjust_alaw(
 		sr = -1
 	}
 
-	sp = int_t(linear_to_alaw((sr >> 1) << 3))
+	sp = int_t(linear_to_alaw(saturate16(int32_t((sr >> 1) << 3))))
 	/* 16-bit prediction error */
 	dx = int_t(alaw_to_linear(uint8_t(sp)))>>2 - se
 	id = quantize(dx, y, qtab, quantizer_states)
@@ -627,7 +627,7 @@ func tandem_adjust_alaw(
 }
 
 func tandem_adjust_ulaw(
-	sr int16_t, /* decoder output linear PCM sample */
+	sr int_t, /* decoder output linear PCM sample */
 	se int_t, /* predictor estimate sample */
 	y int_t, /* quantizer step size */
 	i int_t, /* decoder input code */
@@ -765,10 +765,10 @@ func (s *g726_state_t) g726_16_decoder(code uint8_t) int16_t {
 	s.update(y, g726_16_witab[code], g726_16_fitab[code], dq, sr, dqsez)
 
 	switch s.ext_coding {
-	case G726_ENCODING_ALAW:
-		return tandem_adjust_alaw(int16_t(sr), se, y, int_t(code), 2, qtab_726_16[:], 4)
-	case G726_ENCODING_ULAW:
-		return tandem_adjust_ulaw(int16_t(sr), se, y, int_t(code), 2, qtab_726_16[:], 4)
+	case EncodingALaw:
+		return tandem_adjust_alaw(sr, se, y, int_t(code), 2, qtab_726_16[:], 4)
+	case EncodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, int_t(code), 2, qtab_726_16[:], 4)
 	}
 
 	return int16_t(sr << 2)
@@ -848,10 +848,10 @@ func (s *g726_state_t) g726_24_decoder(code uint8_t) int16_t {
 	s.update(y, g726_24_witab[code], g726_24_fitab[code], dq, sr, dqsez)
 
 	switch s.ext_coding {
-	case G726_ENCODING_ALAW:
-		return tandem_adjust_alaw(int16_t(sr), se, y, int_t(code), 4, qtab_726_24[:], 7)
-	case G726_ENCODING_ULAW:
-		return tandem_adjust_ulaw(int16_t(sr), se, y, int_t(code), 4, qtab_726_24[:], 7)
+	case EncodingALaw:
+		return tandem_adjust_alaw(sr, se, y, int_t(code), 4, qtab_726_24[:], 7)
+	case EncodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, int_t(code), 4, qtab_726_24[:], 7)
 	}
 
 	return int16_t(sr << 2)
@@ -927,10 +927,10 @@ func (s *g726_state_t) g726_32_decoder(code uint8_t) int16_t {
 	s.update(y, g726_32_witab[code], g726_32_fitab[code], dq, sr, dqsez)
 
 	switch s.ext_coding {
-	case G726_ENCODING_ALAW:
-		return tandem_adjust_alaw(int16_t(sr), se, y, int_t(code), 8, qtab_726_32[:], 15)
-	case G726_ENCODING_ULAW:
-		return tandem_adjust_ulaw(int16_t(sr), se, y, int_t(code), 8, qtab_726_32[:], 15)
+	case EncodingALaw:
+		return tandem_adjust_alaw(sr, se, y, int_t(code), 8, qtab_726_32[:], 15)
+	case EncodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, int_t(code), 8, qtab_726_32[:], 15)
 	}
 
 	return int16_t(sr << 2)
@@ -1010,120 +1010,96 @@ func (s *g726_state_t) g726_40_decoder(code uint8_t) int16_t {
 	s.update(y, g726_40_witab[code], g726_40_fitab[code], dq, sr, dqsez)
 
 	switch s.ext_coding {
-	case G726_ENCODING_ALAW:
-		return tandem_adjust_alaw(int16_t(sr), se, y, int_t(code), 0x10, qtab_726_40[:], 31)
-	case G726_ENCODING_ULAW:
-		return tandem_adjust_ulaw(int16_t(sr), se, y, int_t(code), 0x10, qtab_726_40[:], 31)
+	case EncodingALaw:
+		return tandem_adjust_alaw(sr, se, y, int_t(code), 0x10, qtab_726_40[:], 31)
+	case EncodingULaw:
+		return tandem_adjust_ulaw(sr, se, y, int_t(code), 0x10, qtab_726_40[:], 31)
 	}
 
 	return int16_t(sr << 2)
 }
 
-func (s *g726_state_t) Decode(g726_data []uint8_t) (amp []int16_t) {
-	var g726_bytes = len(g726_data)
-	amp = make([]int16_t, 0, g726_bytes)
+// Decode decodes g726Data and returns the samples. For A-law and u-law ext
+// coding each returned sample carries one G.711 octet.
+func (s *G726State) Decode(g726Data []byte) (amp []int16) {
+	return s.AppendDecode(make([]int16, 0, s.DecodedLen(len(g726Data))), g726Data)
+}
+
+// AppendDecode decodes g726Data and appends the samples to amp.
+func (s *G726State) AppendDecode(amp []int16, g726Data []byte) []int16 {
+	var g726Bytes = len(g726Data)
 	var i int
-	var samples int
 	var code uint8_t
-	var sl int16_t
 
 	for {
-		if s.packing != G726_PACKING_NONE {
+		if s.packing != PackingNone {
 			/* Unpack the code bits */
-			if s.packing != G726_PACKING_LEFT {
+			if s.packing != PackingLeft {
 				if s.bs.residue < s.bits_per_sample {
-					if i >= g726_bytes {
+					if i >= g726Bytes {
 						break
 					}
-
-					s.bs.bitstream |= uint32_t(g726_data[i]) << uint32_t(s.bs.residue)
+					s.bs.bitstream |= uint32_t(g726Data[i]) << uint32_t(s.bs.residue)
 					i += 1
-
 					s.bs.residue += 8
 				}
 				code = (uint8_t)(s.bs.bitstream & ((1 << s.bits_per_sample) - 1))
 				s.bs.bitstream >>= s.bits_per_sample
 			} else {
 				if s.bs.residue < s.bits_per_sample {
-					if i >= g726_bytes {
+					if i >= g726Bytes {
 						break
 					}
-					s.bs.bitstream = (s.bs.bitstream << 8) | uint32_t(g726_data[i])
+					s.bs.bitstream = (s.bs.bitstream << 8) | uint32_t(g726Data[i])
 					i += 1
 					s.bs.residue += 8
 				}
-
 				code = (uint8_t)((s.bs.bitstream >> (s.bs.residue - s.bits_per_sample)) & ((1 << s.bits_per_sample) - 1))
 			}
 			s.bs.residue -= s.bits_per_sample
 		} else {
-			if i >= g726_bytes {
+			if i >= g726Bytes {
 				break
 			}
-			code = g726_data[i]
+			code = g726Data[i]
 			i += 1
 		}
 
-		sl = s.dec_func(code)
-		if s.ext_coding != G726_ENCODING_LINEAR {
-			amp[samples] = sl
-			samples += 1
-		} else {
-			// amp[samples] = sl
-			amp = append(amp, sl)
-			samples += 1
-		}
+		amp = append(amp, s.dec_func(code))
 	}
 
 	return amp
 }
 
-func (s *g726_state_t) Encode(amp []int16_t) (g726_data []uint8_t) {
-	g726_data = make([]uint8_t, 0, len(amp))
+// Encode encodes amp and returns the G.726 data. For A-law and u-law ext
+// coding each element of amp carries one G.711 octet in its low 8 bits.
+func (s *G726State) Encode(amp []int16) (g726Data []byte) {
+	return s.AppendEncode(make([]byte, 0, s.EncodedLen(len(amp))), amp)
+}
 
-	var i int
-	var g726_bytes int
+// AppendEncode encodes amp and appends the G.726 data to g726Data.
+func (s *G726State) AppendEncode(g726Data []byte, amp []int16) []byte {
 	var sl int16_t
 	var code uint8_t
 
-	for ; i < len(amp); i++ {
+	for i := 0; i < len(amp); i++ {
 		switch s.ext_coding {
-		case G726_ENCODING_ALAW:
-			// TODO:
-			// sl = alaw_to_linear(((const uint8_t *) amp)[i]) >> 2;
-		case G726_ENCODING_ULAW:
-			// TODO:
-			// sl = ulaw_to_linear(((const uint8_t *) amp)[i]) >> 2;
+		case EncodingALaw:
+			sl = alaw_to_linear(uint8_t(amp[i])) >> 2
+		case EncodingULaw:
+			sl = ulaw_to_linear(uint8_t(amp[i])) >> 2
 		default:
 			sl = amp[i] >> 2
 		}
 
 		code = s.enc_func(sl)
-		if false {
-			lsb := 0
-			if s.bs.lsb_first {
-				lsb = 1
-			}
-			fmt.Printf("%d %4d %4d yl:%v yu:%v dms:%v dml:%v ap:%v a:%v %v b:%v %v %v %v %v %v pk:%v %v dq:%v %v %v %v %v %v sr:%v %v bitstream:%v residue:%v lsb:%v\n",
-				s.packets,
-				i, code, s.yl, s.yu, s.dms, s.dml, s.ap, s.a[0], s.a[1],
-				s.b[0], s.b[1], s.b[2], s.b[3], s.b[4], s.b[5],
-				s.pk[0], s.pk[1],
-				s.dq[0], s.dq[1], s.dq[2], s.dq[3], s.dq[4], s.dq[5],
-				s.sr[0], s.sr[1],
-				s.bs.bitstream, s.bs.residue, lsb,
-			)
-		}
-
-		if s.packing != G726_PACKING_NONE {
+		if s.packing != PackingNone {
 			/* Pack the code bits */
-			if s.packing != G726_PACKING_LEFT {
+			if s.packing != PackingLeft {
 				s.bs.bitstream |= uint32_t(code) << uint32_t(s.bs.residue)
 				s.bs.residue += s.bits_per_sample
 				if s.bs.residue >= 8 {
-					// g726_data[g726_bytes] = (uint8_t)(s.bs.bitstream & 0xFF)
-					g726_data = append(g726_data, (uint8_t)(s.bs.bitstream&0xFF))
-					g726_bytes += 1
+					g726Data = append(g726Data, (uint8_t)(s.bs.bitstream&0xFF))
 					s.bs.bitstream >>= 8
 					s.bs.residue -= 8
 				}
@@ -1131,25 +1107,62 @@ func (s *g726_state_t) Encode(amp []int16_t) (g726_data []uint8_t) {
 				s.bs.bitstream = (s.bs.bitstream << uint32_t(s.bits_per_sample)) | uint32_t(code)
 				s.bs.residue += s.bits_per_sample
 				if s.bs.residue >= 8 {
-					// g726_data[g726_bytes] = (uint8_t)((s.bs.bitstream >> (s.bs.residue - 8)) & 0xFF)
-					g726_data = append(g726_data, (uint8_t)((s.bs.bitstream>>(s.bs.residue-8))&0xFF))
-					g726_bytes += 1
+					g726Data = append(g726Data, (uint8_t)((s.bs.bitstream>>(s.bs.residue-8))&0xFF))
 					s.bs.residue -= 8
 				}
 			}
 		} else {
-			// g726_data[g726_bytes] = code
-			g726_data = append(g726_data, code)
-			g726_bytes += 1
+			g726Data = append(g726Data, code)
 		}
 	}
 
-	return g726_data
+	return g726Data
 }
 
-func G726_init(bit_rate, ext_coding, packing int32_t) (*g726_state_t, error) {
+// EncodedLen returns the number of bytes Encode produces for the given
+// number of samples, including bits left over from earlier calls.
+func (s *G726State) EncodedLen(samples int) int {
+	if s.packing == PackingNone {
+		return samples
+	}
+	return (int(s.bs.residue) + samples*int(s.bits_per_sample)) / 8
+}
+
+// DecodedLen returns the number of samples Decode produces for the given
+// number of bytes, including bits left over from earlier calls.
+func (s *G726State) DecodedLen(g726Bytes int) int {
+	if s.packing == PackingNone {
+		return g726Bytes
+	}
+	return (int(s.bs.residue) + g726Bytes*8) / int(s.bits_per_sample)
+}
+
+// NewG726State creates a G.726 encode or decode context, as g726_init does in
+// spandsp. bitRate is 16000, 24000, 32000 or 40000.
+func NewG726State(bitRate int, ext Encoding, packing Packing) (*G726State, error) {
+	if bitRate != 16000 && bitRate != 24000 && bitRate != 32000 && bitRate != 40000 {
+		return nil, ErrInvalidBitRate
+	}
+	return g726_init(int32_t(bitRate), ext, packing)
+}
+
+// G726_init initialises a G.726 encode or decode context.
+//
+// Deprecated: use NewG726State.
+func G726_init(bit_rate int32, ext_coding Encoding, packing Packing) (*G726State, error) {
+	return NewG726State(int(bit_rate), ext_coding, packing)
+}
+
+// g726_init initialises a G.726 encode or decode context.
+func g726_init(bit_rate int32_t, ext_coding Encoding, packing Packing) (*g726_state_t, error) {
 	if bit_rate != 16000 && bit_rate != 24000 && bit_rate != 32000 && bit_rate != 40000 {
-		return nil, errors.New("invalid bit rate")
+		return nil, ErrInvalidBitRate
+	}
+	if !ext_coding.valid() {
+		return nil, ErrInvalidEncoding
+	}
+	if !packing.valid() {
+		return nil, ErrInvalidPacking
 	}
 
 	var i int
@@ -1174,7 +1187,41 @@ func G726_init(bit_rate, ext_coding, packing int32_t) (*g726_state_t, error) {
 		s.dq[i] = 32
 	}
 	s.td = false
-	switch bit_rate {
+	s.set_rate_funcs()
+
+	s.bs = bitstream_state_s{
+		bitstream: 0,
+		residue:   0,
+		lsb_first: s.packing != PackingLeft,
+	}
+
+	return s, nil
+}
+
+// EncodeTo encodes amp into g726Data and returns the number of bytes
+// written, like g726_encode in spandsp. It returns io.ErrShortBuffer, leaving
+// the context unchanged, if g726Data is shorter than s.EncodedLen(len(amp)).
+func (s *G726State) EncodeTo(g726Data []byte, amp []int16) (int, error) {
+	if len(g726Data) < s.EncodedLen(len(amp)) {
+		return 0, io.ErrShortBuffer
+	}
+	return len(s.AppendEncode(g726Data[:0], amp)), nil
+}
+
+// DecodeTo decodes g726Data into amp and returns the number of samples
+// written, like g726_decode in spandsp. It returns io.ErrShortBuffer, leaving
+// the context unchanged, if amp is shorter than s.DecodedLen(len(g726Data)).
+func (s *G726State) DecodeTo(amp []int16, g726Data []byte) (int, error) {
+	if len(amp) < s.DecodedLen(len(g726Data)) {
+		return 0, io.ErrShortBuffer
+	}
+	return len(s.AppendDecode(amp[:0], g726Data)), nil
+}
+
+// set_rate_funcs selects the encoder/decoder functions and the number of
+// bits per sample for the current bit rate.
+func (s *g726_state_t) set_rate_funcs() {
+	switch s.rate {
 	case 16000:
 		s.enc_func = s.g726_16_encoder
 		s.dec_func = s.g726_16_decoder
@@ -1187,44 +1234,71 @@ func G726_init(bit_rate, ext_coding, packing int32_t) (*g726_state_t, error) {
 		s.enc_func = s.g726_40_encoder
 		s.dec_func = s.g726_40_decoder
 		s.bits_per_sample = 5
-		break
 	default:
 		// 32000
 		s.enc_func = s.g726_32_encoder
 		s.dec_func = s.g726_32_decoder
 		s.bits_per_sample = 4
-		break
 	}
+}
 
-	s.bs = bitstream_state_s{
-		bitstream: 0,
-		residue:   0,
-		lsb_first: s.packing != G726_PACKING_LEFT,
-	}
+var (
+	ErrInvalidBitRate  = errors.New("invalid bit rate")
+	ErrInvalidEncoding = errors.New("invalid ext coding")
+	ErrInvalidPacking  = errors.New("invalid packing")
+)
 
-	return s, nil
+// Encoding is the coding of the samples on the PCM side of the codec.
+type Encoding int32
+
+const (
+	EncodingLinear Encoding = 0 /* Interworking with 16 bit signed linear */
+	EncodingULaw   Encoding = 1 /* Interworking with u-law */
+	EncodingALaw   Encoding = 2 /* Interworking with A-law */
+)
+
+// Deprecated: use EncodingLinear, EncodingULaw and EncodingALaw.
+const (
+	G726_ENCODING_LINEAR = EncodingLinear
+	G726_ENCODING_ULAW   = EncodingULaw
+	G726_ENCODING_ALAW   = EncodingALaw
+)
+
+func (e Encoding) valid() bool {
+	return e == EncodingLinear || e == EncodingULaw || e == EncodingALaw
 }
 
+// Packing is the packing of the code words into bytes.
+type Packing int32
+
 const (
-	G726_ENCODING_LINEAR = 0 /* Interworking with 16 bit signed linear */
-	G726_ENCODING_ULAW   = 1 /* Interworking with u-law */
-	G726_ENCODING_ALAW   = 2 /* Interworking with A-law */
+	PackingNone  Packing = 0 /* One code word per byte */
+	PackingLeft  Packing = 1 /* MSB first, as in AAL2 and ffmpeg */
+	PackingRight Packing = 2 /* LSB first, as in RFC 3551 */
 )
 
+// Deprecated: use PackingNone, PackingLeft and PackingRight.
 const (
-	G726_PACKING_NONE  = 0
-	G726_PACKING_LEFT  = 1
-	G726_PACKING_RIGHT = 2
+	G726_PACKING_NONE  = PackingNone
+	G726_PACKING_LEFT  = PackingLeft
+	G726_PACKING_RIGHT = PackingRight
 )
 
+func (p Packing) valid() bool {
+	return p == PackingNone || p == PackingLeft || p == PackingRight
+}
+
 // G.726 state
-type g726_state_t = g726_state_s
+type g726_state_t = G726State
 type bitstream_state_t = bitstream_state_s
 
 type g726_decoder_func_t func(code uint8_t) int16_t
 
 type g726_encoder_func_t func(amp int16_t) uint8_t
 
+// G726State is a G.726 encode or decode context, created by NewG726State.
+// It holds no resources, so there is no counterpart to g726_release.
+//
 /*!
  * The following is the definition of the state structure
  * used by the G.726 encoder and decoder to preserve their internal
@@ -1234,15 +1308,15 @@ type g726_encoder_func_t func(amp int16_t) uint8_t
  * to variable names in the bit level description of the coding algorithm
  * included in this recommendation.
  */
-type g726_state_s struct {
+type G726State struct {
 	/*! The bit rate */
 	rate int32_t
 	/*! The external coding, for tandem operation */
-	ext_coding int32_t
+	ext_coding Encoding
 	/*! The number of bits per sample */
 	bits_per_sample int32_t
 	/*! One of the G.726_PACKING_xxx options */
-	packing int32_t
+	packing Packing
 
 	/*! Locked or steady state step size multiplier. */
 	yl int_t // int32_t
@@ -1299,11 +1373,11 @@ type (
 	int_t    = int64
 )
 
-type IntValue interface {
+type int_value interface {
 	~int | ~int8 | ~int16 | ~int32 | ~int64 |
 		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
 }
 
-func overflow[T IntValue](v T) int_t {
+func overflow[T int_value](v T) int_t {
 	return int_t(v)
 }
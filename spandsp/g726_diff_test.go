@@ -0,0 +1,383 @@
+package spandsp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+/* Differential tests between the root package and this port. Both are fed
+   the same linear input and must produce the same codes, the same decoded
+   samples and the same ITU state after every sample. When they disagree the
+   inputs are replayed one sample at a time to report the first divergence
+   together with both states. */
+
+var diff_rates = []int{16000, 24000, 32000, 40000}
+
+var diff_packings = []Packing{PackingNone, PackingLeft, PackingRight}
+
+// itu_fields are the ITU state variables shared by both implementations. The
+// root package keeps them unexported, so they are read by reflection.
+var itu_fields = []string{"yl", "yu", "dms", "dml", "ap", "a", "b", "pk", "dq", "sr", "td"}
+
+func itu_value(f reflect.Value) int64 {
+	if f.Kind() == reflect.Bool {
+		if f.Bool() {
+			return 1
+		}
+		return 0
+	}
+	return f.Int()
+}
+
+// itu_state returns the ITU state variables of a *g726.G726_state or a
+// *G726State in a common form.
+func itu_state(state any) []int64 {
+	v := reflect.ValueOf(state).Elem()
+	var out []int64
+	for _, name := range itu_fields {
+		f := v.FieldByName(name)
+		if f.Kind() == reflect.Array {
+			for i := 0; i < f.Len(); i++ {
+				out = append(out, itu_value(f.Index(i)))
+			}
+		} else {
+			out = append(out, itu_value(f))
+		}
+	}
+	return out
+}
+
+// dump_state formats the ITU state variables of either implementation.
+func dump_state(state any) string {
+	v := reflect.ValueOf(state).Elem()
+	var sb strings.Builder
+	for i, name := range itu_fields {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		f := v.FieldByName(name)
+		if f.Kind() == reflect.Array {
+			vals := make([]int64, f.Len())
+			for j := range vals {
+				vals[j] = itu_value(f.Index(j))
+			}
+			fmt.Fprintf(&sb, "%s=%v", name, vals)
+		} else {
+			fmt.Fprintf(&sb, "%s=%d", name, itu_value(f))
+		}
+	}
+	return sb.String()
+}
+
+// same_output reports whether two decoded samples agree. The root 32kbps
+// decoder saturates the reconstructed signal where the reference lets
+// int16_t(sr << 2) wrap, so a saturated root sample matches any value. The
+// states are compared separately, which still catches a real divergence.
+func same_output(bit_rate int, root, port int16_t) bool {
+	if root == port {
+		return true
+	}
+	return bit_rate == 32000 && (root == math.MaxInt16 || root == math.MinInt16)
+}
+
+// diff_pair is a root and a spandsp state configured identically.
+type diff_pair struct {
+	bit_rate int
+	packing  Packing
+	root     *g726.G726_state
+	port     *G726State
+}
+
+func new_diff_pair(bit_rate int, packing Packing) *diff_pair {
+	root, err := g726.New(g726.Rate(bit_rate/8000-2), g726.PackingType(packing))
+	if err != nil {
+		panic(err)
+	}
+	port, err := NewG726State(bit_rate, EncodingLinear, packing)
+	if err != nil {
+		panic(err)
+	}
+	return &diff_pair{bit_rate: bit_rate, packing: packing, root: root, port: port}
+}
+
+func (p *diff_pair) name() string {
+	return fmt.Sprintf("%dbps packing %d", p.bit_rate, p.packing)
+}
+
+func (p *diff_pair) same_state() bool {
+	return reflect.DeepEqual(itu_state(p.root), itu_state(p.port))
+}
+
+// sr_overflow reports whether the states differ only because the
+// reconstructed signal went beyond 32767, and if so copies the root value
+// into the port. The root quan_pow2() caps the exponent at 15 as the Sun code
+// does, while top_bit() gives 16. This needs se + dq to overflow, which only
+// random code streams at 40kbps seem to reach.
+func (p *diff_pair) sr_overflow() bool {
+	if p.port.sr[0]>>6 != 16 {
+		return false
+	}
+	sr := p.port.sr[0]
+	p.port.sr[0] = int_t(reflect.ValueOf(p.root).Elem().FieldByName("sr").Index(0).Int())
+	if p.same_state() {
+		return true
+	}
+	p.port.sr[0] = sr
+	return false
+}
+
+// divergence describes the first sample where the implementations disagree.
+type divergence struct {
+	pair       string
+	op         string
+	index      int
+	input      int
+	root, port int
+	before     [2]string
+	after      [2]string
+}
+
+func (d *divergence) Error() string {
+	return fmt.Sprintf("%s: %s diverges at sample %d (input %d): root %d, spandsp %d\n"+
+		"  before: root    %s\n"+
+		"          spandsp %s\n"+
+		"  after:  root    %s\n"+
+		"          spandsp %s",
+		d.pair, d.op, d.index, d.input, d.root, d.port, d.before[0], d.before[1], d.after[0], d.after[1])
+}
+
+// diff_encode encodes amp with both implementations and returns nil if the
+// bit streams and final states agree.
+func diff_encode(bit_rate int, packing Packing, amp []int16_t) error {
+	p := new_diff_pair(bit_rate, packing)
+	want := p.root.EncodeV2(amp)
+	got := p.port.Encode(amp)
+	if string(got) == string(want) && p.same_state() {
+		return nil
+	}
+
+	/* Replay unpacked, one sample at a time */
+	s := new_diff_pair(bit_rate, PackingNone)
+	resynced := false
+	for i := range amp {
+		d := &divergence{pair: p.name(), op: "encode", index: i, input: int(amp[i])}
+		d.before = [2]string{dump_state(s.root), dump_state(s.port)}
+		d.root = int(s.root.EncodeV2(amp[i : i+1])[0])
+		d.port = int(s.port.Encode(amp[i : i+1])[0])
+		if d.root == d.port && !s.same_state() && s.sr_overflow() {
+			resynced = true
+		} else if d.root != d.port || !s.same_state() {
+			d.after = [2]string{dump_state(s.root), dump_state(s.port)}
+			return d
+		}
+	}
+	if resynced {
+		return nil
+	}
+
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			return fmt.Errorf("%s: codes agree but the packed streams differ at byte %d", p.name(), i)
+		}
+	}
+	return fmt.Errorf("%s: codes agree but spandsp produced %d bytes, root %d", p.name(), len(got), len(want))
+}
+
+// diff_decode decodes g726_data with both implementations and returns nil if
+// the samples and final states agree.
+func diff_decode(bit_rate int, packing Packing, g726_data []uint8_t) error {
+	p := new_diff_pair(bit_rate, packing)
+
+	/* Record the codes the port unpacks, for the replay below */
+	var codes []uint8_t
+	dec := p.port.dec_func
+	p.port.dec_func = func(code uint8_t) int16_t {
+		codes = append(codes, code)
+		return dec(code)
+	}
+
+	want := p.root.DecodeV2(g726_data)
+	got := p.port.Decode(g726_data)
+	same := len(got) == len(want) && p.same_state()
+	for i := 0; same && i < len(want); i++ {
+		same = same_output(bit_rate, want[i], got[i])
+	}
+	if same {
+		return nil
+	}
+
+	s := new_diff_pair(bit_rate, PackingNone)
+	resynced := false
+	for i, code := range codes {
+		d := &divergence{pair: p.name(), op: "decode", index: i, input: int(code)}
+		d.before = [2]string{dump_state(s.root), dump_state(s.port)}
+		root := s.root.DecodeV2(codes[i : i+1])[0]
+		port := s.port.Decode(codes[i : i+1])[0]
+		d.root, d.port = int(root), int(port)
+		if same_output(bit_rate, root, port) && !s.same_state() && s.sr_overflow() {
+			resynced = true
+		} else if !same_output(bit_rate, root, port) || !s.same_state() {
+			d.after = [2]string{dump_state(s.root), dump_state(s.port)}
+			return d
+		}
+	}
+	if resynced {
+		return nil
+	}
+	return fmt.Errorf("%s: samples agree but spandsp decoded %d samples, root %d", p.name(), len(got), len(want))
+}
+
+// diff_audio returns the first 10 seconds of the example recording, or nil if
+// it is not available.
+func diff_audio(tb testing.TB) []int16_t {
+	data, err := os.ReadFile("../example/audio-samples.pcm")
+	if err != nil {
+		tb.Logf("example audio not available: %v", err)
+		return nil
+	}
+	if len(data) > 160000 {
+		data = data[:160000]
+	}
+	amp := make([]int16_t, len(data)/2)
+	for i := range amp {
+		amp[i] = int16_t(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return amp
+}
+
+// diff_signals returns the inputs of the differential test: real speech,
+// full scale noise, a clipped square wave and a slow sweep.
+func diff_signals(tb testing.TB) map[string][]int16_t {
+	rnd := rand.New(rand.NewSource(2023))
+	noise := make([]int16_t, 16000)
+	for i := range noise {
+		noise[i] = int16_t(rnd.Intn(65536) - 32768)
+	}
+	square := make([]int16_t, 8000)
+	for i := range square {
+		square[i] = math.MaxInt16
+		if (i/23)%2 != 0 {
+			square[i] = math.MinInt16
+		}
+	}
+	sweep := make([]int16_t, 16000)
+	for i := range sweep {
+		t := float64(i) / 8000
+		sweep[i] = int16_t(20000 * math.Sin(2*math.Pi*(100+900*t)*t))
+	}
+
+	signals := map[string][]int16_t{"noise": noise, "square": square, "sweep": sweep, "speech-like": test_signal(8000)}
+	if audio := diff_audio(tb); audio != nil {
+		signals["audio"] = audio
+	}
+	return signals
+}
+
+func Test_g726_differential_encode(t *testing.T) {
+	for name, amp := range diff_signals(t) {
+		for _, bit_rate := range diff_rates {
+			for _, packing := range diff_packings {
+				if err := diff_encode(bit_rate, packing, amp); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+func Test_g726_differential_decode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(726))
+	random := make([]uint8_t, 20000)
+	rnd.Read(random)
+
+	for _, bit_rate := range diff_rates {
+		for _, packing := range diff_packings {
+			if err := diff_decode(bit_rate, packing, random); err != nil {
+				t.Error(err)
+			}
+
+			/* Decode what the encoder produced from real audio */
+			for name, amp := range diff_signals(t) {
+				enc, _ := NewG726State(bit_rate, EncodingLinear, packing)
+				if err := diff_decode(bit_rate, packing, enc.Encode(amp)); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+func Test_g726_differential_report(t *testing.T) {
+	/* A corrupted state must be reported at the first affected sample */
+	amp := test_signal(100)
+	p := new_diff_pair(32000, PackingNone)
+	p.root.EncodeV2(amp[:50])
+	p.port.Encode(amp[:50])
+	if !p.same_state() {
+		t.Fatal("states differ before corruption")
+	}
+	p.port.yu += 200
+	if p.same_state() {
+		t.Fatal("corrupted state not detected")
+	}
+
+	d := &divergence{pair: p.name(), op: "encode", index: 50}
+	d.before = [2]string{dump_state(p.root), dump_state(p.port)}
+	if !strings.Contains(d.Error(), "yu=") || d.before[0] == d.before[1] {
+		t.Fatal(d.Error())
+	}
+
+	if !same_output(32000, math.MinInt16, -300) || same_output(24000, math.MaxInt16, -300) || same_output(32000, 100, -300) {
+		t.Fatal("same_output")
+	}
+}
+
+// diff_fuzz_params maps a fuzz byte to a rate and packing.
+func diff_fuzz_params(mode uint8) (int, Packing) {
+	return diff_rates[mode%4], diff_packings[(mode/4)%3]
+}
+
+func Fuzz_g726_encode(f *testing.F) {
+	f.Add(uint8(0), []byte{0, 0, 0xFF, 0x7F, 0, 0x80})
+	f.Add(uint8(5), []byte("differential"))
+	if audio := diff_audio(f); audio != nil {
+		seed := make([]byte, 0, 800)
+		for _, v := range audio[4000:4400] {
+			seed = binary.LittleEndian.AppendUint16(seed, uint16(v))
+		}
+		for mode := uint8(0); mode < 12; mode++ {
+			f.Add(mode, seed)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, mode uint8, data []byte) {
+		amp := make([]int16_t, len(data)/2)
+		for i := range amp {
+			amp[i] = int16_t(binary.LittleEndian.Uint16(data[2*i:]))
+		}
+		bit_rate, packing := diff_fuzz_params(mode)
+		if err := diff_encode(bit_rate, packing, amp); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func Fuzz_g726_decode(f *testing.F) {
+	f.Add(uint8(2), []byte{0x00, 0xFF, 0x0F, 0xF0})
+	f.Add(uint8(11), []byte("differential"))
+
+	f.Fuzz(func(t *testing.T, mode uint8, data []byte) {
+		bit_rate, packing := diff_fuzz_params(mode)
+		if err := diff_decode(bit_rate, packing, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package spandsp
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+// test_signal returns a signal that stays clear of the 14 bit overload
+// region, where the two packages clip the linear output differently.
+func test_signal(n int) []int16_t {
+	rnd := rand.New(rand.NewSource(726))
+	amp := make([]int16_t, n)
+	for i := range amp {
+		v := 6000*math.Sin(2*math.Pi*440*float64(i)/8000) + 2000*math.Sin(2*math.Pi*1270*float64(i)/8000)
+		amp[i] = int16_t(v) + int16_t(rnd.Intn(801)-400)
+	}
+	return amp
+}
+
+func Test_g726_against_root(t *testing.T) {
+	linear := test_signal(4000)
+
+	for _, bit_rate := range []int{16000, 24000, 32000, 40000} {
+		for _, packing := range []Packing{PackingNone, PackingLeft, PackingRight} {
+			for _, ext_coding := range []Encoding{EncodingLinear, EncodingULaw, EncodingALaw} {
+				amp := linear
+				if ext_coding != EncodingLinear {
+					amp = make([]int16_t, len(linear))
+					for i, v := range linear {
+						if ext_coding == EncodingALaw {
+							amp[i] = int16_t(linear_to_alaw(v))
+						} else {
+							amp[i] = int16_t(linear_to_ulaw(int32_t(v)))
+						}
+					}
+				}
+
+				new_root := func() *g726.G726_state {
+					r, err := g726.New(g726.Rate(bit_rate/8000-2), g726.PackingType(packing), g726.WithExtCoding(g726.ExtCoding(ext_coding)))
+					if err != nil {
+						t.Fatal(err)
+					}
+					return r
+				}
+				new_state := func() *G726State {
+					s, err := NewG726State(bit_rate, ext_coding, packing)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return s
+				}
+
+				want := new_root().EncodeV2(amp)
+				got := new_state().Encode(amp)
+				if string(got) != string(want) {
+					t.Fatalf("%d packing %d coding %d: encoded data differs", bit_rate, packing, ext_coding)
+				}
+
+				want_amp := new_root().DecodeV2(want)
+				got_amp := new_state().Decode(want)
+				if len(got_amp) != len(want_amp) {
+					t.Fatalf("%d packing %d coding %d: decoded %d samples, want %d", bit_rate, packing, ext_coding, len(got_amp), len(want_amp))
+				}
+				for i := range want_amp {
+					if got_amp[i] != want_amp[i] {
+						t.Fatalf("%d packing %d coding %d: sample %d is %d, want %d", bit_rate, packing, ext_coding, i, got_amp[i], want_amp[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func Test_g726_encode_decode(t *testing.T) {
+	if _, err := NewG726State(8000, EncodingLinear, PackingNone); !errors.Is(err, ErrInvalidBitRate) {
+		t.Fatalf("got %v, want ErrInvalidBitRate", err)
+	}
+	if _, err := NewG726State(32000, Encoding(3), PackingNone); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("got %v, want ErrInvalidEncoding", err)
+	}
+	if _, err := NewG726State(32000, EncodingLinear, Packing(3)); !errors.Is(err, ErrInvalidPacking) {
+		t.Fatalf("got %v, want ErrInvalidPacking", err)
+	}
+
+	amp := test_signal(161)
+	enc, _ := NewG726State(24000, EncodingLinear, PackingRight)
+	dec, _ := NewG726State(24000, EncodingLinear, PackingRight)
+	want := enc.Clone().Encode(amp)
+
+	g726_data := make([]uint8_t, 100)
+	if _, err := enc.EncodeTo(g726_data[:len(want)-1], amp); err != io.ErrShortBuffer {
+		t.Fatalf("got %v, want io.ErrShortBuffer", err)
+	}
+	n, err := enc.EncodeTo(g726_data, amp)
+	if err != nil || string(g726_data[:n]) != string(want) {
+		t.Fatalf("EncodeTo() = %d, %v", n, err)
+	}
+
+	out := make([]int16_t, 200)
+	if _, err = dec.DecodeTo(out[:10], g726_data[:n]); err != io.ErrShortBuffer {
+		t.Fatalf("got %v, want io.ErrShortBuffer", err)
+	}
+	want_samples := dec.DecodedLen(n)
+	if n, err = dec.DecodeTo(out, g726_data[:n]); err != nil || n != want_samples {
+		t.Fatalf("DecodeTo() = %d, %v", n, err)
+	}
+}
+
+// The deprecated spandsp-style names still build and behave like the new API.
+func Test_g726_deprecated_api(t *testing.T) {
+	amp := test_signal(100)
+	old, err := G726_init(16000, G726_ENCODING_LINEAR, G726_PACKING_LEFT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewG726State(16000, EncodingLinear, PackingLeft)
+	if string(old.Encode(amp)) != string(s.Encode(amp)) {
+		t.Fatal("G726_init state encodes differently")
+	}
+	if _, err := G726_init(8000, G726_ENCODING_ULAW, G726_PACKING_RIGHT); !errors.Is(err, ErrInvalidBitRate) {
+		t.Fatalf("got %v, want ErrInvalidBitRate", err)
+	}
+}
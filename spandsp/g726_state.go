@@ -0,0 +1,163 @@
+package spandsp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+/* Version of the serialized state format. */
+const g726_state_version = 1
+
+/* Length of a version 1 serialized state. */
+const g726_state_size_v1 = 1 + 3*4 + (5+2+6+2+6+2)*8 + 1 + 4 + 4 + 1 + 8
+
+// MarshalBinary implements encoding.BinaryMarshaler. The snapshot covers
+// the configuration, the ITU state variables and the bit stream residue.
+func (s *G726State) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, g726_state_size_v1)
+
+	b = append(b, g726_state_version)
+	b = binary.BigEndian.AppendUint32(b, uint32(s.rate))
+	b = binary.BigEndian.AppendUint32(b, uint32(s.ext_coding))
+	b = binary.BigEndian.AppendUint32(b, uint32(s.packing))
+
+	put := func(v int_t) {
+		b = binary.BigEndian.AppendUint64(b, uint64(v))
+	}
+	put(s.yl)
+	put(s.yu)
+	put(s.dms)
+	put(s.dml)
+	put(s.ap)
+	for _, v := range s.a {
+		put(v)
+	}
+	for _, v := range s.b {
+		put(v)
+	}
+	for _, v := range s.pk {
+		put(v)
+	}
+	for _, v := range s.dq {
+		put(v)
+	}
+	for _, v := range s.sr {
+		put(v)
+	}
+	b = append(b, bool_to_byte(s.td))
+
+	b = binary.BigEndian.AppendUint32(b, s.bs.bitstream)
+	b = binary.BigEndian.AppendUint32(b, uint32(s.bs.residue))
+	b = append(b, bool_to_byte(s.bs.lsb_first))
+
+	b = binary.BigEndian.AppendUint64(b, s.packets)
+
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, restoring a state
+// written by MarshalBinary.
+func (s *G726State) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty state snapshot")
+	}
+	if data[0] != g726_state_version {
+		return errors.New("unsupported state snapshot version")
+	}
+	if len(data) != g726_state_size_v1 {
+		return errors.New("invalid state snapshot length")
+	}
+
+	var t g726_state_t
+	p := data[1:]
+	t.rate = int32_t(binary.BigEndian.Uint32(p))
+	t.ext_coding = Encoding(binary.BigEndian.Uint32(p[4:]))
+	t.packing = Packing(binary.BigEndian.Uint32(p[8:]))
+	p = p[12:]
+
+	if t.rate != 16000 && t.rate != 24000 && t.rate != 32000 && t.rate != 40000 {
+		return errors.New("invalid bit rate in state snapshot")
+	}
+	if !t.ext_coding.valid() || !t.packing.valid() {
+		return errors.New("invalid coding or packing in state snapshot")
+	}
+
+	get := func() int_t {
+		v := int_t(binary.BigEndian.Uint64(p))
+		p = p[8:]
+		return v
+	}
+	t.yl = get()
+	t.yu = get()
+	t.dms = get()
+	t.dml = get()
+	t.ap = get()
+	for i := range t.a {
+		t.a[i] = get()
+	}
+	for i := range t.b {
+		t.b[i] = get()
+	}
+	for i := range t.pk {
+		t.pk[i] = get()
+	}
+	for i := range t.dq {
+		t.dq[i] = get()
+	}
+	for i := range t.sr {
+		t.sr[i] = get()
+	}
+	if p[0] > 1 {
+		return errors.New("invalid tone detect flag in state snapshot")
+	}
+	t.td = p[0] != 0
+	p = p[1:]
+
+	t.bs.bitstream = binary.BigEndian.Uint32(p)
+	t.bs.residue = int32_t(binary.BigEndian.Uint32(p[4:]))
+	t.bs.lsb_first = p[8] != 0
+	p = p[9:]
+
+	/* Fewer than 8 bits are left over after each call; a larger residue
+	   would make the unpacking shift count negative. */
+	if t.bs.residue < 0 || t.bs.residue > 8 {
+		return errors.New("invalid bit residue in state snapshot")
+	}
+	if t.bs.lsb_first != (t.packing != PackingLeft) {
+		return errors.New("invalid bit order in state snapshot")
+	}
+
+	t.packets = binary.BigEndian.Uint64(p)
+
+	*s = t
+	/* The function values must be bound to s, not to the temporary. */
+	s.set_rate_funcs()
+	return nil
+}
+
+// Clone returns an independent copy of the state.
+func (s *G726State) Clone() *G726State {
+	c := *s
+	c.set_rate_funcs()
+	return &c
+}
+
+// Equal reports whether two states are identical, including the bit stream
+// residue.
+func (s *G726State) Equal(o *G726State) bool {
+	if s == nil || o == nil {
+		return s == o
+	}
+
+	return s.rate == o.rate && s.ext_coding == o.ext_coding && s.bits_per_sample == o.bits_per_sample && s.packing == o.packing &&
+		s.yl == o.yl && s.yu == o.yu && s.dms == o.dms && s.dml == o.dml && s.ap == o.ap &&
+		s.a == o.a && s.b == o.b && s.pk == o.pk && s.dq == o.dq && s.sr == o.sr && s.td == o.td &&
+		s.bs == o.bs && s.packets == o.packets
+}
+
+func bool_to_byte(b bool) uint8_t {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -1,6 +1,7 @@
 package spandsp
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math/bits"
 	"testing"
@@ -12,8 +13,8 @@ func Test_g726_init(t *testing.T) {
 		pcm_in[i] = -0x7800
 	}
 
-	s_e, _ := G726_init(32000, G726_ENCODING_LINEAR, G726_PACKING_LEFT)
-	s_d, _ := G726_init(32000, G726_ENCODING_LINEAR, G726_PACKING_LEFT)
+	s_e, _ := NewG726State(32000, EncodingLinear, PackingLeft)
+	s_d, _ := NewG726State(32000, EncodingLinear, PackingLeft)
 
 	bitstream := s_e.Encode(pcm_in)
 	pcm_out := s_d.Decode(bitstream)
@@ -70,3 +71,71 @@ func encodeAlawFrame(frame int16) uint8 {
 	}
 	return uint8((sign | compressedByte) ^ 0x0055)
 }
+
+func Test_g726_state_snapshot(t *testing.T) {
+	var pcm_in = make([]int16, 1001)
+	for i := 0; i < len(pcm_in); i++ {
+		pcm_in[i] = int16((i * 1103) % 20000)
+	}
+
+	for _, rate := range []int{16000, 24000, 32000, 40000} {
+		s, _ := NewG726State(rate, EncodingLinear, PackingRight)
+		s.Encode(pcm_in[:333])
+
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var restored G726State
+		if err = restored.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		c := s.Clone()
+		if !restored.Equal(s) || !c.Equal(s) {
+			t.Fatalf("%d: restored state differs", rate)
+		}
+
+		want := string(s.Encode(pcm_in[333:]))
+		if got := string(restored.Encode(pcm_in[333:])); got != want {
+			t.Fatalf("%d: restored state encodes differently", rate)
+		}
+		if got := string(c.Encode(pcm_in[333:])); got != want {
+			t.Fatalf("%d: cloned state encodes differently", rate)
+		}
+		if !restored.Equal(s) || !c.Equal(s) {
+			t.Fatalf("%d: states diverged", rate)
+		}
+	}
+}
+
+func Test_g726_state_snapshot_corrupt(t *testing.T) {
+	s, _ := NewG726State(24000, EncodingLinear, PackingLeft)
+	s.Encode(make([]int16, 7))
+	data, _ := s.MarshalBinary()
+
+	var restored G726State
+	for _, residue := range []int32{-1, 9, 1 << 20} {
+		corrupt := append([]byte{}, data...)
+		binary.BigEndian.PutUint32(corrupt[len(corrupt)-13:], uint32(residue))
+		if err := restored.UnmarshalBinary(corrupt); err == nil {
+			t.Fatalf("residue %d accepted", residue)
+		}
+	}
+
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)-18] = 2
+	if err := restored.UnmarshalBinary(corrupt); err == nil {
+		t.Fatal("tone detect flag 2 accepted")
+	}
+
+	corrupt = append([]byte{}, data...)
+	corrupt[len(corrupt)-9] = 1
+	if err := restored.UnmarshalBinary(corrupt); err == nil {
+		t.Fatal("LSB first bit order accepted for left packing")
+	}
+
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	restored.Decode([]byte{0x5a, 0xa5})
+}
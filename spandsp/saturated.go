@@ -0,0 +1,14 @@
+package spandsp
+
+// saturate16 Saturate to 16 bits
+// param amp The value to be saturated
+// return The saturated value.
+func saturate16(amp int32_t) int16_t {
+	if amp > 32767 {
+		return 32767
+	}
+	if amp < -32768 {
+		return -32768
+	}
+	return int16_t(amp)
+}
@@ -0,0 +1,202 @@
+package g726
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// 流式处理时每次处理的采样点数
+const streamChunkSamples = 4096
+
+// Writer 把写入的16位小端PCM数据编码为G726码流后写入底层 io.Writer
+// 写入的数据长度不需要对齐, 不完整的采样点和不足一个字节的比特会保留到下次写入
+type Writer struct {
+	w     io.Writer
+	state *G726_state
+
+	odd     byte // 上次写入剩余的半个采样点
+	has_odd bool
+	pcm     []int16
+	out     []byte
+	err     error
+	closed  bool
+}
+
+// NewWriter 创建一个流式编码器, rate 或 packing 无效时返回错误
+func NewWriter(w io.Writer, rate Rate, packing PackingType, opts ...Option) (*Writer, error) {
+	state, err := New(rate, packing, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:     w,
+		state: state,
+		pcm:   make([]int16, 0, streamChunkSamples),
+	}, nil
+}
+
+// Write 写入16位小端PCM数据
+// 底层写入失败时返回的长度不包括编码结果没有写出的那部分数据
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		done := n - len(p)
+		w.pcm = w.pcm[:0]
+		if w.has_odd {
+			w.pcm = append(w.pcm, int16(uint16(w.odd)|uint16(p[0])<<8))
+			w.has_odd = false
+			p = p[1:]
+		}
+		for len(p) >= 2 && len(w.pcm) < cap(w.pcm) {
+			w.pcm = append(w.pcm, int16(binary.LittleEndian.Uint16(p)))
+			p = p[2:]
+		}
+		if len(p) == 1 {
+			w.odd = p[0]
+			w.has_odd = true
+			p = p[1:]
+		}
+
+		w.out = w.state.AppendEncode(w.out[:0], w.pcm)
+		if err := w.write(w.out); err != nil {
+			return done, err
+		}
+	}
+
+	return n, nil
+}
+
+// WriteSamples 写入PCM采样点
+func (w *Writer) WriteSamples(pcm []int16) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if w.has_odd {
+		return fmt.Errorf("%w: pcm length must be even", ErrInputLength)
+	}
+
+	for len(pcm) > 0 {
+		n := len(pcm)
+		if n > streamChunkSamples {
+			n = streamChunkSamples
+		}
+		w.out = w.state.AppendEncode(w.out[:0], pcm[:n])
+		if err := w.write(w.out); err != nil {
+			return err
+		}
+		pcm = pcm[n:]
+	}
+	return nil
+}
+
+// Close 输出缓存中剩余的比特(不足一个字节时补零)
+// 不会关闭底层的 io.Writer
+func (w *Writer) Close() error {
+	return w.CloseContext(context.Background())
+}
+
+// CloseContext 与 Close 相同, ctx 在写出剩余数据之前已经被取消时不再写出
+// 剩余数据只有几个字节, 只调用一次底层的 Write, 不会在写入的过程中检查 ctx
+func (w *Writer) CloseContext(ctx context.Context) error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+
+	if w.err != nil {
+		return w.err
+	}
+	if err := ctx.Err(); err != nil {
+		w.err = err
+		return err
+	}
+	if w.has_odd {
+		w.err = fmt.Errorf("%w: pcm length must be even", ErrInputLength)
+		return w.err
+	}
+
+	w.out = w.state.AppendFlush(w.out[:0])
+	return w.write(w.out)
+}
+
+// Samples 返回已经编码的采样点数
+// 与 Reader.SetSampleLimit 配合使用, 可以让解码后的数据保持原始长度
+func (w *Writer) Samples() int64 {
+	return w.state.Samples()
+}
+
+func (w *Writer) write(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := w.w.Write(data); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Reader 从底层 io.Reader 读取G726码流, 解码后输出16位小端PCM数据
+type Reader struct {
+	r     io.Reader
+	state *G726_state
+
+	in  []byte
+	pcm []int16
+	buf []byte
+	out []byte // buf 中尚未被读取的部分
+	err error
+}
+
+// NewReader 创建一个流式解码器, rate 或 packing 无效时返回错误
+func NewReader(r io.Reader, rate Rate, packing PackingType, opts ...Option) (*Reader, error) {
+	state, err := New(rate, packing, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:     r,
+		state: state,
+		in:    make([]byte, streamChunkSamples),
+	}, nil
+}
+
+// SetSampleLimit 设置解码的采样点总数, 参见 G726_state.SetSampleLimit
+func (r *Reader) SetSampleLimit(n int64) {
+	r.state.SetSampleLimit(n)
+}
+
+// Read 读取解码后的16位小端PCM数据
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.r.Read(r.in)
+		if n > 0 {
+			r.pcm = r.state.AppendDecode(r.pcm[:0], r.in[:n])
+			r.buf = AppendPcm16ToPcm8(r.buf[:0], r.pcm)
+			r.out = r.buf
+		}
+		if err != nil {
+			r.err = err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
@@ -0,0 +1,147 @@
+package g726
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func testSignal(n int) []int16 {
+	pcm := make([]int16, n)
+	for i := 0; i < n; i++ {
+		v := 8000*math.Sin(2*math.Pi*440*float64(i)/8000) + 3000*math.Sin(2*math.Pi*1270*float64(i)/8000)
+		pcm[i] = int16(v)
+	}
+	return pcm
+}
+
+func TestWriterReader(t *testing.T) {
+	pcm := testSignal(8003)
+	pcm8 := (&G726_state{}).Pcm16ToPcm8(pcm)
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, packing := range []PackingType{PackingNone, PackingLeft, PackingRight} {
+		for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+			ref := G726_init_state(rate, packing)
+			want := append(ref.EncodeV2(pcm), ref.Flush()...)
+
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, rate, packing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for p := pcm8; len(p) > 0; {
+				n := rnd.Intn(37) + 1
+				if n > len(p) {
+					n = len(p)
+				}
+				if _, err := w.Write(p[:n]); err != nil {
+					t.Fatal(err)
+				}
+				p = p[n:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatalf("%v packing %v: writer output differs from EncodeV2", rate, packing)
+			}
+
+			dec := G726_init_state(rate, packing)
+			wantPCM := dec.Pcm16ToPcm8(dec.DecodeV2(want))
+
+			r, err := NewReader(&oneByteReader{r: bytes.NewReader(want)}, rate, packing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			p := make([]byte, 7)
+			for {
+				n, err := r.Read(p)
+				got = append(got, p[:n]...)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got, wantPCM) {
+				t.Fatalf("%v packing %v: reader output differs from DecodeV2", rate, packing)
+			}
+		}
+	}
+}
+
+func TestWriterCloseContext(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, Rate24kbps, PackingLeft)
+	_ = w.WriteSamples(testSignal(5))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.CloseContext(ctx); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	w, _ = NewWriter(&buf, Rate16kbps, PackingRight)
+	_, _ = w.Write([]byte{1, 2, 3})
+	if err := w.Close(); err == nil {
+		t.Fatal("expected error for incomplete sample")
+	}
+	if _, err := w.Write([]byte{1, 2}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+	if err := w.WriteSamples([]int16{1}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter(io.Discard, Rate(7), PackingLeft); !errors.Is(err, ErrInvalidRate) {
+		t.Fatalf("got %v, want ErrInvalidRate", err)
+	}
+	if _, err := NewReader(bytes.NewReader(nil), Rate32kbps, PackingType(9)); !errors.Is(err, ErrInvalidPacking) {
+		t.Fatalf("got %v, want ErrInvalidPacking", err)
+	}
+
+	// 第二块的编码结果写入失败, 只有第一块算作已经写入
+	pcm8 := (&G726_state{}).Pcm16ToPcm8(testSignal(2*streamChunkSamples + 10))
+	w, _ := NewWriter(&failingWriter{n: 1}, Rate32kbps, PackingLeft)
+	n, err := w.Write(pcm8)
+	if err != errWriteFailed || n != 2*streamChunkSamples {
+		t.Fatalf("Write() = %d, %v, want %d, %v", n, err, 2*streamChunkSamples, errWriteFailed)
+	}
+	if n, err := w.Write(pcm8); n != 0 || err != errWriteFailed {
+		t.Fatalf("Write() after failure = %d, %v", n, err)
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+// failingWriter 在 n 次成功的写入之后返回错误
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errWriteFailed
+	}
+	w.n--
+	return len(p), nil
+}
+
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.r.Read(p)
+}
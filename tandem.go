@@ -0,0 +1,101 @@
+package g726
+
+import "github.com/general252/g726/g711"
+
+// G.726 与 G.711 互通时的同步编码调整
+
+// saturate16 把 v 限制在16位有符号整数范围内
+func saturate16(v int) int16 {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
+
+// tandem_adjust_alaw 同步编码调整(G.726 4.2.7)
+// 如果 A律输出再次编码得到的码字与 i 不同, 就把输出调整到相邻的 A律码, 使多次转码不累积失真
+func tandem_adjust_alaw(sr, se, y, i, sign int, quant func(d, y int) int) int {
+	if sr <= -32768 {
+		sr = -1
+	}
+	sp := int(g711.EncodeALaw(saturate16((sr >> 1) << 3))) /* short to A-law compression */
+	dx := (int(g711.DecodeALaw(uint8(sp))) >> 2) - se      /* 16-bit prediction error */
+	id := quant(dx, y)
+
+	if id == i { /* no adjustment on sp */
+		return sp
+	}
+
+	/* ADPCM codes : 8, 9, ... F, 0, 1, ... , 6, 7 */
+	im := i ^ sign /* 2's complement to biased unsigned */
+	imx := id ^ sign
+	if imx > im { /* sp adjusted to next lower value */
+		if sp&0x80 != 0 {
+			if sp == 0xD5 {
+				return 0x55
+			}
+			return ((sp ^ 0x55) - 1) ^ 0x55
+		}
+		if sp == 0x2A {
+			return 0x2A
+		}
+		return ((sp ^ 0x55) + 1) ^ 0x55
+	}
+
+	/* sp adjusted to next higher value */
+	if sp&0x80 != 0 {
+		if sp == 0xAA {
+			return 0xAA
+		}
+		return ((sp ^ 0x55) + 1) ^ 0x55
+	}
+	if sp == 0x55 {
+		return 0xD5
+	}
+	return ((sp ^ 0x55) - 1) ^ 0x55
+}
+
+// tandem_adjust_ulaw 同步编码调整(G.726 4.2.7), μ律版本
+func tandem_adjust_ulaw(sr, se, y, i, sign int, quant func(d, y int) int) int {
+	if sr <= -32768 {
+		sr = 0
+	}
+	sp := int(g711.EncodeULaw(saturate16(sr << 2)))   /* short to u-law compression */
+	dx := (int(g711.DecodeULaw(uint8(sp))) >> 2) - se /* 16-bit prediction error */
+	id := quant(dx, y)
+
+	if id == i {
+		return sp
+	}
+
+	/* ADPCM codes : 8, 9, ... F, 0, 1, ... , 6, 7 */
+	im := i ^ sign /* 2's complement to biased unsigned */
+	imx := id ^ sign
+	if imx > im { /* sp adjusted to next lower value */
+		if sp&0x80 != 0 {
+			if sp == 0xFF {
+				return 0x7E
+			}
+			return sp + 1
+		}
+		if sp == 0x00 {
+			return 0x00
+		}
+		return sp - 1
+	}
+
+	/* sp adjusted to next higher value */
+	if sp&0x80 != 0 {
+		if sp == 0x80 {
+			return 0x80
+		}
+		return sp - 1
+	}
+	if sp == 0x7F {
+		return 0xFE
+	}
+	return sp + 1
+}
@@ -0,0 +1,83 @@
+package g726
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/general252/g726/g711"
+)
+
+func TestExtCodingTandem(t *testing.T) {
+	pcm := testSignal(4000)
+	for _, coding := range []ExtCoding{ExtCodingALaw, ExtCodingULaw} {
+		octets := make([]byte, len(pcm))
+		for i, v := range pcm {
+			if coding == ExtCodingALaw {
+				octets[i] = g711.EncodeALaw(v)
+			} else {
+				octets[i] = g711.EncodeULaw(v)
+			}
+		}
+
+		for rate := Rate16kbps; rate <= Rate40kbps; rate++ {
+			newState := func() *G726_state {
+				s, err := New(rate, PackingRight, WithExtCoding(coding))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}
+
+			// G.711 -> G.726 -> G.711 -> G.726 得到的码流必须完全相同
+			first := newState().EncodeG711(octets)
+			out := newState().DecodeG711(first)
+			second := newState().EncodeG711(out)
+			if string(first) != string(second) {
+				t.Fatalf("%v %v: tandem coding changed the bit stream", rate, coding)
+			}
+			if again := newState().DecodeG711(second); string(again) != string(out) {
+				t.Fatalf("%v %v: tandem decoding changed the output", rate, coding)
+			}
+
+			// int16 接口使用低8位, 结果与字节接口相同
+			in16 := make([]int16, len(octets))
+			for i, v := range octets {
+				in16[i] = int16(v)
+			}
+			if got := newState().EncodeV2(in16); string(got) != string(first) {
+				t.Fatalf("%v %v: int16 encoding differs", rate, coding)
+			}
+			for i, v := range newState().DecodeV2(first) {
+				if v != int16(out[i]) {
+					t.Fatalf("%v %v: int16 decoding differs at %d", rate, coding, i)
+				}
+			}
+		}
+	}
+
+	if _, err := New(Rate32kbps, PackingLeft, WithExtCoding(ExtCoding(3))); !errors.Is(err, ErrInvalidCoding) {
+		t.Fatalf("got %v, want ErrInvalidCoding", err)
+	}
+}
+
+func TestExtCodingSnapshot(t *testing.T) {
+	s, _ := New(Rate24kbps, PackingLeft, WithExtCoding(ExtCodingULaw))
+	s.EncodeG711([]byte{0x12, 0x34, 0x56, 0x78, 0x9A})
+
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored G726_state
+	if err = restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Equal(s) {
+		t.Fatal("restored state differs")
+	}
+
+	data[3] = 7
+	if err = restored.UnmarshalBinary(data); !errors.Is(err, ErrInvalidCoding) {
+		t.Fatalf("got %v, want ErrInvalidCoding", err)
+	}
+}
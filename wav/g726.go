@@ -0,0 +1,100 @@
+package wav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+)
+
+type config struct {
+	packing g726.PackingType
+}
+
+func newConfig(opts []Option) config {
+	c := config{packing: g726.PackingLeft}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Option 用于 G.726 编解码的可选配置
+type Option func(*config)
+
+// WithPacking 设置 G.726 码字的打包方式, 默认为 PackingLeft
+// 有些软件(例如按 RFC 3551 保存 RTP 负载的工具)使用 PackingRight
+func WithPacking(packing g726.PackingType) Option {
+	return func(c *config) {
+		c.packing = packing
+	}
+}
+
+// ReadPCM 读取 WAV 文件并返回按声道交织的16位 PCM 和它的格式, G.726 文件先解码
+func ReadPCM(r io.Reader, opts ...Option) ([]int16, Format, error) {
+	f, err := Read(r)
+	if err != nil {
+		return nil, Format{}, err
+	}
+	return f.PCM(opts...)
+}
+
+// PCM 返回文件的16位 PCM 和它的格式, G.726 文件先解码
+func (f *File) PCM(opts ...Option) ([]int16, Format, error) {
+	if f.IsPCM() {
+		pcm := make([]int16, len(f.Data)/2)
+		for i := range pcm {
+			pcm[i] = int16(binary.LittleEndian.Uint16(f.Data[2*i:]))
+		}
+		return pcm, PCMFormat(f.Channels, f.SampleRate), nil
+	}
+
+	rate, err := f.G726Rate()
+	if err != nil {
+		return nil, Format{}, err
+	}
+	if f.Channels != 1 {
+		return nil, Format{}, fmt.Errorf("%w: G.726 with %d channels", ErrUnsupported, f.Channels)
+	}
+
+	c := newConfig(opts)
+	dec, err := g726.New(rate, c.packing)
+	if err != nil {
+		return nil, Format{}, err
+	}
+	pcm := dec.DecodeV2(f.Data)
+	if f.Samples >= 0 && f.Samples < int64(len(pcm)) {
+		// 去掉补齐 nBlockAlign 的部分
+		pcm = pcm[:f.Samples]
+	}
+	return pcm, PCMFormat(1, f.SampleRate), nil
+}
+
+// WritePCM 写入16位 PCM 的 WAV 文件, pcm 按声道交织
+func WritePCM(w io.Writer, pcm []int16, channels, sampleRate int) error {
+	ww, err := NewWriter(w, PCMFormat(channels, sampleRate))
+	if err != nil {
+		return err
+	}
+	if err := ww.WriteSamples(pcm); err != nil {
+		return err
+	}
+	return ww.Close()
+}
+
+// WriteG726 把单声道 8000Hz 的 PCM 编码为 G.726 并写入 WAV 文件
+func WriteG726(w io.Writer, pcm []int16, rate g726.Rate, opts ...Option) error {
+	f, err := G726Format(rate)
+	if err != nil {
+		return err
+	}
+	ww, err := NewWriter(w, f, opts...)
+	if err != nil {
+		return err
+	}
+	if err := ww.WriteSamples(pcm); err != nil {
+		return err
+	}
+	return ww.Close()
+}
@@ -0,0 +1,227 @@
+// Package wav 读写 RIFF/WAVE 文件, 支持16位线性 PCM 和 G.726 ADPCM (WAVE_FORMAT_G726_ADPCM, 0x0064)
+//
+// G.726 WAV 文件为单声道 8000Hz, wBitsPerSample 为每个码字的比特数(2-5), 默认按 PackingLeft
+// (高位在前, 与 Windows ACM 和 ffmpeg 一致)打包, fact chunk 记录采样点数.
+// ReadPCM, WritePCM 和 WriteG726 一次完成整个文件的读写和编解码, Writer 用于流式写入.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/internal/riff"
+)
+
+// 格式标签
+const (
+	FormatPCM        = 0x0001
+	FormatALaw       = 0x0006
+	FormatMuLaw      = 0x0007
+	FormatG726       = 0x0064
+	FormatExtensible = 0xFFFE
+)
+
+var (
+	ErrFormat      = errors.New("wav: not a WAVE file")
+	ErrNoFormat    = errors.New("wav: missing fmt chunk")
+	ErrNoData      = errors.New("wav: missing data chunk")
+	ErrUnsupported = errors.New("wav: unsupported format")
+)
+
+var (
+	waveID = riff.FourCC{'W', 'A', 'V', 'E'}
+	fmtID  = riff.FourCC{'f', 'm', 't', ' '}
+	factID = riff.FourCC{'f', 'a', 'c', 't'}
+	dataID = riff.FourCC{'d', 'a', 't', 'a'}
+)
+
+// Format WAVEFORMATEX
+type Format struct {
+	Tag           uint16
+	Channels      int
+	SampleRate    int
+	ByteRate      int // nAvgBytesPerSec
+	BlockAlign    int // nBlockAlign
+	BitsPerSample int
+	Extra         []byte // cbSize 之后的扩展数据
+}
+
+// PCMFormat 返回16位线性 PCM 的格式
+func PCMFormat(channels, sampleRate int) Format {
+	return Format{
+		Tag:           FormatPCM,
+		Channels:      channels,
+		SampleRate:    sampleRate,
+		ByteRate:      2 * channels * sampleRate,
+		BlockAlign:    2 * channels,
+		BitsPerSample: 16,
+	}
+}
+
+// G726Format 返回 G.726 的格式, 单声道 8000Hz
+// nBlockAlign 为能容纳整数个码字的最小字节数
+func G726Format(rate g726.Rate) (Format, error) {
+	if rate < g726.Rate16kbps || rate > g726.Rate40kbps {
+		return Format{}, g726.ErrInvalidRate
+	}
+	bits := int(rate) + 2
+	return Format{
+		Tag:           FormatG726,
+		Channels:      1,
+		SampleRate:    8000,
+		ByteRate:      bits * 1000,
+		BlockAlign:    bits / gcd(8, bits),
+		BitsPerSample: bits,
+	}, nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// IsPCM 是否为16位线性 PCM
+func (f Format) IsPCM() bool {
+	tag := f.Tag
+	if tag == FormatExtensible && len(f.Extra) >= 8 {
+		// WAVEFORMATEXTENSIBLE 的 SubFormat GUID 前两个字节为格式标签
+		tag = binary.LittleEndian.Uint16(f.Extra[6:])
+	}
+	return tag == FormatPCM && f.BitsPerSample == 16
+}
+
+// G726Rate 返回 G.726 格式的码率
+func (f Format) G726Rate() (g726.Rate, error) {
+	if f.Tag != FormatG726 {
+		return 0, fmt.Errorf("%w: format tag %#04x is not G.726", ErrUnsupported, f.Tag)
+	}
+	if f.BitsPerSample < 2 || f.BitsPerSample > 5 {
+		return 0, fmt.Errorf("%w: G.726 with %d bits per sample", ErrUnsupported, f.BitsPerSample)
+	}
+	return g726.Rate(f.BitsPerSample - 2), nil
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler, 返回 WAVEFORMATEX, PCM 格式不带 cbSize
+func (f Format) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 16, 18+len(f.Extra))
+	binary.LittleEndian.PutUint16(b[0:], f.Tag)
+	binary.LittleEndian.PutUint16(b[2:], uint16(f.Channels))
+	binary.LittleEndian.PutUint32(b[4:], uint32(f.SampleRate))
+	binary.LittleEndian.PutUint32(b[8:], uint32(f.ByteRate))
+	binary.LittleEndian.PutUint16(b[12:], uint16(f.BlockAlign))
+	binary.LittleEndian.PutUint16(b[14:], uint16(f.BitsPerSample))
+	if f.Tag != FormatPCM {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(f.Extra)))
+		b = append(b, f.Extra...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler, 解析 WAVEFORMATEX 或 PCMWAVEFORMAT
+func (f *Format) UnmarshalBinary(b []byte) error {
+	if len(b) < 16 {
+		return fmt.Errorf("wav: fmt chunk too short (%d bytes)", len(b))
+	}
+	f.Tag = binary.LittleEndian.Uint16(b[0:])
+	f.Channels = int(binary.LittleEndian.Uint16(b[2:]))
+	f.SampleRate = int(binary.LittleEndian.Uint32(b[4:]))
+	f.ByteRate = int(binary.LittleEndian.Uint32(b[8:]))
+	f.BlockAlign = int(binary.LittleEndian.Uint16(b[12:]))
+	f.BitsPerSample = int(binary.LittleEndian.Uint16(b[14:]))
+	f.Extra = nil
+	if len(b) >= 18 {
+		n := int(binary.LittleEndian.Uint16(b[16:]))
+		if n > len(b)-18 {
+			n = len(b) - 18
+		}
+		f.Extra = append([]byte(nil), b[18:18+n]...)
+	}
+	if f.Channels == 0 {
+		return fmt.Errorf("wav: zero channels")
+	}
+	return nil
+}
+
+// File WAV 文件的内容
+type File struct {
+	Format
+	Samples int64 // fact chunk 中每声道的采样点数, 没有 fact chunk 时为 -1
+	Data    []byte
+}
+
+// Read 读取 WAV 文件, 忽略不认识的 chunk
+// data chunk 被截断(例如录音时异常退出)时返回已经读到的数据
+func Read(r io.Reader) (*File, error) {
+	form, chunks, err := riff.ReadForm(r)
+	if err == riff.ErrFormat || err == nil && form != waveID {
+		return nil, ErrFormat
+	} else if err != nil {
+		return nil, err
+	}
+
+	f := &File{Samples: -1}
+	var haveFormat, haveData bool
+	for {
+		id, size, body, err := chunks.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch id {
+		case fmtID:
+			b, err := io.ReadAll(body)
+			if err != nil {
+				return nil, err
+			}
+			if err := f.Format.UnmarshalBinary(b); err != nil {
+				return nil, err
+			}
+			haveFormat = true
+		case factID:
+			var b [4]byte
+			if size >= 4 {
+				if _, err := io.ReadFull(body, b[:]); err != nil {
+					return nil, err
+				}
+				f.Samples = int64(binary.LittleEndian.Uint32(b[:]))
+			}
+		case dataID:
+			data, err := io.ReadAll(body)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return nil, err
+			}
+			f.Data = data
+			haveData = true
+		}
+	}
+
+	if !haveFormat {
+		return nil, ErrNoFormat
+	}
+	if !haveData {
+		return nil, ErrNoData
+	}
+	return f, nil
+}
+
+// Write 写入 WAV 文件, 非 PCM 格式带有 fact chunk
+func Write(w io.Writer, f *File) error {
+	ww, err := NewWriter(w, f.Format)
+	if err != nil {
+		return err
+	}
+	if _, err := ww.Write(f.Data); err != nil {
+		return err
+	}
+	if f.Samples >= 0 {
+		ww.SetSamples(f.Samples)
+	}
+	return ww.Close()
+}
@@ -0,0 +1,177 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+func testSignal(n int) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	return pcm
+}
+
+func TestPCM(t *testing.T) {
+	pcm := testSignal(1001 * 2)
+
+	var buf bytes.Buffer
+	if err := WritePCM(&buf, pcm, 2, 16000); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if len(b) != 44+len(pcm)*2 || string(b[:4]) != "RIFF" || binary.LittleEndian.Uint32(b[4:]) != uint32(len(b)-8) ||
+		string(b[8:16]) != "WAVEfmt " || binary.LittleEndian.Uint32(b[16:]) != 16 || string(b[36:40]) != "data" {
+		t.Fatalf("bad header % x", b[:44])
+	}
+
+	got, f, err := ReadPCM(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f, PCMFormat(2, 16000)) || f.ByteRate != 64000 || f.BlockAlign != 4 {
+		t.Fatalf("format %+v", f)
+	}
+	if len(got) != len(pcm) {
+		t.Fatalf("%d samples, want %d", len(got), len(pcm))
+	}
+	for i := range pcm {
+		if got[i] != pcm[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], pcm[i])
+		}
+	}
+}
+
+func TestG726(t *testing.T) {
+	pcm := testSignal(1001)
+	blockAlign := []int{1, 3, 1, 5}
+
+	for rate := g726.Rate16kbps; rate <= g726.Rate40kbps; rate++ {
+		for _, packing := range []g726.PackingType{g726.PackingLeft, g726.PackingRight} {
+			var buf bytes.Buffer
+			if err := WriteG726(&buf, pcm, rate, WithPacking(packing)); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := Read(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bits := int(rate) + 2
+			if f.Tag != FormatG726 || f.Channels != 1 || f.SampleRate != 8000 || f.BitsPerSample != bits ||
+				f.ByteRate != 1000*bits || f.BlockAlign != blockAlign[rate] || f.Samples != 1001 {
+				t.Fatalf("rate %v: %+v", rate, f.Format)
+			}
+			if len(f.Data)%f.BlockAlign != 0 || len(f.Data) < 1001*bits/8 {
+				t.Fatalf("rate %v: %d data bytes", rate, len(f.Data))
+			}
+
+			got, pf, err := f.PCM(WithPacking(packing))
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc, _ := g726.New(rate, packing)
+			dec, _ := g726.New(rate, packing)
+			want := dec.DecodeV2(append(enc.EncodeV2(pcm), enc.Flush()...))
+			if !reflect.DeepEqual(pf, PCMFormat(1, 8000)) || len(got) != len(pcm) {
+				t.Fatalf("rate %v: %d samples, format %+v", rate, len(got), pf)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("rate %v packing %v: sample %d = %d, want %d", rate, packing, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestWriterSeekable(t *testing.T) {
+	pcm := testSignal(800)
+
+	var buf bytes.Buffer
+	if err := WriteG726(&buf, pcm, g726.Rate24kbps); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入文件时在原地补上长度, 结果与写入内存相同
+	name := filepath.Join(t.TempDir(), "test.wav")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := G726Format(g726.Rate24kbps)
+	w, err := NewWriter(file, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(pcm); i += 100 {
+		if err := w.WriteSamples(pcm[i : i+100]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, buf.Bytes()) {
+		t.Fatal("seekable and buffered output differ")
+	}
+}
+
+func TestReadChunks(t *testing.T) {
+	// 带有奇数长度的未知 chunk, data 在 fact 之前, 数据被截断
+	var b []byte
+	chunk := func(id string, body []byte) {
+		b = append(b, id...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(body)))
+		b = append(b, body...)
+		if len(body)%2 != 0 {
+			b = append(b, 0)
+		}
+	}
+	f, _ := G726Format(g726.Rate32kbps)
+	fmtChunk, _ := f.MarshalBinary()
+	chunk("fmt ", fmtChunk)
+	chunk("LIST", []byte("INFOISFT\x03\x00\x00\x00Go\x00"))
+	chunk("data", []byte{1, 2, 3, 4, 5})
+	chunk("fact", []byte{9, 0, 0, 0})
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, 100)
+	b = append(b, 6, 7)
+	b = append([]byte("RIFF\x00\x00\x00\x00WAVE"), b...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	file, err := Read(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Samples != 9 || !bytes.Equal(file.Data, []byte{6, 7}) || file.BlockAlign != 1 {
+		t.Fatalf("%+v", file)
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00AVI "))); !errors.Is(err, ErrFormat) {
+		t.Fatalf("AVI file: %v", err)
+	}
+	if _, err := Read(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00WAVE"))); !errors.Is(err, ErrNoFormat) {
+		t.Fatalf("empty file: %v", err)
+	}
+	noData := append([]byte("RIFF\x00\x00\x00\x00WAVE"), b[12:12+8+18]...)
+	binary.LittleEndian.PutUint32(noData[4:], uint32(len(noData)-8))
+	if _, _, err := ReadPCM(bytes.NewReader(noData)); !errors.Is(err, ErrNoData) {
+		t.Fatalf("file without data: %v", err)
+	}
+}
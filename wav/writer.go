@@ -0,0 +1,158 @@
+package wav
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/general252/g726"
+	"github.com/general252/g726/internal/riff"
+)
+
+// Writer 流式写入 WAV 文件, Close 时补上各 chunk 的长度和 fact chunk 的采样点数
+// 目标不支持 Seek 时数据先缓存在内存中, Close 时一次写出
+type Writer struct {
+	dst     io.Writer
+	buf     *riff.Buffer
+	rw      *riff.Writer
+	format  Format
+	factPos int64 // fact chunk 中采样点数的位置, 没有 fact chunk 时为0
+	samples int64 // 每声道的采样点数, 为负数时由数据长度计算
+	bytes   int64
+	packing g726.PackingType
+	enc     *g726.G726_state
+	codes   []byte
+}
+
+// NewWriter 创建 Writer, 写入 fmt chunk 并开始 data chunk
+func NewWriter(w io.Writer, f Format, opts ...Option) (*Writer, error) {
+	c := newConfig(opts)
+
+	ww := &Writer{dst: w, format: f, samples: -1, packing: c.packing}
+	ws, ok := w.(io.WriteSeeker)
+	if !ok {
+		ww.buf = &riff.Buffer{}
+		ws = ww.buf
+	}
+
+	rw, err := riff.NewWriter(ws, waveID)
+	if err != nil {
+		return nil, err
+	}
+	ww.rw = rw
+
+	if err := rw.StartChunk(fmtID); err != nil {
+		return nil, err
+	}
+	b, _ := f.MarshalBinary()
+	if _, err := rw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := rw.EndChunk(); err != nil {
+		return nil, err
+	}
+
+	if f.Tag != FormatPCM && f.Tag != FormatExtensible {
+		if err := rw.StartChunk(factID); err != nil {
+			return nil, err
+		}
+		ww.factPos = rw.Offset()
+		if _, err := rw.Write(make([]byte, 4)); err != nil {
+			return nil, err
+		}
+		if err := rw.EndChunk(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rw.StartChunk(dataID); err != nil {
+		return nil, err
+	}
+	return ww, nil
+}
+
+// Write 写入已经编码的数据
+// 只用 Write 写入时 fact chunk 中的采样点数由数据长度计算, 可以用 SetSamples 指定
+func (w *Writer) Write(data []byte) (int, error) {
+	n, err := w.rw.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// SetSamples 设置 fact chunk 中每声道的采样点数
+func (w *Writer) SetSamples(n int64) {
+	w.samples = n
+}
+
+// WriteSamples 写入按声道交织的 PCM, G.726 格式(只支持单声道)时先编码
+func (w *Writer) WriteSamples(pcm []int16) error {
+	if w.format.Tag == FormatG726 {
+		if w.enc == nil {
+			rate, err := w.format.G726Rate()
+			if err != nil {
+				return err
+			}
+			if w.format.Channels != 1 {
+				return ErrUnsupported
+			}
+			if w.enc, err = g726.New(rate, w.packing); err != nil {
+				return err
+			}
+			w.samples = 0
+		}
+		w.codes = w.enc.AppendEncode(w.codes[:0], pcm)
+		_, err := w.Write(w.codes)
+		w.samples += int64(len(pcm))
+		return err
+	}
+	if !w.format.IsPCM() {
+		return ErrUnsupported
+	}
+
+	b := make([]byte, 2*len(pcm))
+	for i, v := range pcm {
+		binary.LittleEndian.PutUint16(b[2*i:], uint16(v))
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// Close 结束 G.726 编码(补齐到 nBlockAlign), 补上长度信息
+func (w *Writer) Close() error {
+	if w.enc != nil {
+		w.codes = w.enc.AppendFlush(w.codes[:0])
+		for w.format.BlockAlign > 1 && (w.bytes+int64(len(w.codes)))%int64(w.format.BlockAlign) != 0 {
+			w.codes = append(w.codes, 0)
+		}
+		if _, err := w.Write(w.codes); err != nil {
+			return err
+		}
+	}
+
+	if w.factPos > 0 {
+		samples := w.samples
+		if samples < 0 {
+			samples = w.dataSamples()
+		}
+		if err := w.rw.Patch(w.factPos, uint32(samples)); err != nil {
+			return err
+		}
+	}
+	if err := w.rw.Close(); err != nil {
+		return err
+	}
+
+	if w.buf != nil {
+		_, err := w.dst.Write(w.buf.Bytes())
+		return err
+	}
+	return nil
+}
+
+// dataSamples 由数据长度计算每声道的采样点数, G.726 的 wBitsPerSample 为每个码字的比特数
+func (w *Writer) dataSamples() int64 {
+	f := w.format
+	if f.BitsPerSample <= 0 || f.Channels <= 0 {
+		return 0
+	}
+	return w.bytes * 8 / int64(f.BitsPerSample*f.Channels)
+}
@@ -0,0 +1,254 @@
+// Package waveform 把8kHz PCM 的波形绘制为图片, 用于对比原信号和 G.726 编解码之后的信号
+//
+// 多个波形上下排列, 共用同一条时间轴和相同的纵轴范围(默认为16位满量程), 所以差值的幅度可以直接和原信号比较.
+// 每一列像素画出对应时间段内采样点的最小值到最大值, 放大到少于一个像素一个采样点时连接相邻的采样点.
+// 竖直网格线按整数的时间间隔(1, 2, 5 x 10^n 个采样点)对齐.
+package waveform
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/general252/g726"
+)
+
+// 波形的颜色
+var (
+	OriginalColor   = color.RGBA{0x44, 0xdd, 0x99, 0xff}
+	DecodedColor    = color.RGBA{0x44, 0xaa, 0xff, 0xff}
+	DifferenceColor = color.RGBA{0xff, 0x66, 0x44, 0xff}
+)
+
+var (
+	backgroundColor = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	gridColor       = color.RGBA{0x00, 0x48, 0x00, 0xff}
+	axisColor       = color.RGBA{0x00, 0x80, 0x00, 0xff}
+	separatorColor  = color.RGBA{0x60, 0x60, 0x60, 0xff}
+)
+
+const (
+	separatorHeight = 2  // 波形之间分隔线的高度
+	gridRows        = 8  // 每个波形的水平网格数
+	minGridSpacing  = 50 // 竖直网格线之间最少的像素
+)
+
+// Panel 一个波形
+type Panel struct {
+	PCM   []int16
+	Color color.Color // nil 时使用 OriginalColor
+}
+
+type config struct {
+	width, height int // 宽度和每个波形的高度
+	start, end    int // 显示的采样点范围 [start, end), end <= 0 表示到最长的波形结束
+	amplitude     int // 纵轴的范围 [-amplitude, amplitude)
+}
+
+// Option 设置绘制参数
+type Option func(*config)
+
+// WithSize 设置图片宽度和每个波形的高度(像素), 默认 1500x300
+func WithSize(width, height int) Option {
+	return func(c *config) {
+		c.width, c.height = width, height
+	}
+}
+
+// WithRange 只显示采样点 [start, end), end <= 0 表示到结束, 用于放大一段波形
+func WithRange(start, end int) Option {
+	return func(c *config) {
+		c.start, c.end = start, end
+	}
+}
+
+// WithAmplitude 设置纵轴的范围为 ±amplitude, 默认 32768 (满量程), 用于放大较小的信号, 超出范围的部分被截断
+func WithAmplitude(amplitude int) Option {
+	return func(c *config) {
+		c.amplitude = amplitude
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{width: 1500, height: 300, amplitude: 32768}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.width < 1 {
+		c.width = 1
+	}
+	if c.height < 2 {
+		c.height = 2
+	}
+	if c.start < 0 {
+		c.start = 0
+	}
+	if c.amplitude < 1 || c.amplitude > 32768 {
+		c.amplitude = 32768
+	}
+	return c
+}
+
+// Render 把 panels 从上到下绘制在同一条时间轴上
+func Render(panels []Panel, opts ...Option) *image.RGBA {
+	c := newConfig(opts)
+	if c.end <= 0 {
+		for _, p := range panels {
+			if len(p.PCM) > c.end {
+				c.end = len(p.PCM)
+			}
+		}
+	}
+	if c.end <= c.start {
+		c.end = c.start + 1
+	}
+
+	height := len(panels)*c.height + (len(panels)-1)*separatorHeight
+	if len(panels) == 0 {
+		height = c.height
+	}
+	img := image.NewRGBA(image.Rect(0, 0, c.width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{backgroundColor}, image.Point{}, draw.Src)
+
+	for i, p := range panels {
+		top := i * (c.height + separatorHeight)
+		if i > 0 {
+			r := image.Rect(0, top-separatorHeight, c.width, top)
+			draw.Draw(img, r, &image.Uniform{separatorColor}, image.Point{}, draw.Src)
+		}
+		sub := img.SubImage(image.Rect(0, top, c.width, top+c.height)).(*image.RGBA)
+		drawGrid(sub, &c)
+		col := p.Color
+		if col == nil {
+			col = OriginalColor
+		}
+		drawTrace(sub, p.PCM, col, &c)
+	}
+	return img
+}
+
+// RenderComparison 用 rate 编解码 pcm, 从上到下绘制原信号, 解码的信号和两者之差
+func RenderComparison(pcm []int16, rate g726.Rate, opts ...Option) (*image.RGBA, error) {
+	enc, err := g726.New(rate, g726.PackingRight)
+	if err != nil {
+		return nil, err
+	}
+	dec, _ := g726.New(rate, g726.PackingRight)
+	decoded := dec.DecodeV2(enc.AppendFlush(enc.EncodeV2(pcm)))[:len(pcm)]
+
+	return Render([]Panel{
+		{PCM: pcm, Color: OriginalColor},
+		{PCM: decoded, Color: DecodedColor},
+		{PCM: Diff(decoded, pcm), Color: DifferenceColor},
+	}, opts...), nil
+}
+
+// Diff 返回 a - b, 超出范围的值饱和, 长度为两者中较短的
+func Diff(a, b []int16) []int16 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	d := make([]int16, n)
+	for i := range d {
+		v := int32(a[i]) - int32(b[i])
+		if v > 32767 {
+			v = 32767
+		} else if v < -32768 {
+			v = -32768
+		}
+		d[i] = int16(v)
+	}
+	return d
+}
+
+// WritePNG 把 Render 的结果编码为 PNG
+func WritePNG(w io.Writer, panels []Panel, opts ...Option) error {
+	return png.Encode(w, Render(panels, opts...))
+}
+
+// gridStep 返回竖直网格线之间的采样点数, 形如 1, 2, 5 x 10^n, 间隔不少于 minGridSpacing 像素
+func gridStep(samples, width int) int {
+	for step := 1; ; step *= 10 {
+		for _, m := range []int{1, 2, 5} {
+			if int64(step*m)*int64(width) >= int64(minGridSpacing)*int64(samples) {
+				return step * m
+			}
+		}
+	}
+}
+
+// column 返回采样点 n 所在的像素列
+func column(n int, c *config) int {
+	return int(int64(n-c.start) * int64(c.width) / int64(c.end-c.start))
+}
+
+// row 返回采样值 v 在高度为 h 的波形中的像素行, 正数在上
+func row(v int16, h int, c *config) int {
+	y := (c.amplitude - 1 - int(v)) * (h - 1) / (2*c.amplitude - 1)
+	if y < 0 {
+		return 0
+	}
+	if y > h-1 {
+		return h - 1
+	}
+	return y
+}
+
+func drawGrid(img *image.RGBA, c *config) {
+	b := img.Bounds()
+	h := b.Dy()
+	for i := 1; i < gridRows; i++ {
+		col := gridColor
+		if i == gridRows/2 {
+			col = axisColor
+		}
+		y := b.Min.Y + i*(h-1)/gridRows
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.SetRGBA(x, y, col)
+		}
+	}
+
+	step := gridStep(c.end-c.start, c.width)
+	for n := (c.start + step - 1) / step * step; n < c.end; n += step {
+		x := b.Min.X + column(n, c)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			if img.RGBAAt(x, y) == backgroundColor {
+				img.SetRGBA(x, y, gridColor)
+			}
+		}
+	}
+}
+
+// drawTrace 在每一列画出对应采样点的最小值到最大值
+func drawTrace(img *image.RGBA, pcm []int16, col color.Color, c *config) {
+	b := img.Bounds()
+	h := b.Dy()
+	span := int64(c.end - c.start)
+	for x := 0; x < c.width; x++ {
+		// 这一列对应的采样点 [first, last], 包括下一列的第一个采样点, 使放大时的波形连续
+		first := c.start + int(int64(x)*span/int64(c.width))
+		last := c.start + int(int64(x+1)*span/int64(c.width))
+		if last >= len(pcm) {
+			last = len(pcm) - 1
+		}
+		if first > last {
+			break
+		}
+
+		lo, hi := pcm[first], pcm[first]
+		for _, v := range pcm[first+1 : last+1] {
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		for y := row(hi, h, c); y <= row(lo, h, c); y++ {
+			img.Set(b.Min.X+x, b.Min.Y+y, col)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package waveform
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/general252/g726"
+)
+
+func tone(n int, amplitude float64) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = int16(amplitude * math.Sin(2*math.Pi*440*float64(i)/8000))
+	}
+	return pcm
+}
+
+func TestRender(t *testing.T) {
+	// 前半段为 +16384, 后半段为 -32768
+	pcm := make([]int16, 1000)
+	for i := range pcm {
+		pcm[i] = 16384
+		if i >= 500 {
+			pcm[i] = -32768
+		}
+	}
+
+	img := Render([]Panel{{PCM: pcm}, {PCM: Diff(pcm, pcm), Color: DifferenceColor}}, WithSize(100, 101))
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 2*101+separatorHeight {
+		t.Fatal(b)
+	}
+
+	for _, tt := range []struct {
+		x, y int
+		col  color.RGBA
+	}{
+		{10, 24, OriginalColor},  // 16384 在上方 1/4 处
+		{10, 50, axisColor},      // 中间的坐标轴
+		{90, 100, OriginalColor}, // -32768 在最下面
+		{49, 75, OriginalColor},  // 两段之间的跳变是连续的
+		{10, 101, separatorColor},
+		{10, 103 + 49, DifferenceColor},
+	} {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.col {
+			t.Errorf("(%d, %d) = %v, want %v", tt.x, tt.y, got, tt.col)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	pcm := tone(8000, 20000)
+
+	// 放大到 80 个采样点, 每个采样点 10 个像素, 波形在每列都连续
+	img := Render([]Panel{{PCM: pcm}}, WithSize(800, 200), WithRange(4000, 4080))
+	for x := 0; x < 800; x++ {
+		found := false
+		for y := 0; y < 200; y++ {
+			if img.RGBAAt(x, y) == OriginalColor {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("column %d is empty", x)
+		}
+	}
+
+	// 竖直网格线的间隔为 1, 2, 5 x 10^n 个采样点
+	if step := gridStep(80, 800); step != 5 {
+		t.Fatal(step)
+	}
+	if step := gridStep(8000*60, 1500); step != 20000 {
+		t.Fatal(step)
+	}
+
+	// 超出信号的部分为空, 纵轴放大后截断
+	img = Render([]Panel{{PCM: pcm}}, WithSize(100, 100), WithRange(7990, 8010), WithAmplitude(1000))
+	for y := 0; y < 100; y++ {
+		if img.RGBAAt(99, y) == OriginalColor {
+			t.Fatalf("trace after the end of the signal at row %d", y)
+		}
+	}
+}
+
+func TestRenderComparison(t *testing.T) {
+	pcm := tone(1001, 10000)
+	img, err := RenderComparison(pcm, g726.Rate32kbps, WithSize(300, 80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 3*80+2*separatorHeight {
+		t.Fatal(b)
+	}
+	if _, err := RenderComparison(pcm, g726.Rate(7)); err == nil {
+		t.Fatal("invalid rate accepted")
+	}
+
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, []Panel{{PCM: pcm}}, WithSize(64, 32)); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := decoded.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Fatal(b)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	d := Diff([]int16{100, 32767, -32768, 5}, []int16{50, -10, 10})
+	if len(d) != 3 || d[0] != 50 || d[1] != 32767 || d[2] != -32768 {
+		t.Fatal(d)
+	}
+}